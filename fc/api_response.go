@@ -22,7 +22,10 @@ type APIResponse struct {
 	StatusCode                int
 	Status                    string
 	IsSuccessful              bool
+	Outcome                   Outcome
 	Err                       error
+	// endpoint is the name of the endpoint the response belongs to, used to derive the Outcome.
+	endpoint string
 }
 
 func (resp *APIResponse) String() string {
@@ -30,10 +33,10 @@ func (resp *APIResponse) String() string {
 		"\nResolveResponse: %v,\nResolveResponseWithTags: %v,\nTagsResponse: %v,\nAudienceResponse: %v,"+
 		"\nPermissionFindResponse: %v,\nPermissionCurrentResponse: %v,\nPermissionVerifyResponse: %v,"+
 		"\nVerifySignalsResponse: %v,\nVerifyMatchResponse: %v,\nVerifyActivityResponse: %v,"+
-		"\nStatusCode: %v,\nStatus: %v,\nIsSuccessful: %v,\nErr: %v\n",
+		"\nStatusCode: %v,\nStatus: %v,\nIsSuccessful: %v,\nOutcome: %v,\nErr: %v\n",
 		resp.RawHttpResponse, resp.PersonResponse, resp.CompanyResponse, resp.ResolveResponse,
 		resp.ResolveResponseWithTags, resp.TagsResponse, resp.AudienceResponse,
 		resp.PermissionFindResponse, resp.PermissionCurrentResponse, resp.PermissionVerifyResponse,
 		resp.VerifySignalsResponse, resp.VerifyMatchResponse, resp.VerifyActivityResponse,
-		resp.StatusCode, resp.Status, resp.IsSuccessful, resp.Err)
+		resp.StatusCode, resp.Status, resp.IsSuccessful, resp.Outcome, resp.Err)
 }
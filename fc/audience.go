@@ -12,6 +12,7 @@ type AudienceRequestOption func(ar *AudienceRequest)
 type AudienceRequest struct {
 	WebhookURL string `json:"webhookUrl,omitempty"`
 	Tags       []*Tag `json:"tags,omitempty"`
+	tagSchema  *TagSchema
 }
 
 func NewAudienceRequest(option ...AudienceRequestOption) (*AudienceRequest, error) {
@@ -21,6 +22,9 @@ func NewAudienceRequest(option ...AudienceRequestOption) (*AudienceRequest, erro
 		opt(audienceRequest)
 	}
 	err := validateAudienceRequest(audienceRequest)
+	if err == nil {
+		err = audienceRequest.tagSchema.validateTags(audienceRequest.Tags)
+	}
 	if err != nil {
 		audienceRequest = nil
 	}
@@ -48,6 +52,13 @@ func WithWebhookUrlForAudience(webhookUrl string) AudienceRequestOption {
 	}
 }
 
+// WithTagSchemaForAudience validates the tags of the request against the schema when it's created.
+func WithTagSchemaForAudience(schema *TagSchema) AudienceRequestOption {
+	return func(audienceRequest *AudienceRequest) {
+		audienceRequest.tagSchema = schema
+	}
+}
+
 func WithTagForAudience(tag *Tag) AudienceRequestOption {
 	return func(audienceRequest *AudienceRequest) {
 		if audienceRequest.Tags == nil {
@@ -71,6 +82,8 @@ func WithTagsForAudience(tags []*Tag) AudienceRequestOption {
 type AudienceResponse struct {
 	RequestId     string `json:"requestId"`
 	AudienceBytes []byte
+	// BytesWritten is the size of the audience file streamed by AudienceDownloadTo or AudienceDownloadToFile.
+	BytesWritten int64 `json:"-"`
 }
 
 func (audienceResponse AudienceResponse) WriteAudienceBytesToFile(fileName string) error {
@@ -0,0 +1,232 @@
+package fullcontact
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+type AudienceState string
+
+const (
+	AudienceCreated     AudienceState = "created"
+	AudienceNotReady    AudienceState = "not_ready"
+	AudienceNotified    AudienceState = "notified"
+	AudienceDownloading AudienceState = "downloading"
+	AudienceDownloaded  AudienceState = "downloaded"
+)
+
+// AudienceStatus is reported to the status callback of an AudienceBuilder at every step.
+type AudienceStatus struct {
+	RequestId string
+	State     AudienceState
+	// Attempt is the number of download attempts made so far.
+	Attempt  int
+	Response *APIResponse
+}
+
+// AudienceResult is the result of an audience created and downloaded by an AudienceBuilder.
+type AudienceResult struct {
+	RequestId    string
+	BytesWritten int64
+	Attempts     int
+}
+
+type AudienceBuilderOption func(ab *AudienceBuilder)
+
+/*
+AudienceBuilder creates an audience and downloads it once ready, as a single blocking operation.
+
+After AudienceCreate, the download is attempted with an exponential backoff until the audience
+is ready. With a WebhookHandler, the download waits for the audience notification instead.
+The audience file is streamed to the destination, see AudienceDownloadTo.
+*/
+type AudienceBuilder struct {
+	client          AudienceAPI
+	initialInterval time.Duration
+	maxInterval     time.Duration
+	statusCallback  func(status *AudienceStatus)
+	handler         *WebhookHandler
+	mutex           sync.Mutex
+	notifications   map[string]chan struct{}
+	// early holds the notifications received before AudienceCreate returned
+	early map[string]time.Time
+}
+
+func NewAudienceBuilder(client AudienceAPI, options ...AudienceBuilderOption) (*AudienceBuilder, error) {
+	if client == nil {
+		return nil, NewFullContactError("Client can't be nil")
+	}
+	ab := &AudienceBuilder{
+		client:          client,
+		initialInterval: 5 * time.Second,
+		maxInterval:     time.Minute,
+		notifications:   make(map[string]chan struct{}),
+		early:           make(map[string]time.Time),
+	}
+	for _, opts := range options {
+		opts(ab)
+	}
+	if ab.handler != nil {
+		ab.handler.OnAudience(ab.onAudience)
+	}
+	return ab, nil
+}
+
+// WithAudiencePollInterval sets the delay before the first download attempt, doubled up to max, 5s and 1m by default.
+func WithAudiencePollInterval(initial, max time.Duration) AudienceBuilderOption {
+	return func(ab *AudienceBuilder) {
+		ab.initialInterval = initial
+		ab.maxInterval = max
+	}
+}
+
+func WithAudienceStatusCallback(statusCallback func(status *AudienceStatus)) AudienceBuilderOption {
+	return func(ab *AudienceBuilder) {
+		ab.statusCallback = statusCallback
+	}
+}
+
+// WithAudienceWebhookHandler waits for the audience notification on the handler before downloading.
+func WithAudienceWebhookHandler(handler *WebhookHandler) AudienceBuilderOption {
+	return func(ab *AudienceBuilder) {
+		ab.handler = handler
+	}
+}
+
+// CreateTo creates the audience and streams it to the writer. Options are applied to every API call.
+func (ab *AudienceBuilder) CreateTo(ctx context.Context, audienceRequest *AudienceRequest, writer io.Writer, options ...CallOption) (*AudienceResult, error) {
+	if writer == nil {
+		return nil, NewFullContactError("Writer can't be nil")
+	}
+	return ab.create(ctx, audienceRequest, func(requestId string, options []CallOption) chan *APIResponse {
+		return ab.client.AudienceDownloadTo(requestId, writer, options...)
+	}, false, options)
+}
+
+// CreateToFile creates the audience and streams it to the file. Options are applied to every API call.
+func (ab *AudienceBuilder) CreateToFile(ctx context.Context, audienceRequest *AudienceRequest, fileName string, options ...CallOption) (*AudienceResult, error) {
+	if !isPopulated(fileName) {
+		return nil, NewFullContactError("File name can't be empty")
+	}
+	return ab.create(ctx, audienceRequest, func(requestId string, options []CallOption) chan *APIResponse {
+		return ab.client.AudienceDownloadToFile(requestId, fileName, options...)
+	}, true, options)
+}
+
+func (ab *AudienceBuilder) create(ctx context.Context, audienceRequest *AudienceRequest,
+	download func(requestId string, options []CallOption) chan *APIResponse, restartable bool, options []CallOption) (*AudienceResult, error) {
+	if audienceRequest == nil {
+		return nil, NewFullContactError("Audience Request can't be nil")
+	}
+	options = append([]CallOption{WithCallContext(ctx)}, options...)
+
+	resp := <-ab.client.AudienceCreate(audienceRequest, options...)
+	if resp.Err != nil {
+		return nil, resp.Err
+	}
+	if !(resp.IsMatched() || resp.IsAccepted()) || resp.AudienceResponse == nil || !isPopulated(resp.AudienceResponse.RequestId) {
+		return nil, NewFullContactError(fmt.Sprintf("Audience create failed with status %d", resp.StatusCode))
+	}
+	result := &AudienceResult{RequestId: resp.AudienceResponse.RequestId}
+	ab.report(result, AudienceCreated, resp)
+
+	notified := ab.awaitNotification(result.RequestId)
+	defer ab.forget(result.RequestId)
+
+	interval := ab.initialInterval
+	for {
+		if notified != nil {
+			select {
+			case <-notified:
+				ab.report(result, AudienceNotified, nil)
+				notified = nil
+			case <-ctx.Done():
+				return result, ctx.Err()
+			}
+		} else {
+			select {
+			case <-time.After(interval):
+			case <-ctx.Done():
+				return result, ctx.Err()
+			}
+			interval *= 2
+			if interval > ab.maxInterval {
+				interval = ab.maxInterval
+			}
+		}
+
+		result.Attempts++
+		ab.report(result, AudienceDownloading, nil)
+		resp = <-download(result.RequestId, options)
+		if resp.AudienceResponse != nil {
+			result.BytesWritten = resp.AudienceResponse.BytesWritten
+		}
+		switch {
+		case resp.IsMatched():
+			ab.report(result, AudienceDownloaded, resp)
+			return result, nil
+		case resp.IsNoMatch() || resp.IsAccepted():
+			ab.report(result, AudienceNotReady, resp)
+		case resp.IsRetryable() && ctx.Err() == nil && (restartable || result.BytesWritten == 0):
+			// A partial download to a writer can't be restarted, a file is only renamed once complete
+			ab.report(result, AudienceNotReady, resp)
+		case resp.Err != nil:
+			return result, resp.Err
+		default:
+			return result, NewFullContactError(fmt.Sprintf("Audience download failed with status %d", resp.StatusCode))
+		}
+	}
+}
+
+func (ab *AudienceBuilder) report(result *AudienceResult, state AudienceState, resp *APIResponse) {
+	if ab.statusCallback != nil {
+		ab.statusCallback(&AudienceStatus{RequestId: result.RequestId, State: state, Attempt: result.Attempts, Response: resp})
+	}
+}
+
+// awaitNotification returns the channel closed on notification, nil without a WebhookHandler.
+func (ab *AudienceBuilder) awaitNotification(requestId string) chan struct{} {
+	if ab.handler == nil {
+		return nil
+	}
+	ab.mutex.Lock()
+	defer ab.mutex.Unlock()
+	notified := make(chan struct{})
+	if _, ok := ab.early[requestId]; ok {
+		delete(ab.early, requestId)
+		close(notified)
+	}
+	ab.notifications[requestId] = notified
+	return notified
+}
+
+func (ab *AudienceBuilder) forget(requestId string) {
+	ab.mutex.Lock()
+	defer ab.mutex.Unlock()
+	delete(ab.notifications, requestId)
+}
+
+func (ab *AudienceBuilder) onAudience(notification *AudienceNotification) error {
+	ab.mutex.Lock()
+	defer ab.mutex.Unlock()
+	notified, ok := ab.notifications[notification.RequestId]
+	if !ok {
+		now := time.Now()
+		for requestId, receivedAt := range ab.early {
+			if now.Sub(receivedAt) > time.Hour {
+				delete(ab.early, requestId)
+			}
+		}
+		ab.early[notification.RequestId] = now
+		return nil
+	}
+	select {
+	case <-notified:
+	default:
+		close(notified)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package fullcontact
+
+import (
+	"bytes"
+	"context"
+	assert "github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func getAudienceBuilderTestClient(downloads ...*APIResponse) *MockClient {
+	mockClient := NewMockClient()
+	mockClient.Respond("AudienceCreate", &APIResponse{StatusCode: 200, AudienceResponse: &AudienceResponse{RequestId: "q1"}})
+	attempt := 0
+	mockClient.On("AudienceDownloadTo", func(args ...interface{}) *APIResponse {
+		resp := downloads[attempt]
+		if attempt < len(downloads)-1 {
+			attempt++
+		}
+		if resp.StatusCode == 200 {
+			n, _ := io.WriteString(args[1].(io.Writer), audienceJson)
+			resp.AudienceResponse = &AudienceResponse{RequestId: "q1", BytesWritten: int64(n)}
+		}
+		return resp
+	})
+	return mockClient
+}
+
+func getTestAudienceRequest() *AudienceRequest {
+	audienceRequest, _ := NewAudienceRequest(WithWebhookUrlForAudience("https://example.com/webhook/audience"),
+		WithTagForAudience(NewTag(WithTagKey("segment"), WithTagValue("vip"))))
+	return audienceRequest
+}
+
+func TestAudienceBuilderPollsUntilReady(t *testing.T) {
+	mockClient := getAudienceBuilderTestClient(&APIResponse{StatusCode: 404}, &APIResponse{StatusCode: 503}, &APIResponse{StatusCode: 200})
+	states := make([]AudienceState, 0)
+	builder, err := NewAudienceBuilder(mockClient,
+		WithAudiencePollInterval(time.Millisecond, 2*time.Millisecond),
+		WithAudienceStatusCallback(func(status *AudienceStatus) {
+			states = append(states, status.State)
+		}))
+	assert.NoError(t, err)
+
+	var buffer bytes.Buffer
+	result, err := builder.CreateTo(context.Background(), getTestAudienceRequest(), &buffer)
+	assert.NoError(t, err)
+	assert.Equal(t, &AudienceResult{RequestId: "q1", BytesWritten: int64(len(audienceJson)), Attempts: 3}, result)
+	assert.Equal(t, audienceJson, buffer.String())
+	assert.Equal(t, []AudienceState{AudienceCreated,
+		AudienceDownloading, AudienceNotReady,
+		AudienceDownloading, AudienceNotReady,
+		AudienceDownloading, AudienceDownloaded}, states)
+}
+
+func TestAudienceBuilderWaitsForWebhook(t *testing.T) {
+	handler := NewWebhookHandler()
+	mockClient := getAudienceBuilderTestClient(&APIResponse{StatusCode: 200})
+	mockClient.On("AudienceCreate", func(args ...interface{}) *APIResponse {
+		postWebhook(handler, http.MethodPost, "/webhook/audience", "{\"requestId\":\"q1\"}")
+		return &APIResponse{StatusCode: 200, AudienceResponse: &AudienceResponse{RequestId: "q1"}}
+	})
+	states := make([]AudienceState, 0)
+	builder, err := NewAudienceBuilder(mockClient,
+		WithAudienceWebhookHandler(handler),
+		WithAudiencePollInterval(time.Hour, time.Hour),
+		WithAudienceStatusCallback(func(status *AudienceStatus) {
+			states = append(states, status.State)
+		}))
+	assert.NoError(t, err)
+
+	var buffer bytes.Buffer
+	result, err := builder.CreateTo(context.Background(), getTestAudienceRequest(), &buffer)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Attempts)
+	assert.Equal(t, []AudienceState{AudienceCreated, AudienceNotified, AudienceDownloading, AudienceDownloaded}, states)
+}
+
+func TestAudienceBuilderCancelled(t *testing.T) {
+	mockClient := getAudienceBuilderTestClient(&APIResponse{StatusCode: 404})
+	builder, _ := NewAudienceBuilder(mockClient, WithAudiencePollInterval(time.Millisecond, time.Millisecond))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	result, err := builder.CreateTo(ctx, getTestAudienceRequest(), &bytes.Buffer{})
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, "q1", result.RequestId)
+	assert.True(t, result.Attempts > 1)
+}
+
+func TestAudienceBuilderFailures(t *testing.T) {
+	builder, _ := NewAudienceBuilder(NewMockClient().Respond("AudienceCreate", &APIResponse{StatusCode: 400}))
+	_, err := builder.CreateTo(context.Background(), getTestAudienceRequest(), &bytes.Buffer{})
+	assert.EqualError(t, err, "FullContactError: Audience create failed with status 400")
+
+	mockClient := getAudienceBuilderTestClient(&APIResponse{StatusCode: 401})
+	builder, _ = NewAudienceBuilder(mockClient, WithAudiencePollInterval(time.Millisecond, time.Millisecond))
+	_, err = builder.CreateTo(context.Background(), getTestAudienceRequest(), &bytes.Buffer{})
+	assert.EqualError(t, err, "FullContactError: Audience download failed with status 401")
+
+	partial := &APIResponse{StatusCode: 200, Err: io.ErrUnexpectedEOF, AudienceResponse: &AudienceResponse{BytesWritten: 10}}
+	mockClient = NewMockClient()
+	mockClient.Respond("AudienceCreate", &APIResponse{StatusCode: 200, AudienceResponse: &AudienceResponse{RequestId: "q1"}})
+	mockClient.Respond("AudienceDownloadTo", partial)
+	builder, _ = NewAudienceBuilder(mockClient, WithAudiencePollInterval(time.Millisecond, time.Millisecond))
+	_, err = builder.CreateTo(context.Background(), getTestAudienceRequest(), &bytes.Buffer{})
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	assert.Len(t, mockClient.CallsTo("AudienceDownloadTo"), 1)
+}
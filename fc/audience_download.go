@@ -0,0 +1,156 @@
+package fullcontact
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// audienceDownload is the destination of an audience file streamed by AudienceDownloadTo or AudienceDownloadToFile.
+type audienceDownload struct {
+	writer   io.Writer
+	fileName string
+	written  int64
+}
+
+func withAudienceDownload(download *audienceDownload) CallOption {
+	return func(co *callOptions) {
+		co.download = download
+	}
+}
+
+/*
+WithCallDownloadProgress reports the progress of AudienceDownloadTo and AudienceDownloadToFile,
+with the bytes received so far and the total size, -1 if unknown.
+*/
+func WithCallDownloadProgress(progress func(written, total int64)) CallOption {
+	return func(co *callOptions) {
+		co.downloadProgress = progress
+	}
+}
+
+/*
+WithCallDownloadTimeout bounds in millis the whole download of AudienceDownloadTo and
+AudienceDownloadToFile, including retries and reading the body. Downloads are exempt from the
+client timeout, which only bounds the wait for the response headers, so by default a download
+runs until it completes or the context of the request is cancelled.
+*/
+func WithCallDownloadTimeout(timeout int) CallOption {
+	return func(co *callOptions) {
+		co.downloadTimeoutMillis = timeout
+	}
+}
+
+// WithCallDecompression decompresses the gzip audience file of AudienceDownloadTo and AudienceDownloadToFile on the fly.
+func WithCallDecompression() CallOption {
+	return func(co *callOptions) {
+		co.decompress = true
+	}
+}
+
+type progressReader struct {
+	reader   io.Reader
+	read     int64
+	total    int64
+	progress func(written, total int64)
+}
+
+func (pr *progressReader) Read(p []byte) (int, error) {
+	n, err := pr.reader.Read(p)
+	pr.read += int64(n)
+	if n > 0 && pr.progress != nil {
+		pr.progress(pr.read, pr.total)
+	}
+	return n, err
+}
+
+// stream copies the body of the response to the destination and replaces it with an empty body.
+func (download *audienceDownload) stream(response *http.Response, co *callOptions) error {
+	defer func() {
+		response.Body.Close()
+		response.Body = http.NoBody
+	}()
+	if !isPopulated(download.fileName) {
+		return download.copy(download.writer, response, co)
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(download.fileName), "."+filepath.Base(download.fileName)+".tmp-")
+	if err != nil {
+		return err
+	}
+	err = download.copy(tmp, response, co)
+	if err == nil {
+		// Without syncing, the renamed file may be empty or truncated after a crash.
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), download.fileName)
+}
+
+// cancelOnClose releases the context of a download request once its body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (body cancelOnClose) Close() error {
+	err := body.ReadCloser.Close()
+	body.cancel()
+	return err
+}
+
+/*
+sendDownload executes a download request with co.headerTimeout bounding the wait for the response
+headers only, as the http.Client timeout would also bound reading the body.
+*/
+func sendDownload(req *http.Request, co *callOptions) (*http.Response, error) {
+	if co.headerTimeout <= 0 {
+		return co.httpClient.Do(req)
+	}
+	ctx, cancel := context.WithCancel(req.Context())
+	timer := time.AfterFunc(co.headerTimeout, cancel)
+	resp, err := co.httpClient.Do(req.WithContext(ctx))
+	if !timer.Stop() && err != nil {
+		err = fmt.Errorf("audience download timed out after %v waiting for the response: %w", co.headerTimeout, err)
+	}
+	if err != nil || resp == nil {
+		cancel()
+		return resp, err
+	}
+	resp.Body = cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+func (download *audienceDownload) copy(writer io.Writer, response *http.Response, co *callOptions) error {
+	body := &progressReader{reader: response.Body, total: response.ContentLength, progress: co.downloadProgress}
+	var reader io.Reader = body
+	if co.decompress {
+		gzipReader, err := gzip.NewReader(body)
+		if err != nil {
+			return err
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	}
+	var err error
+	download.written, err = io.Copy(writer, reader)
+	if err != nil {
+		return err
+	}
+	if body.total >= 0 && body.read != body.total {
+		return NewFullContactError(fmt.Sprintf("Audience download incomplete, received %d of %d bytes", body.read, body.total))
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package fullcontact
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	assert "github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const audienceJson = "[{\"recordId\":\"r1\"},{\"recordId\":\"r2\"}]"
+
+func gzipBytes(t *testing.T, data string) []byte {
+	var buffer bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buffer)
+	_, err := gzipWriter.Write([]byte(data))
+	assert.NoError(t, err)
+	assert.NoError(t, gzipWriter.Close())
+	return buffer.Bytes()
+}
+
+func getDownloadTestClient(t *testing.T, statusCode int, body []byte, contentLength int64) *fullContactClient {
+	transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		assert.Equal(t, audienceDownloadUrl+"?requestId=q1", req.URL.String())
+		resp := chaosResponse(req, statusCode, string(body))
+		if statusCode == 200 {
+			resp.Header.Set("Content-Type", "application/octet-stream")
+		}
+		resp.ContentLength = contentLength
+		return resp, nil
+	})
+	fcClient, err := NewFullContactClient(
+		WithCredentialsProvider(StaticCredentialsProvider{apiKey: "apikey"}),
+		WithHTTPClient(&http.Client{Transport: transport}))
+	assert.NoError(t, err)
+	return fcClient
+}
+
+func TestAudienceDownloadTo(t *testing.T) {
+	gzipped := gzipBytes(t, audienceJson)
+	fcClient := getDownloadTestClient(t, 200, gzipped, int64(len(gzipped)))
+
+	var buffer bytes.Buffer
+	var written, total int64
+	resp := <-fcClient.AudienceDownloadTo("q1", &buffer, WithCallDownloadProgress(func(w, t int64) {
+		written, total = w, t
+	}))
+	assert.NoError(t, resp.Err)
+	assert.True(t, resp.IsMatched())
+	assert.Equal(t, gzipped, buffer.Bytes())
+	assert.Equal(t, int64(len(gzipped)), resp.AudienceResponse.BytesWritten)
+	assert.Nil(t, resp.AudienceResponse.AudienceBytes)
+	assert.Equal(t, int64(len(gzipped)), written)
+	assert.Equal(t, int64(len(gzipped)), total)
+
+	buffer.Reset()
+	resp = <-fcClient.AudienceDownloadTo("q1", &buffer, WithCallDecompression())
+	assert.NoError(t, resp.Err)
+	assert.Equal(t, audienceJson, buffer.String())
+	assert.Equal(t, int64(len(audienceJson)), resp.AudienceResponse.BytesWritten)
+}
+
+func TestAudienceDownloadToFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fc-audience")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "audience.json")
+	gzipped := gzipBytes(t, audienceJson)
+
+	resp := <-getDownloadTestClient(t, 200, gzipped, -1).AudienceDownloadToFile("q1", fileName, WithCallDecompression())
+	assert.NoError(t, resp.Err)
+	content, err := ioutil.ReadFile(fileName)
+	assert.NoError(t, err)
+	assert.Equal(t, audienceJson, string(content))
+
+	resp = <-getDownloadTestClient(t, 200, gzipped, int64(len(gzipped)+10)).AudienceDownloadToFile("q1", filepath.Join(dir, "incomplete.json.gz"))
+	assert.EqualError(t, resp.Err, fmt.Sprintf("FullContactError: Audience download incomplete, received %d of %d bytes",
+		len(gzipped), len(gzipped)+10))
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+}
+
+func TestAudienceDownloadToNotReady(t *testing.T) {
+	var buffer bytes.Buffer
+	resp := <-getDownloadTestClient(t, 404, []byte("{\"status\":404,\"message\":\"Not Found\"}"), -1).AudienceDownloadTo("q1", &buffer)
+	assert.NoError(t, resp.Err)
+	assert.True(t, resp.IsNoMatch())
+	assert.Equal(t, 0, buffer.Len())
+
+	resp = <-getDownloadTestClient(t, 200, nil, -1).AudienceDownloadTo("q1", nil)
+	assert.EqualError(t, resp.Err, "FullContactError: Writer can't be nil")
+	resp = <-getDownloadTestClient(t, 200, nil, -1).AudienceDownloadToFile("", "audience.json")
+	assert.EqualError(t, resp.Err, "FullContactError: requestId can't be nil")
+}
+
+func getSlowDownloadTestServer(headerDelay, bodyDelay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(headerDelay)
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.WriteHeader(200)
+		for _, part := range []string{audienceJson[:10], audienceJson[10:]} {
+			_, _ = w.Write([]byte(part))
+			w.(http.Flusher).Flush()
+			select {
+			case <-time.After(bodyDelay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}))
+}
+
+func getSlowDownloadTestClient(t *testing.T, testServer *httptest.Server) *fullContactClient {
+	transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		req.URL.Scheme, req.URL.Host = "http", testServer.Listener.Addr().String()
+		return http.DefaultTransport.RoundTrip(req)
+	})
+	fcClient, err := NewFullContactClient(
+		WithCredentialsProvider(StaticCredentialsProvider{apiKey: "apikey"}),
+		WithHTTPClient(&http.Client{Transport: transport}),
+		WithRetryHandler(fastRetryHandler{}))
+	assert.NoError(t, err)
+	return fcClient
+}
+
+func TestAudienceDownloadExemptFromClientTimeout(t *testing.T) {
+	testServer := getSlowDownloadTestServer(0, 150*time.Millisecond)
+	defer testServer.Close()
+	fcClient := getSlowDownloadTestClient(t, testServer)
+
+	var buffer bytes.Buffer
+	resp := <-fcClient.AudienceDownloadTo("q1", &buffer, WithCallTimeout(100))
+	assert.NoError(t, resp.Err)
+	assert.Equal(t, audienceJson, buffer.String())
+
+	buffer.Reset()
+	resp = <-fcClient.AudienceDownloadTo("q1", &buffer, WithCallTimeout(100), WithCallDownloadTimeout(200))
+	assert.Error(t, resp.Err)
+	assert.True(t, resp.IsRetryable())
+
+	resp = <-fcClient.AudienceDownload("q1", WithCallTimeout(100))
+	assert.Error(t, resp.Err)
+}
+
+func TestAudienceDownloadHeaderTimeout(t *testing.T) {
+	testServer := getSlowDownloadTestServer(300*time.Millisecond, 0)
+	defer testServer.Close()
+
+	var buffer bytes.Buffer
+	resp := <-getSlowDownloadTestClient(t, testServer).AudienceDownloadTo("q1", &buffer, WithCallTimeout(100))
+	assert.Error(t, resp.Err)
+	assert.Contains(t, resp.Err.Error(), "audience download timed out after 100ms waiting for the response")
+	assert.Equal(t, 0, buffer.Len())
+}
+
+func TestAudienceDownloadToSharedOptions(t *testing.T) {
+	gzipped := gzipBytes(t, audienceJson)
+	fcClient := getDownloadTestClient(t, 200, gzipped, int64(len(gzipped)))
+	// spare capacity, which the calls must not append to
+	options := make([]CallOption, 0, 4)
+	options = append(options, WithCallDecompression())
+
+	var first, second bytes.Buffer
+	firstCh := fcClient.AudienceDownloadTo("q1", &first, options...)
+	secondCh := fcClient.AudienceDownloadTo("q1", &second, options...)
+	assert.NoError(t, (<-firstCh).Err)
+	assert.NoError(t, (<-secondCh).Err)
+	assert.Equal(t, audienceJson, first.String())
+	assert.Equal(t, audienceJson, second.String())
+}
@@ -0,0 +1,369 @@
+package fullcontact
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+/*
+AudienceRecord is a record of an audience file. RecordId, PersonIds and Tags are decoded, and every
+other field of the record, e.g. enrichment data, is kept in Fields.
+*/
+type AudienceRecord struct {
+	RecordId  string
+	PersonIds []string
+	Tags      []*Tag
+	Fields    map[string]json.RawMessage
+}
+
+func (record *AudienceRecord) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal(data, &fields)
+	if err != nil {
+		return err
+	}
+	if fields == nil {
+		return NewFullContactError("Audience record must be a JSON object")
+	}
+	known := map[string]interface{}{
+		"recordId":  &record.RecordId,
+		"personIds": &record.PersonIds,
+		"tags":      &record.Tags,
+	}
+	for name, value := range known {
+		if raw, ok := fields[name]; ok {
+			if err = json.Unmarshal(raw, value); err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+			delete(fields, name)
+		}
+	}
+	record.Fields = fields
+	return nil
+}
+
+func (record *AudienceRecord) MarshalJSON() ([]byte, error) {
+	fields := make(map[string]interface{}, len(record.Fields)+3)
+	for name, value := range record.Fields {
+		fields[name] = value
+	}
+	if isPopulated(record.RecordId) {
+		fields["recordId"] = record.RecordId
+	}
+	if record.PersonIds != nil {
+		fields["personIds"] = record.PersonIds
+	}
+	if record.Tags != nil {
+		fields["tags"] = record.Tags
+	}
+	return json.Marshal(fields)
+}
+
+// Field returns the value of a field of the record, unquoted for strings and as JSON otherwise.
+func (record *AudienceRecord) Field(name string) string {
+	raw, ok := record.Fields[name]
+	if !ok {
+		return ""
+	}
+	var value string
+	if json.Unmarshal(raw, &value) == nil {
+		return value
+	}
+	return string(raw)
+}
+
+// AudienceParseError reports a malformed record of an audience file.
+type AudienceParseError struct {
+	Line int
+	Err  error
+}
+
+func (err *AudienceParseError) Error() string {
+	return fmt.Sprintf("FullContactError: Malformed audience record at line %d: %v", err.Line, err.Err)
+}
+
+type AudienceReaderOption func(ar *AudienceReader)
+
+/*
+WithSkipMalformedRecords skips malformed records instead of stopping, they are reported by Malformed.
+In a JSON array, only the records which are valid JSON but not audience records can be skipped: the
+array can't be read past invalid JSON, which stops the iteration with an error.
+*/
+func WithSkipMalformedRecords() AudienceReaderOption {
+	return func(ar *AudienceReader) {
+		ar.skipMalformed = true
+	}
+}
+
+// WithAudienceRecordFilter only yields the records for which filter returns true, e.g. TagExpression.MatchesRecord.
+func WithAudienceRecordFilter(filter func(record *AudienceRecord) bool) AudienceReaderOption {
+	return func(ar *AudienceReader) {
+		ar.filter = filter
+	}
+}
+
+/*
+AudienceReader iterates over the records of an audience file, one at a time, without reading the
+whole file in memory:
+
+	for reader.Next() {
+		record := reader.Record()
+	}
+	if reader.Err() != nil { ... }
+
+The file may be gzip compressed or not, and hold a JSON array of records or one record per line (JSONL).
+*/
+type AudienceReader struct {
+	reader        *bufio.Reader
+	closers       []io.Closer
+	skipMalformed bool
+	filter        func(record *AudienceRecord) bool
+	array         bool
+	decoder       *json.Decoder
+	lines         *lineTracker
+	line          int
+	record        *AudienceRecord
+	err           error
+	malformed     []*AudienceParseError
+}
+
+func NewAudienceReader(reader io.Reader, options ...AudienceReaderOption) (*AudienceReader, error) {
+	ar := &AudienceReader{}
+	for _, opts := range options {
+		opts(ar)
+	}
+
+	buffered := bufio.NewReader(reader)
+	magic, err := buffered.Peek(2)
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gzipReader, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, err
+		}
+		ar.closers = append(ar.closers, gzipReader)
+		buffered = bufio.NewReader(gzipReader)
+	}
+	ar.reader = buffered
+
+	// Leading blank lines are skipped to find out the format, and counted
+	for {
+		c, err := ar.reader.ReadByte()
+		if err == io.EOF {
+			return ar, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if c == '\n' {
+			ar.line++
+		}
+		if !isJSONSpace(c) {
+			_ = ar.reader.UnreadByte()
+			ar.array = c == '['
+			break
+		}
+	}
+	if ar.array {
+		ar.lines = &lineTracker{reader: ar.reader, lines: ar.line}
+		ar.decoder = json.NewDecoder(ar.lines)
+		if _, err = ar.decoder.Token(); err != nil {
+			return nil, err
+		}
+	}
+	return ar, nil
+}
+
+// OpenAudienceFile opens an audience file, e.g. written by AudienceDownloadToFile. The reader must be closed.
+func OpenAudienceFile(fileName string, options ...AudienceReaderOption) (*AudienceReader, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	ar, err := NewAudienceReader(file, options...)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	ar.closers = append(ar.closers, file)
+	return ar, nil
+}
+
+func (ar *AudienceReader) Close() error {
+	var err error
+	for _, closer := range ar.closers {
+		if closeErr := closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	ar.closers = nil
+	return err
+}
+
+// Next advances to the next record and returns false at the end of the file or on error.
+func (ar *AudienceReader) Next() bool {
+	for ar.err == nil && ar.reader != nil {
+		raw, line, err := ar.nextRaw()
+		if err == io.EOF {
+			ar.reader = nil
+			return false
+		}
+		if err != nil {
+			ar.err = err
+			return false
+		}
+		var record AudienceRecord
+		if err = json.Unmarshal(raw, &record); err != nil {
+			parseErr := &AudienceParseError{Line: line, Err: err}
+			if !ar.skipMalformed {
+				ar.err = parseErr
+				return false
+			}
+			ar.malformed = append(ar.malformed, parseErr)
+			continue
+		}
+		if ar.filter != nil && !ar.filter(&record) {
+			continue
+		}
+		ar.record = &record
+		return true
+	}
+	return false
+}
+
+func (ar *AudienceReader) Record() *AudienceRecord {
+	return ar.record
+}
+
+// Err returns the error which stopped the iteration, nil at the end of the file.
+func (ar *AudienceReader) Err() error {
+	return ar.err
+}
+
+// Malformed returns the malformed records skipped with WithSkipMalformedRecords.
+func (ar *AudienceReader) Malformed() []*AudienceParseError {
+	return ar.malformed
+}
+
+func (ar *AudienceReader) nextRaw() ([]byte, int, error) {
+	if ar.array {
+		if !ar.decoder.More() {
+			_, err := ar.decoder.Token()
+			if err != nil {
+				return nil, 0, &AudienceParseError{Line: ar.lines.lineAt(ar.decoder.InputOffset()), Err: err}
+			}
+			return nil, 0, io.EOF
+		}
+		var raw json.RawMessage
+		if err := ar.decoder.Decode(&raw); err != nil {
+			offset := ar.decoder.InputOffset()
+			if syntaxErr, ok := err.(*json.SyntaxError); ok {
+				offset = syntaxErr.Offset - 1
+			}
+			return nil, 0, &AudienceParseError{Line: ar.lines.lineAt(offset), Err: err}
+		}
+		return raw, ar.lines.lineAt(ar.decoder.InputOffset() - int64(len(raw))), nil
+	}
+
+	for {
+		data, err := ar.reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, 0, err
+		}
+		if len(data) == 0 && err == io.EOF {
+			return nil, 0, io.EOF
+		}
+		ar.line++
+		data = bytes.TrimSpace(data)
+		if len(data) > 0 {
+			return data, ar.line, nil
+		}
+	}
+}
+
+// WriteJSONL writes the remaining records as JSON lines and returns how many were written.
+func (ar *AudienceReader) WriteJSONL(writer io.Writer) (int, error) {
+	encoder := json.NewEncoder(writer)
+	count := 0
+	for ar.Next() {
+		if err := encoder.Encode(ar.Record()); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, ar.Err()
+}
+
+/*
+WriteCSV writes the remaining records as CSV and returns how many were written. The columns are
+recordId, personIds and tags, followed by the fields given. PersonIds and tags ("key:value") are
+separated by "|".
+*/
+func (ar *AudienceReader) WriteCSV(writer io.Writer, fields ...string) (int, error) {
+	csvWriter := csv.NewWriter(writer)
+	header := append([]string{"recordId", "personIds", "tags"}, fields...)
+	if err := csvWriter.Write(header); err != nil {
+		return 0, err
+	}
+	count := 0
+	for ar.Next() {
+		record := ar.Record()
+		tags := make([]string, len(record.Tags))
+		for i, tag := range record.Tags {
+			tags[i] = tag.Key + ":" + tag.Value
+		}
+		row := []string{record.RecordId, strings.Join(record.PersonIds, "|"), strings.Join(tags, "|")}
+		for _, field := range fields {
+			row = append(row, record.Field(field))
+		}
+		if err := csvWriter.Write(row); err != nil {
+			return count, err
+		}
+		count++
+	}
+	csvWriter.Flush()
+	if err := csvWriter.Error(); err != nil {
+		return count, err
+	}
+	return count, ar.Err()
+}
+
+func isJSONSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+// lineTracker counts the lines of the bytes read through it, to find the line of an offset.
+// Offsets must be looked up in increasing order.
+type lineTracker struct {
+	reader   io.Reader
+	read     int64
+	lines    int
+	newlines []int64
+}
+
+func (lt *lineTracker) Read(p []byte) (int, error) {
+	n, err := lt.reader.Read(p)
+	for i := 0; i < n; i++ {
+		if p[i] == '\n' {
+			lt.newlines = append(lt.newlines, lt.read+int64(i))
+		}
+	}
+	lt.read += int64(n)
+	return n, err
+}
+
+func (lt *lineTracker) lineAt(offset int64) int {
+	i := 0
+	for i < len(lt.newlines) && lt.newlines[i] < offset {
+		i++
+	}
+	lt.lines += i
+	lt.newlines = lt.newlines[i:]
+	return lt.lines + 1
+}
@@ -0,0 +1,118 @@
+package fullcontact
+
+import (
+	"bytes"
+	assert "github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const audienceJsonl = `{"recordId":"r1","personIds":["p1"],"tags":[{"key":"segment","value":"vip"}],"fullName":"Marquita H Ross","age":34}
+
+{"recordId":"r2","personIds":["p2","p3"]}
+`
+
+func readAudience(t *testing.T, reader *AudienceReader) []*AudienceRecord {
+	records := make([]*AudienceRecord, 0)
+	for reader.Next() {
+		records = append(records, reader.Record())
+	}
+	return records
+}
+
+func TestAudienceReaderJSONL(t *testing.T) {
+	reader, err := NewAudienceReader(bytes.NewReader(gzipBytes(t, audienceJsonl)))
+	assert.NoError(t, err)
+	records := readAudience(t, reader)
+	assert.NoError(t, reader.Err())
+	assert.Len(t, records, 2)
+	assert.Equal(t, "r1", records[0].RecordId)
+	assert.Equal(t, []string{"p1"}, records[0].PersonIds)
+	assert.Equal(t, []*Tag{{Key: "segment", Value: "vip"}}, records[0].Tags)
+	assert.Equal(t, "Marquita H Ross", records[0].Field("fullName"))
+	assert.Equal(t, "34", records[0].Field("age"))
+	assert.Equal(t, []string{"p2", "p3"}, records[1].PersonIds)
+	assert.NoError(t, reader.Close())
+}
+
+func TestAudienceReaderJSONArray(t *testing.T) {
+	reader, err := NewAudienceReader(strings.NewReader("\n[\n  {\"recordId\":\"r1\"},\n  {\"recordId\":\"r2\"}\n]\n"))
+	assert.NoError(t, err)
+	records := readAudience(t, reader)
+	assert.NoError(t, reader.Err())
+	assert.Len(t, records, 2)
+	assert.Equal(t, "r2", records[1].RecordId)
+
+	reader, _ = NewAudienceReader(strings.NewReader("[\n{\"recordId\":\"r1\"},\n{\"recordId\":\"r2\",\n\"personIds\":x}\n]"))
+	assert.Len(t, readAudience(t, reader), 1)
+	assert.EqualError(t, reader.Err(), "FullContactError: Malformed audience record at line 4: invalid character 'x' looking for beginning of value")
+
+	reader, _ = NewAudienceReader(strings.NewReader("[\n{\"recordId\":\"r1\"},\n{\"recordId\":2}\n]"))
+	assert.Len(t, readAudience(t, reader), 1)
+	assert.Equal(t, 3, reader.Err().(*AudienceParseError).Line)
+}
+
+func TestAudienceReaderJSONArraySkipsMalformedRecords(t *testing.T) {
+	input := "[\n{\"recordId\":2},\n{\"recordId\":\"r2\"},\n{\"recordId\":x},\n{\"recordId\":\"r4\"}\n]"
+	reader, _ := NewAudienceReader(strings.NewReader(input), WithSkipMalformedRecords())
+	records := readAudience(t, reader)
+	assert.Len(t, records, 1)
+	assert.Equal(t, "r2", records[0].RecordId)
+	assert.Len(t, reader.Malformed(), 1)
+	assert.Equal(t, 2, reader.Malformed()[0].Line)
+	// invalid JSON can't be skipped in an array
+	assert.EqualError(t, reader.Err(), "FullContactError: Malformed audience record at line 4: invalid character 'x' looking for beginning of value")
+}
+
+func TestAudienceReaderMalformedLines(t *testing.T) {
+	input := "{\"recordId\":\"r1\"}\n{\"recordId\":\n[]\n{\"recordId\":\"r4\"}\n"
+	reader, _ := NewAudienceReader(strings.NewReader(input))
+	assert.Len(t, readAudience(t, reader), 1)
+	assert.EqualError(t, reader.Err(), "FullContactError: Malformed audience record at line 2: unexpected end of JSON input")
+
+	reader, _ = NewAudienceReader(strings.NewReader(input), WithSkipMalformedRecords())
+	records := readAudience(t, reader)
+	assert.NoError(t, reader.Err())
+	assert.Len(t, records, 2)
+	assert.Equal(t, "r4", records[1].RecordId)
+	assert.Len(t, reader.Malformed(), 2)
+	assert.Equal(t, 3, reader.Malformed()[1].Line)
+}
+
+func TestAudienceReaderExport(t *testing.T) {
+	reader, _ := NewAudienceReader(strings.NewReader(audienceJsonl))
+	var buffer bytes.Buffer
+	count, err := reader.WriteCSV(&buffer, "fullName", "age")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, "recordId,personIds,tags,fullName,age\n"+
+		"r1,p1,segment:vip,Marquita H Ross,34\n"+
+		"r2,p2|p3,,,\n", buffer.String())
+
+	reader, _ = NewAudienceReader(strings.NewReader(audienceJsonl))
+	buffer.Reset()
+	count, err = reader.WriteJSONL(&buffer)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, "{\"age\":34,\"fullName\":\"Marquita H Ross\",\"personIds\":[\"p1\"],\"recordId\":\"r1\",\"tags\":[{\"key\":\"segment\",\"value\":\"vip\"}]}\n"+
+		"{\"personIds\":[\"p2\",\"p3\"],\"recordId\":\"r2\"}\n", buffer.String())
+}
+
+func TestOpenAudienceFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fc-audience")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "audience.json.gz")
+	assert.NoError(t, ioutil.WriteFile(fileName, gzipBytes(t, audienceJsonl), 0600))
+
+	reader, err := OpenAudienceFile(fileName)
+	assert.NoError(t, err)
+	assert.Len(t, readAudience(t, reader), 2)
+	assert.NoError(t, reader.Close())
+
+	_, err = OpenAudienceFile(filepath.Join(dir, "missing.json.gz"))
+	assert.Error(t, err)
+}
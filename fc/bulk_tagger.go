@@ -0,0 +1,415 @@
+package fullcontact
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// BulkTagItem holds the tags to add to and remove from a single record.
+type BulkTagItem struct {
+	RecordId string
+	Add      []*Tag
+	Remove   []*Tag
+}
+
+type BulkTagStatus string
+
+const (
+	BulkTagSucceeded BulkTagStatus = "succeeded"
+	BulkTagFailed    BulkTagStatus = "failed"
+	// BulkTagSkipped means the item was already applied by a previous run of the same job.
+	BulkTagSkipped BulkTagStatus = "skipped"
+)
+
+// BulkTagResult is the result of tagging a single record.
+type BulkTagResult struct {
+	Item   *BulkTagItem
+	Status BulkTagStatus
+	Err    error
+}
+
+// BulkTagReport holds the results of a bulk tagging run, in the order of the items.
+type BulkTagReport struct {
+	Results   []*BulkTagResult
+	Succeeded int
+	Failed    int
+	Skipped   int
+}
+
+// FailedItems returns the items which failed, to run them again.
+func (report *BulkTagReport) FailedItems() []*BulkTagItem {
+	var items []*BulkTagItem
+	for _, result := range report.Results {
+		if result.Status == BulkTagFailed {
+			items = append(items, result.Item)
+		}
+	}
+	return items
+}
+
+// WriteCSV writes a line per record with its recordId, status and error.
+func (report *BulkTagReport) WriteCSV(w io.Writer) error {
+	csvWriter := csv.NewWriter(w)
+	err := csvWriter.Write([]string{"recordId", "status", "error"})
+	for _, result := range report.Results {
+		if err != nil {
+			return err
+		}
+		message := ""
+		if result.Err != nil {
+			message = result.Err.Error()
+		}
+		err = csvWriter.Write([]string{result.Item.RecordId, string(result.Status), message})
+	}
+	if err != nil {
+		return err
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+type BulkTaggerOption func(bt *BulkTagger)
+
+/*
+BulkTagger adds and removes tags of many records, with a TagsCreate and a TagsDelete call per record.
+Calls are made concurrently, limited to a request rate, and retried by the RetryHandler of the client
+or the one set with WithBulkTagRetryHandler.
+
+With WithBulkTagJob, the items applied are recorded in a Store under the job id, so running the job
+again, e.g. after a crash or to retry the failures, skips the records already tagged.
+*/
+type BulkTagger struct {
+	client       Tagger
+	concurrency  int
+	limiter      *rateLimiter
+	retryHandler RetryHandler
+	store        Store
+	jobId        string
+}
+
+func NewBulkTagger(client Tagger, options ...BulkTaggerOption) (*BulkTagger, error) {
+	if client == nil {
+		return nil, NewFullContactError("Client can't be nil")
+	}
+	bt := &BulkTagger{client: client, concurrency: 4}
+	for _, opts := range options {
+		opts(bt)
+	}
+	if bt.concurrency < 1 {
+		bt.concurrency = 1
+	}
+	if bt.store != nil && !isPopulated(bt.jobId) {
+		return nil, NewFullContactError("Job id must be present for bulk tagging with a Store")
+	}
+	return bt, nil
+}
+
+// WithBulkTagConcurrency sets how many records are tagged at once, 4 by default.
+func WithBulkTagConcurrency(concurrency int) BulkTaggerOption {
+	return func(bt *BulkTagger) {
+		bt.concurrency = concurrency
+	}
+}
+
+// WithBulkTagRateLimit limits the calls to requestsPerSecond with bursts of up to burst calls.
+func WithBulkTagRateLimit(requestsPerSecond float64, burst int) BulkTaggerOption {
+	return func(bt *BulkTagger) {
+		bt.limiter = newRateLimiter(requestsPerSecond, burst)
+	}
+}
+
+func WithBulkTagRetryHandler(retryHandler RetryHandler) BulkTaggerOption {
+	return func(bt *BulkTagger) {
+		bt.retryHandler = retryHandler
+	}
+}
+
+// WithBulkTagJob records the items applied by the job in the store, to skip them when the job runs again.
+func WithBulkTagJob(store Store, jobId string) BulkTaggerOption {
+	return func(bt *BulkTagger) {
+		bt.store = store
+		bt.jobId = jobId
+	}
+}
+
+// TagRecords adds and removes the same tags for every record.
+func (bt *BulkTagger) TagRecords(ctx context.Context, recordIds []string, add []*Tag, remove []*Tag, options ...CallOption) *BulkTagReport {
+	items := make([]*BulkTagItem, len(recordIds))
+	for i, recordId := range recordIds {
+		items[i] = &BulkTagItem{RecordId: recordId, Add: add, Remove: remove}
+	}
+	return bt.RunAll(ctx, items, options...)
+}
+
+func (bt *BulkTagger) RunAll(ctx context.Context, items []*BulkTagItem, options ...CallOption) *BulkTagReport {
+	ch := make(chan *BulkTagItem)
+	go func() {
+		defer close(ch)
+		for _, item := range items {
+			select {
+			case ch <- item:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return bt.Run(ctx, ch, options...)
+}
+
+/*
+RunCSV tags the records of a CSV with a header line. The recordId column holds the record ids, and
+the optional add and remove columns the tags, as key:value separated by "|", e.g.
+
+	recordId,add,remove
+	r1,segment:vip|region:us,segment:churned
+
+Malformed lines are reported as failed items.
+*/
+func (bt *BulkTagger) RunCSV(ctx context.Context, r io.Reader, options ...CallOption) (*BulkTagReport, error) {
+	csvReader := csv.NewReader(r)
+	csvReader.FieldsPerRecord = -1
+	header, err := csvReader.Read()
+	if err != nil {
+		return nil, NewFullContactError("Unable to read bulk tag CSV header: " + err.Error())
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	if _, ok := columns["recordId"]; !ok {
+		return nil, NewFullContactError("Bulk tag CSV must have a recordId column")
+	}
+
+	ch := make(chan *BulkTagItem)
+	lineErrors := make(map[*BulkTagItem]error)
+	var mutex sync.Mutex
+	go func() {
+		defer close(ch)
+		for line := 2; ; line++ {
+			row, err := csvReader.Read()
+			if err == io.EOF {
+				return
+			}
+			_, parseError := err.(*csv.ParseError)
+			readError := err != nil && !parseError
+			item := &BulkTagItem{}
+			if err == nil {
+				item, err = bulkTagItemFromRow(row, columns)
+			}
+			if err != nil {
+				mutex.Lock()
+				lineErrors[item] = NewFullContactError(fmt.Sprintf("Malformed bulk tag CSV line %d: %v", line, err))
+				mutex.Unlock()
+			}
+			select {
+			case ch <- item:
+			case <-ctx.Done():
+				return
+			}
+			if readError {
+				// the reader failed, no further line can be read
+				return
+			}
+		}
+	}()
+	return bt.run(ctx, ch, func(item *BulkTagItem) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return lineErrors[item]
+	}, options...), nil
+}
+
+func bulkTagItemFromRow(row []string, columns map[string]int) (*BulkTagItem, error) {
+	column := func(name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+	item := &BulkTagItem{RecordId: column("recordId")}
+	var err error
+	if item.Add, err = parseBulkTags(column("add")); err != nil {
+		return item, err
+	}
+	item.Remove, err = parseBulkTags(column("remove"))
+	return item, err
+}
+
+func parseBulkTags(value string) ([]*Tag, error) {
+	if !isPopulated(value) {
+		return nil, nil
+	}
+	var tags []*Tag
+	for _, keyValue := range strings.Split(value, "|") {
+		parts := strings.SplitN(keyValue, ":", 2)
+		if len(parts) != 2 || !isPopulated(parts[0]) || !isPopulated(parts[1]) {
+			return nil, fmt.Errorf("invalid tag %q", keyValue)
+		}
+		tags = append(tags, &Tag{Key: parts[0], Value: parts[1]})
+	}
+	return tags, nil
+}
+
+/*
+Run tags the records of the items received until the channel is closed or the context is done.
+Items not received before the context is done are missing from the report.
+*/
+func (bt *BulkTagger) Run(ctx context.Context, items <-chan *BulkTagItem, options ...CallOption) *BulkTagReport {
+	return bt.run(ctx, items, nil, options...)
+}
+
+type indexedBulkTagItem struct {
+	index int
+	item  *BulkTagItem
+}
+
+func (bt *BulkTagger) run(ctx context.Context, items <-chan *BulkTagItem, itemError func(item *BulkTagItem) error, options ...CallOption) *BulkTagReport {
+	options = append([]CallOption{WithCallContext(ctx)}, options...)
+	if bt.retryHandler != nil {
+		options = append(options, WithCallRetryHandler(bt.retryHandler))
+	}
+
+	var mutex sync.Mutex
+	var results []*BulkTagResult
+	indexes := make(map[*BulkTagResult]int)
+	indexed := make(chan indexedBulkTagItem)
+	var wg sync.WaitGroup
+	for w := 0; w < bt.concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for next := range indexed {
+				var result *BulkTagResult
+				if itemError != nil {
+					if err := itemError(next.item); err != nil {
+						result = &BulkTagResult{Item: next.item, Status: BulkTagFailed, Err: err}
+					}
+				}
+				if result == nil {
+					result = bt.tag(ctx, next.item, options)
+				}
+				mutex.Lock()
+				results = append(results, result)
+				indexes[result] = next.index
+				mutex.Unlock()
+			}
+		}()
+	}
+	index := 0
+	for item := range items {
+		if ctx.Err() != nil {
+			break
+		}
+		indexed <- indexedBulkTagItem{index: index, item: item}
+		index++
+	}
+	close(indexed)
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		return indexes[results[i]] < indexes[results[j]]
+	})
+	report := &BulkTagReport{Results: results}
+	for _, result := range results {
+		switch result.Status {
+		case BulkTagSucceeded:
+			report.Succeeded++
+		case BulkTagFailed:
+			report.Failed++
+		case BulkTagSkipped:
+			report.Skipped++
+		}
+	}
+	return report
+}
+
+func (bt *BulkTagger) tag(ctx context.Context, item *BulkTagItem, options []CallOption) *BulkTagResult {
+	result := &BulkTagResult{Item: item, Status: BulkTagFailed}
+	if !isPopulated(item.RecordId) {
+		result.Err = NewFullContactError("RecordId must be present for bulk tagging")
+		return result
+	}
+	if len(item.Add) == 0 && len(item.Remove) == 0 {
+		result.Err = NewFullContactError("Tags to add or remove must be present for record: " + item.RecordId)
+		return result
+	}
+	for _, tag := range append(append([]*Tag(nil), item.Add...), item.Remove...) {
+		if tag == nil {
+			result.Err = NewFullContactError("Tags to add or remove can't be nil for record: " + item.RecordId)
+			return result
+		}
+	}
+
+	key, done, err := bt.applied(item)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	if done {
+		result.Status = BulkTagSkipped
+		return result
+	}
+
+	if len(item.Add) > 0 {
+		tagsRequest, err := NewTagsRequest(WithRecordIdForTags(item.RecordId), WithTags(item.Add))
+		if err == nil {
+			err = bt.limiter.wait(ctx)
+		}
+		if err == nil {
+			err = tagsCallError(<-bt.client.TagsCreate(tagsRequest, options...), "create", item.RecordId)
+		}
+		if err != nil {
+			result.Err = err
+			return result
+		}
+	}
+	if len(item.Remove) > 0 {
+		tagsRequest := &TagsRequest{RecordId: item.RecordId, Tags: item.Remove}
+		err := bt.limiter.wait(ctx)
+		if err == nil {
+			err = tagsCallError(<-bt.client.TagsDelete(tagsRequest, options...), "delete", item.RecordId)
+		}
+		if err != nil {
+			result.Err = err
+			return result
+		}
+	}
+
+	if bt.store != nil {
+		if err := bt.store.Save(key, []byte(bulkTagItemDigest(item))); err != nil {
+			result.Err = err
+			return result
+		}
+	}
+	result.Status = BulkTagSucceeded
+	return result
+}
+
+// applied returns whether the same changes were already applied to the record by a previous run of the job.
+func (bt *BulkTagger) applied(item *BulkTagItem) (string, bool, error) {
+	if bt.store == nil {
+		return "", false, nil
+	}
+	key := "bulk-tag/" + bt.jobId + "/" + item.RecordId
+	value, err := bt.store.Load(key)
+	if err != nil {
+		return "", false, err
+	}
+	return key, value != nil && string(value) == bulkTagItemDigest(item), nil
+}
+
+// bulkTagItemDigest returns the changes of the item in a canonical form, e.g. "r1: +region:us -segment:vip".
+func bulkTagItemDigest(item *BulkTagItem) string {
+	plan := &TagPlan{RecordId: item.RecordId}
+	plan.Add = append(plan.Add, item.Add...)
+	plan.Remove = append(plan.Remove, item.Remove...)
+	sortTags(plan.Add)
+	sortTags(plan.Remove)
+	return plan.String()
+}
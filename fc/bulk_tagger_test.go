@@ -0,0 +1,124 @@
+package fullcontact
+
+import (
+	"bytes"
+	"context"
+	assert "github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func getBulkTaggerTestClient(failing map[string]int) *MockClient {
+	mockClient := NewMockClient()
+	mockClient.On("TagsCreate", func(args ...interface{}) *APIResponse {
+		if statusCode, ok := failing[args[0].(*TagsRequest).RecordId]; ok {
+			return &APIResponse{StatusCode: statusCode}
+		}
+		return &APIResponse{StatusCode: 200}
+	})
+	mockClient.Respond("TagsDelete", &APIResponse{StatusCode: 204})
+	return mockClient
+}
+
+func TestBulkTaggerTagRecords(t *testing.T) {
+	mockClient := getBulkTaggerTestClient(map[string]int{"r2": 400})
+	bulkTagger, err := NewBulkTagger(mockClient, WithBulkTagConcurrency(2), WithBulkTagRateLimit(1000, 10))
+	assert.NoError(t, err)
+
+	add := []*Tag{{Key: "campaign", Value: "fall"}}
+	remove := []*Tag{{Key: "campaign", Value: "summer"}}
+	report := bulkTagger.TagRecords(context.Background(), []string{"r1", "r2", "r3", ""}, add, remove)
+	assert.Equal(t, 2, report.Succeeded)
+	assert.Equal(t, 2, report.Failed)
+	assert.Len(t, report.Results, 4)
+	assert.Equal(t, "r1", report.Results[0].Item.RecordId)
+	assert.Equal(t, BulkTagSucceeded, report.Results[0].Status)
+	assert.EqualError(t, report.Results[1].Err, "FullContactError: Tags create failed for record r2 with status 400")
+	assert.EqualError(t, report.Results[3].Err, "FullContactError: RecordId must be present for bulk tagging")
+	assert.Len(t, mockClient.CallsTo("TagsCreate"), 3)
+	assert.Len(t, mockClient.CallsTo("TagsDelete"), 2)
+
+	failed := report.FailedItems()
+	assert.Len(t, failed, 2)
+	assert.Equal(t, "r2", failed[0].RecordId)
+}
+
+func TestBulkTaggerJobRerun(t *testing.T) {
+	store := NewMemoryStore()
+	failing := map[string]int{"r2": 503}
+	mockClient := getBulkTaggerTestClient(failing)
+	bulkTagger, err := NewBulkTagger(mockClient, WithBulkTagJob(store, "fall-campaign"))
+	assert.NoError(t, err)
+
+	add := []*Tag{{Key: "campaign", Value: "fall"}}
+	report := bulkTagger.TagRecords(context.Background(), []string{"r1", "r2"}, add, nil)
+	assert.Equal(t, 1, report.Succeeded)
+	assert.Equal(t, 1, report.Failed)
+
+	delete(failing, "r2")
+	report = bulkTagger.TagRecords(context.Background(), []string{"r1", "r2"}, add, nil)
+	assert.Equal(t, 1, report.Skipped)
+	assert.Equal(t, 1, report.Succeeded)
+	assert.Equal(t, BulkTagSkipped, report.Results[0].Status)
+	assert.Len(t, mockClient.CallsTo("TagsCreate"), 3)
+
+	report = bulkTagger.TagRecords(context.Background(), []string{"r1"}, []*Tag{{Key: "campaign", Value: "winter"}}, nil)
+	assert.Equal(t, 1, report.Succeeded)
+}
+
+func TestNewBulkTaggerJobIdMissing(t *testing.T) {
+	_, err := NewBulkTagger(NewMockClient(), WithBulkTagJob(NewMemoryStore(), ""))
+	assert.EqualError(t, err, "FullContactError: Job id must be present for bulk tagging with a Store")
+}
+
+func TestBulkTaggerRunCSV(t *testing.T) {
+	mockClient := getBulkTaggerTestClient(nil)
+	bulkTagger, err := NewBulkTagger(mockClient)
+	assert.NoError(t, err)
+
+	report, err := bulkTagger.RunCSV(context.Background(), strings.NewReader(
+		"recordId,add,remove\n"+
+			"r1,segment:vip|region:us,segment:churned\n"+
+			"r2,segment,\n"+
+			"r3,,region:us\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, report.Succeeded)
+	assert.Equal(t, 1, report.Failed)
+	assert.EqualError(t, report.Results[1].Err, `FullContactError: Malformed bulk tag CSV line 3: invalid tag "segment"`)
+
+	created := mockClient.CallsTo("TagsCreate")[0].Args[0].(*TagsRequest)
+	assert.Equal(t, []*Tag{{Key: "segment", Value: "vip"}, {Key: "region", Value: "us"}}, created.Tags)
+	assert.Len(t, mockClient.CallsTo("TagsDelete"), 2)
+
+	var buffer bytes.Buffer
+	assert.NoError(t, report.WriteCSV(&buffer))
+	assert.Equal(t, "recordId,status,error\n"+
+		"r1,succeeded,\n"+
+		"r2,failed,\"FullContactError: Malformed bulk tag CSV line 3: invalid tag \"\"segment\"\"\"\n"+
+		"r3,succeeded,\n", buffer.String())
+}
+
+func TestBulkTaggerRunCSVWithoutRecordId(t *testing.T) {
+	bulkTagger, err := NewBulkTagger(NewMockClient())
+	assert.NoError(t, err)
+	_, err = bulkTagger.RunCSV(context.Background(), strings.NewReader("id,add\nr1,segment:vip\n"))
+	assert.EqualError(t, err, "FullContactError: Bulk tag CSV must have a recordId column")
+}
+
+func TestBulkTaggerNilTags(t *testing.T) {
+	mockClient := getBulkTaggerTestClient(nil)
+	bulkTagger, _ := NewBulkTagger(mockClient)
+	items := make(chan *BulkTagItem, 3)
+	items <- &BulkTagItem{RecordId: "r1", Add: []*Tag{nil}}
+	items <- &BulkTagItem{RecordId: "r2", Remove: []*Tag{{Key: "campaign", Value: "fall"}, nil}}
+	items <- &BulkTagItem{RecordId: "r3", Add: []*Tag{{Key: "campaign", Value: "fall"}}}
+	close(items)
+
+	report := bulkTagger.Run(context.Background(), items)
+	assert.Equal(t, 1, report.Succeeded)
+	assert.Equal(t, 2, report.Failed)
+	assert.EqualError(t, report.Results[0].Err, "FullContactError: Tags to add or remove can't be nil for record: r1")
+	assert.EqualError(t, report.Results[1].Err, "FullContactError: Tags to add or remove can't be nil for record: r2")
+	assert.Len(t, mockClient.CallsTo("TagsCreate"), 1)
+	assert.Empty(t, mockClient.CallsTo("TagsDelete"))
+}
@@ -0,0 +1,28 @@
+package fullcontact
+
+// CallRecord describes a request completed by the client.
+type CallRecord struct {
+	// Endpoint is the name of the API endpoint, e.g. "person.enrich".
+	Endpoint string
+	// Request is the request sent to the API, JSON for POST requests and the query for GET requests.
+	Request  []byte
+	Response *APIResponse
+	// reservedEndpoint is the endpoint of the UsageLedger call slot held by the request, if any
+	reservedEndpoint string
+}
+
+/*
+CallObserver is notified of every request completed by the client, after all retries and before
+the response is sent to the channel. ObserveCall is called from the goroutine of the request,
+so implementations must be safe for concurrent use and should return quickly.
+*/
+type CallObserver interface {
+	ObserveCall(call *CallRecord)
+}
+
+// CallObserverFunc adapts a function to a CallObserver.
+type CallObserverFunc func(call *CallRecord)
+
+func (fn CallObserverFunc) ObserveCall(call *CallRecord) {
+	fn(call)
+}
@@ -0,0 +1,117 @@
+package fullcontact
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+/*
+CallOption overrides the client configuration for a single request, e.g.
+
+	fcClient.PersonEnrich(personRequest, fc.WithCallTimeout(500))
+*/
+type CallOption func(co *callOptions)
+
+type callOptions struct {
+	ctx                 context.Context
+	cancel              context.CancelFunc
+	timeoutMillis       int
+	headers             map[string]string
+	retryHandler        RetryHandler
+	credentialsProvider CredentialsProvider
+	httpClient          *http.Client
+	download            *audienceDownload
+	downloadProgress    func(written, total int64)
+	decompress          bool
+	// headerTimeout bounds the wait for the response headers of downloads, which are exempt from the client timeout.
+	headerTimeout         time.Duration
+	downloadTimeoutMillis int
+	// authFailedOver is set once a 401 or 403 was retried with another API key.
+	authFailedOver bool
+	// usageReserved is the endpoint of the call slot reserved in the UsageLedger, released once observed.
+	usageReserved string
+}
+
+// newCallOptions starts from the client configuration and applies the options of a single request.
+func (fcClient *fullContactClient) newCallOptions(options []CallOption) *callOptions {
+	co := &callOptions{
+		ctx:                 context.Background(),
+		headers:             make(map[string]string),
+		retryHandler:        fcClient.retryHandler,
+		credentialsProvider: fcClient.credentialsProvider,
+		httpClient:          fcClient.httpClient,
+	}
+	for k, v := range fcClient.headers {
+		co.headers[k] = v
+	}
+
+	for _, opts := range options {
+		opts(co)
+	}
+
+	if co.ctx == nil {
+		co.ctx = context.Background()
+	}
+	if co.timeoutMillis > 0 {
+		httpClient := *co.httpClient
+		httpClient.Timeout = time.Duration(co.timeoutMillis) * time.Millisecond
+		co.httpClient = &httpClient
+	}
+	if co.download != nil {
+		// The client timeout includes reading the body, which would abort the download of any large audience.
+		httpClient := *co.httpClient
+		co.headerTimeout = httpClient.Timeout
+		httpClient.Timeout = 0
+		co.httpClient = &httpClient
+		if co.downloadTimeoutMillis > 0 {
+			co.ctx, co.cancel = context.WithTimeout(co.ctx, time.Duration(co.downloadTimeoutMillis)*time.Millisecond)
+		}
+	}
+	return co
+}
+
+// WithCallContext sets the context of the request. Cancelling it aborts the request and any pending retry.
+func WithCallContext(ctx context.Context) CallOption {
+	return func(co *callOptions) {
+		co.ctx = ctx
+	}
+}
+
+/*
+WithCallTimeout overrides the client timeout in millis for every attempt of the request. For
+AudienceDownloadTo and AudienceDownloadToFile it only bounds the wait for the response headers,
+see WithCallDownloadTimeout.
+*/
+func WithCallTimeout(timeout int) CallOption {
+	return func(co *callOptions) {
+		co.timeoutMillis = timeout
+	}
+}
+
+// WithCallHeaders adds headers to the request, overriding the client headers with the same name.
+func WithCallHeaders(headers map[string]string) CallOption {
+	return func(co *callOptions) {
+		for k, v := range headers {
+			co.headers[k] = v
+		}
+	}
+}
+
+// WithCallRetryHandler overrides the client RetryHandler, a nil RetryHandler is ignored.
+func WithCallRetryHandler(retryHandler RetryHandler) CallOption {
+	return func(co *callOptions) {
+		if retryHandler != nil {
+			co.retryHandler = retryHandler
+		}
+	}
+}
+
+// WithCallCredentialsProvider overrides the client CredentialsProvider, a nil CredentialsProvider is ignored.
+func WithCallCredentialsProvider(credentialsProvider CredentialsProvider) CallOption {
+	return func(co *callOptions) {
+		if credentialsProvider != nil {
+			co.credentialsProvider = credentialsProvider
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package fullcontact
+
+import (
+	"context"
+	assert "github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCallOptionsOverrideClient(t *testing.T) {
+	var req *http.Request
+	transport := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		req = r
+		return chaosResponse(r, 200, "{}"), nil
+	})
+	fcTestClient, err := NewFullContactClient(
+		WithCredentialsProvider(StaticCredentialsProvider{apiKey: "apikey"}),
+		WithHeaders(map[string]string{"Reporting-Key": "client", "X-Client": "x"}),
+		WithHTTPClient(&http.Client{Transport: transport}))
+	assert.NoError(t, err)
+
+	cp, _ := NewStaticCredentialsProvider("callkey")
+	resp := <-fcTestClient.TagsGet("r1",
+		WithCallHeaders(map[string]string{"Reporting-Key": "call"}),
+		WithCallCredentialsProvider(cp))
+	assert.NoError(t, resp.Err)
+	assert.Equal(t, "call", req.Header.Get("Reporting-Key"))
+	assert.Equal(t, "x", req.Header.Get("X-Client"))
+	assert.Equal(t, "Bearer callkey", req.Header.Get("Authorization"))
+
+	resp = <-fcTestClient.TagsGet("r1")
+	assert.NoError(t, resp.Err)
+	assert.Equal(t, "client", req.Header.Get("Reporting-Key"))
+	assert.Equal(t, "Bearer apikey", req.Header.Get("Authorization"))
+
+	// nil overrides keep the client settings
+	resp = <-fcTestClient.TagsGet("r1", WithCallCredentialsProvider(nil), WithCallRetryHandler(nil))
+	assert.NoError(t, resp.Err)
+	assert.Equal(t, "Bearer apikey", req.Header.Get("Authorization"))
+}
+
+func TestCallOptionsTimeoutAndRetryHandler(t *testing.T) {
+	calls := 0
+	transport := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		return chaosResponse(r, 503, ""), nil
+	})
+	fcTestClient := fullContactClient{
+		credentialsProvider: StaticCredentialsProvider{apiKey: "apikey"},
+		httpClient:          &http.Client{Transport: transport, Timeout: time.Second},
+		retryHandler:        fastRetryHandler{attempts: 0}}
+
+	co := fcTestClient.newCallOptions([]CallOption{WithCallTimeout(500)})
+	assert.Equal(t, 500*time.Millisecond, co.httpClient.Timeout)
+	assert.Equal(t, time.Second, fcTestClient.httpClient.Timeout)
+
+	ch := make(chan *APIResponse)
+	go fcTestClient.do(tagsGetUrl, nil, ch, WithCallRetryHandler(fastRetryHandler{attempts: 2}))
+	resp := <-ch
+	assert.Equal(t, 503, resp.StatusCode)
+	assert.Equal(t, 3, calls)
+}
+
+func TestCallContextCancelsRetry(t *testing.T) {
+	_, testServer := getTestServerAndClient(tagsGetUrl, "", 429)
+	defer testServer.Close()
+	fcTestClient := fullContactClient{
+		credentialsProvider: StaticCredentialsProvider{apiKey: "apikey"},
+		httpClient:          &http.Client{},
+		retryHandler:        &DefaultRetryHandler{}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	ch := make(chan *APIResponse)
+	go fcTestClient.do(testServer.URL, nil, ch, WithCallContext(ctx))
+	resp := <-ch
+	assert.Equal(t, context.DeadlineExceeded, resp.Err)
+	assert.True(t, time.Since(start) < time.Second)
+}
@@ -0,0 +1,304 @@
+package fullcontact
+
+import (
+	"bytes"
+	"context"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"path"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+/*
+ChaosTransport is a fault-injecting http.RoundTripper for resilience testing.
+
+It wraps a base RoundTripper and, based on configurable probabilities, adds latency,
+resets connections, answers with 429/500/503 or corrupts and slows down the response body.
+Faults can be configured per endpoint (e.g. "person.enrich") and are drawn from a
+random source seeded with a fixed value, so test runs are reproducible.
+*/
+type ChaosTransport struct {
+	base      http.RoundTripper
+	seed      int64
+	faults    *ChaosFaults
+	endpoints map[string]*ChaosFaults
+	mutex     sync.Mutex
+	randoms   map[string]*rand.Rand
+	injected  map[ChaosFault]int
+}
+
+type ChaosTransportOption func(ct *ChaosTransport)
+
+type ChaosFault string
+
+const (
+	ChaosLatency         ChaosFault = "latency"
+	ChaosConnectionReset ChaosFault = "connection_reset"
+	ChaosRateLimited     ChaosFault = "rate_limited"
+	ChaosInternalError   ChaosFault = "internal_error"
+	ChaosUnavailable     ChaosFault = "unavailable"
+	ChaosTruncatedBody   ChaosFault = "truncated_body"
+	ChaosMalformedBody   ChaosFault = "malformed_body"
+	ChaosSlowBody        ChaosFault = "slow_body"
+)
+
+// ChaosFaults holds the probability (0 to 1) of every fault and its parameters.
+type ChaosFaults struct {
+	LatencyProbability       float64
+	Latency                  time.Duration
+	ResetProbability         float64
+	RateLimitProbability     float64
+	RetryAfterSeconds        int
+	InternalErrorProbability float64
+	UnavailableProbability   float64
+	TruncatedBodyProbability float64
+	MalformedBodyProbability float64
+	SlowBodyProbability      float64
+	SlowBodyChunkSize        int
+	SlowBodyChunkDelay       time.Duration
+}
+
+func NewChaosTransport(options ...ChaosTransportOption) *ChaosTransport {
+	ct := &ChaosTransport{
+		faults:    &ChaosFaults{},
+		endpoints: make(map[string]*ChaosFaults),
+		randoms:   make(map[string]*rand.Rand),
+		injected:  make(map[ChaosFault]int),
+	}
+
+	for _, opts := range options {
+		opts(ct)
+	}
+
+	if ct.base == nil {
+		ct.base = http.DefaultTransport
+	}
+	return ct
+}
+
+func WithChaosBaseTransport(base http.RoundTripper) ChaosTransportOption {
+	return func(ct *ChaosTransport) {
+		ct.base = base
+	}
+}
+
+func WithChaosSeed(seed int64) ChaosTransportOption {
+	return func(ct *ChaosTransport) {
+		ct.seed = seed
+	}
+}
+
+// WithChaosFaults sets the faults applied to every endpoint without its own configuration.
+func WithChaosFaults(faults *ChaosFaults) ChaosTransportOption {
+	return func(ct *ChaosTransport) {
+		ct.faults = faults
+	}
+}
+
+// WithChaosEndpointFaults sets the faults for a single endpoint, e.g. "person.enrich".
+func WithChaosEndpointFaults(endpoint string, faults *ChaosFaults) ChaosTransportOption {
+	return func(ct *ChaosTransport) {
+		ct.endpoints[endpoint] = faults
+	}
+}
+
+// InjectedFaults returns how many times each fault has been injected so far.
+func (ct *ChaosTransport) InjectedFaults() map[ChaosFault]int {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+	injected := make(map[ChaosFault]int, len(ct.injected))
+	for fault, count := range ct.injected {
+		injected[fault] = count
+	}
+	return injected
+}
+
+func (ct *ChaosTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint := path.Base(req.URL.Path)
+	faults := ct.faultsFor(endpoint)
+
+	if ct.roll(endpoint, faults.LatencyProbability) {
+		ct.record(ChaosLatency)
+		select {
+		case <-time.After(faults.Latency):
+		case <-req.Context().Done():
+			closeRequestBody(req)
+			return nil, req.Context().Err()
+		}
+	}
+
+	switch {
+	case ct.roll(endpoint, faults.ResetProbability):
+		ct.record(ChaosConnectionReset)
+		closeRequestBody(req)
+		return nil, &net.OpError{Op: "read", Net: "tcp", Addr: fakeAddr(req.URL.Host), Err: syscall.ECONNRESET}
+	case ct.roll(endpoint, faults.RateLimitProbability):
+		ct.record(ChaosRateLimited)
+		closeRequestBody(req)
+		resp := chaosResponse(req, http.StatusTooManyRequests, "{\"status\":429,\"message\":\"Rate limit exceeded\"}")
+		if faults.RetryAfterSeconds > 0 {
+			resp.Header.Set("Retry-After", strconv.Itoa(faults.RetryAfterSeconds))
+		}
+		return resp, nil
+	case ct.roll(endpoint, faults.InternalErrorProbability):
+		ct.record(ChaosInternalError)
+		closeRequestBody(req)
+		return chaosResponse(req, http.StatusInternalServerError, "{\"status\":500,\"message\":\"Internal Server Error\"}"), nil
+	case ct.roll(endpoint, faults.UnavailableProbability):
+		ct.record(ChaosUnavailable)
+		closeRequestBody(req)
+		return chaosResponse(req, http.StatusServiceUnavailable, "{\"status\":503,\"message\":\"Service Unavailable\"}"), nil
+	}
+
+	resp, err := ct.base.RoundTrip(req)
+	if err != nil || resp == nil {
+		return resp, err
+	}
+
+	switch {
+	case ct.roll(endpoint, faults.TruncatedBodyProbability):
+		ct.record(ChaosTruncatedBody)
+		return truncateBody(resp)
+	case ct.roll(endpoint, faults.MalformedBodyProbability):
+		ct.record(ChaosMalformedBody)
+		return malformBody(resp)
+	case ct.roll(endpoint, faults.SlowBodyProbability):
+		ct.record(ChaosSlowBody)
+		resp.Body = &slowReadCloser{
+			body:      resp.Body,
+			chunkSize: faults.SlowBodyChunkSize,
+			delay:     faults.SlowBodyChunkDelay,
+			ctx:       req.Context(),
+		}
+	}
+	return resp, nil
+}
+
+func (ct *ChaosTransport) faultsFor(endpoint string) *ChaosFaults {
+	if faults, ok := ct.endpoints[endpoint]; ok && faults != nil {
+		return faults
+	}
+	if ct.faults == nil {
+		return &ChaosFaults{}
+	}
+	return ct.faults
+}
+
+// roll draws from a random source per endpoint, so that the faults seen by one endpoint
+// don't depend on how many requests were made to the others.
+func (ct *ChaosTransport) roll(endpoint string, probability float64) bool {
+	if probability <= 0 {
+		return false
+	}
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+	random, ok := ct.randoms[endpoint]
+	if !ok {
+		hash := fnv.New64a()
+		hash.Write([]byte(endpoint))
+		random = rand.New(rand.NewSource(ct.seed ^ int64(hash.Sum64())))
+		ct.randoms[endpoint] = random
+	}
+	return random.Float64() < probability
+}
+
+func (ct *ChaosTransport) record(fault ChaosFault) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+	ct.injected[fault]++
+}
+
+// closeRequestBody closes the body of a request which never reaches the base RoundTripper,
+// as the RoundTripper contract requires.
+func closeRequestBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
+
+func chaosResponse(req *http.Request, statusCode int, body string) *http.Response {
+	header := make(http.Header)
+	header.Set("Content-Type", "application/json")
+	return &http.Response{
+		Status:        strconv.Itoa(statusCode) + " " + http.StatusText(statusCode),
+		StatusCode:    statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewBufferString(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+func truncateBody(resp *http.Response) (*http.Response, error) {
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	bodyBytes = bodyBytes[:len(bodyBytes)/2]
+	resp.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBytes))
+	resp.ContentLength = int64(len(bodyBytes))
+	resp.Header.Del("Content-Length")
+	return resp, nil
+}
+
+func malformBody(resp *http.Response) (*http.Response, error) {
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	bodyBytes = append([]byte("{\"malformed\":"), bodyBytes...)
+	resp.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBytes))
+	resp.ContentLength = int64(len(bodyBytes))
+	resp.Header.Del("Content-Length")
+	return resp, nil
+}
+
+// slowReadCloser drips the body out in small chunks with a delay before each of them.
+type slowReadCloser struct {
+	body      io.ReadCloser
+	chunkSize int
+	delay     time.Duration
+	ctx       context.Context
+}
+
+func (src *slowReadCloser) Read(p []byte) (int, error) {
+	select {
+	case <-time.After(src.delay):
+	case <-src.ctx.Done():
+		return 0, src.ctx.Err()
+	}
+	chunkSize := src.chunkSize
+	if chunkSize <= 0 {
+		chunkSize = 1
+	}
+	if len(p) > chunkSize {
+		p = p[:chunkSize]
+	}
+	return src.body.Read(p)
+}
+
+func (src *slowReadCloser) Close() error {
+	return src.body.Close()
+}
+
+type fakeAddr string
+
+func (addr fakeAddr) Network() string {
+	return "tcp"
+}
+
+func (addr fakeAddr) String() string {
+	return string(addr)
+}
@@ -0,0 +1,204 @@
+package fullcontact
+
+import (
+	"bytes"
+	"context"
+	assert "github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type fastRetryHandler struct {
+	attempts int
+}
+
+func (frh fastRetryHandler) ShouldRetry(responseCode int) bool {
+	return responseCode == 429 || responseCode == 500 || responseCode == 503
+}
+
+func (frh fastRetryHandler) RetryAttempts() int {
+	return frh.attempts
+}
+
+func (frh fastRetryHandler) RetryDelayMillis() int {
+	return 1
+}
+
+func getChaosTestClient(chaos *ChaosTransport, attempts int) fullContactClient {
+	return fullContactClient{
+		credentialsProvider: StaticCredentialsProvider{apiKey: "apikey"},
+		httpClient:          &http.Client{Transport: chaos},
+		retryHandler:        fastRetryHandler{attempts: attempts}}
+}
+
+func stubTransport(statusCode int, respJson string) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return chaosResponse(req, statusCode, respJson), nil
+	})
+}
+
+func TestChaosTransportRateLimitedIsRetried(t *testing.T) {
+	chaos := NewChaosTransport(
+		WithChaosBaseTransport(stubTransport(200, "{\"recordIds\":[\"r1\"]}")),
+		WithChaosSeed(7),
+		WithChaosFaults(&ChaosFaults{RateLimitProbability: 1, RetryAfterSeconds: 2}))
+	fcTestClient := getChaosTestClient(chaos, 3)
+
+	ch := make(chan *APIResponse)
+	go fcTestClient.do(identityMapUrl, nil, ch)
+	resp := <-ch
+	assert.False(t, resp.IsSuccessful)
+	assert.Equal(t, 429, resp.StatusCode)
+	assert.Equal(t, "2", resp.RawHttpResponse.Header.Get("Retry-After"))
+	assert.Equal(t, 4, chaos.InjectedFaults()[ChaosRateLimited])
+}
+
+func TestChaosTransportRecoversAfterRetry(t *testing.T) {
+	chaos := NewChaosTransport(
+		WithChaosBaseTransport(stubTransport(200, "{\"recordIds\":[\"r1\"]}")),
+		WithChaosSeed(3),
+		WithChaosEndpointFaults("identity.map", &ChaosFaults{UnavailableProbability: 0.5}))
+	fcTestClient := getChaosTestClient(chaos, 5)
+
+	ch := make(chan *APIResponse)
+	for i := 0; i < 10; i++ {
+		go fcTestClient.do(identityMapUrl, nil, ch)
+		resp := <-ch
+		assert.True(t, resp.IsSuccessful)
+		assert.Equal(t, "r1", resp.ResolveResponse.RecordIds[0])
+	}
+	assert.True(t, chaos.InjectedFaults()[ChaosUnavailable] > 0)
+}
+
+func TestChaosTransportConnectionReset(t *testing.T) {
+	chaos := NewChaosTransport(
+		WithChaosBaseTransport(stubTransport(200, "")),
+		WithChaosFaults(&ChaosFaults{ResetProbability: 1}))
+	fcTestClient := getChaosTestClient(chaos, 1)
+
+	ch := make(chan *APIResponse)
+	go fcTestClient.do(identityMapUrl, nil, ch)
+	resp := <-ch
+	assert.Error(t, resp.Err)
+	assert.Contains(t, resp.Err.Error(), "connection reset")
+	assert.Equal(t, 2, chaos.InjectedFaults()[ChaosConnectionReset])
+}
+
+func TestChaosTransportMalformedAndTruncatedBody(t *testing.T) {
+	_, testServer := getTestServerAndClient(identityMapUrl, "{\"recordIds\":[\"r1\"]}", 200)
+	defer testServer.Close()
+
+	chaos := NewChaosTransport(WithChaosFaults(&ChaosFaults{MalformedBodyProbability: 1}))
+	fcTestClient := getChaosTestClient(chaos, 0)
+	ch := make(chan *APIResponse)
+	go fcTestClient.do(testServer.URL, nil, ch)
+	assert.Error(t, (<-ch).Err)
+
+	chaos = NewChaosTransport(WithChaosFaults(&ChaosFaults{TruncatedBodyProbability: 1}))
+	fcTestClient = getChaosTestClient(chaos, 0)
+	go fcTestClient.do(testServer.URL, nil, ch)
+	assert.Error(t, (<-ch).Err)
+}
+
+func TestChaosTransportEndpointFaults(t *testing.T) {
+	base := stubTransport(200, "{}")
+	chaos := NewChaosTransport(
+		WithChaosBaseTransport(base),
+		WithChaosEndpointFaults("person.enrich", &ChaosFaults{UnavailableProbability: 1}))
+
+	req, _ := http.NewRequest("POST", personEnrichUrl, nil)
+	resp, err := chaos.RoundTrip(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 503, resp.StatusCode)
+
+	req, _ = http.NewRequest("POST", companyEnrichUrl, nil)
+	resp, err = chaos.RoundTrip(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+}
+
+func TestChaosTransportIsDeterministic(t *testing.T) {
+	base := stubTransport(200, "{}")
+	run := func() []int {
+		chaos := NewChaosTransport(
+			WithChaosBaseTransport(base),
+			WithChaosSeed(42),
+			WithChaosFaults(&ChaosFaults{InternalErrorProbability: 0.5}))
+		codes := make([]int, 0)
+		for i := 0; i < 20; i++ {
+			req, _ := http.NewRequest("POST", personEnrichUrl, nil)
+			resp, err := chaos.RoundTrip(req)
+			assert.NoError(t, err)
+			codes = append(codes, resp.StatusCode)
+		}
+		return codes
+	}
+	first := run()
+	assert.Equal(t, first, run())
+	assert.Contains(t, first, 500)
+	assert.Contains(t, first, 200)
+}
+
+func TestChaosTransportSlowBodyAndLatency(t *testing.T) {
+	base := stubTransport(200, "{\"a\":1}")
+	chaos := NewChaosTransport(
+		WithChaosBaseTransport(base),
+		WithChaosFaults(&ChaosFaults{
+			LatencyProbability:  1,
+			Latency:             20 * time.Millisecond,
+			SlowBodyProbability: 1,
+			SlowBodyChunkSize:   2,
+			SlowBodyChunkDelay:  5 * time.Millisecond}))
+
+	start := time.Now()
+	req, _ := http.NewRequest("POST", personEnrichUrl, nil)
+	resp, err := chaos.RoundTrip(req)
+	assert.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, "{\"a\":1}", string(body))
+	assert.True(t, time.Since(start) >= 35*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	req, _ = http.NewRequestWithContext(ctx, "POST", personEnrichUrl, nil)
+	_, err = chaos.RoundTrip(req)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+type closeRecorder struct {
+	*bytes.Reader
+	closed bool
+}
+
+func (cr *closeRecorder) Close() error {
+	cr.closed = true
+	return nil
+}
+
+func TestChaosTransportClosesRequestBodyOfFaults(t *testing.T) {
+	for _, faults := range []*ChaosFaults{{ResetProbability: 1}, {RateLimitProbability: 1},
+		{InternalErrorProbability: 1}, {UnavailableProbability: 1}} {
+		chaos := NewChaosTransport(WithChaosBaseTransport(stubTransport(200, "{}")), WithChaosFaults(faults))
+		body := &closeRecorder{Reader: bytes.NewReader([]byte("{}"))}
+		req, _ := http.NewRequest("POST", personEnrichUrl, body)
+		chaos.RoundTrip(req)
+		assert.True(t, body.closed)
+	}
+}
+
+func TestChaosTransportSlowBodyCancelled(t *testing.T) {
+	chaos := NewChaosTransport(
+		WithChaosBaseTransport(stubTransport(200, "{\"a\":1}")),
+		WithChaosFaults(&ChaosFaults{SlowBodyProbability: 1, SlowBodyChunkDelay: time.Second}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, "POST", personEnrichUrl, nil)
+	resp, err := chaos.RoundTrip(req)
+	assert.NoError(t, err)
+	cancel()
+	_, err = ioutil.ReadAll(resp.Body)
+	assert.Equal(t, context.Canceled, err)
+}
@@ -13,6 +13,9 @@ type fullContactClient struct {
 	headers              map[string]string
 	httpClient           *http.Client
 	retryHandler         RetryHandler
+	callObservers        []CallObserver
+	usageLedger          *UsageLedger
+	tagSchema            *TagSchema
 }
 
 func NewFullContactClient(options ...ClientOption) (*fullContactClient, error) {
@@ -80,3 +83,29 @@ func WithHTTPClient(httpClient *http.Client) ClientOption {
 		fc.httpClient = httpClient
 	}
 }
+
+// WithCallObserver adds an observer which is notified of every request completed by the client.
+func WithCallObserver(observer CallObserver) ClientOption {
+	return func(fc *fullContactClient) {
+		fc.callObservers = append(fc.callObservers, observer)
+	}
+}
+
+// WithUsageLedger accounts every request in the ledger and fails requests once a budget cap is reached.
+func WithUsageLedger(ledger *UsageLedger) ClientOption {
+	return func(fc *fullContactClient) {
+		fc.usageLedger = ledger
+		fc.callObservers = append(fc.callObservers, ledger)
+	}
+}
+
+/*
+WithTagSchema validates the tags of TagsCreate, IdentityMap, IdentityMapResolve and AudienceCreate
+against the schema, failing the request before it is sent. Tags deleted with TagsDelete aren't
+validated, so undeclared tags can still be cleaned up.
+*/
+func WithTagSchema(schema *TagSchema) ClientOption {
+	return func(fc *fullContactClient) {
+		fc.tagSchema = schema
+	}
+}
@@ -0,0 +1,67 @@
+package fullcontact
+
+import "io"
+
+//go:generate go run ./internal/mockgen -source client_interface.go -destination mock_client_gen.go -type MockClient
+
+// Enricher is implemented by clients of the Person and Company Enrich APIs.
+type Enricher interface {
+	PersonEnrich(personRequest *PersonRequest, options ...CallOption) chan *APIResponse
+	CompanyEnrich(companyRequest *CompanyRequest, options ...CallOption) chan *APIResponse
+}
+
+// Resolver is implemented by clients of the Resolve APIs of the Private Identity Cloud.
+type Resolver interface {
+	IdentityMap(resolveRequest *ResolveRequest, options ...CallOption) chan *APIResponse
+	IdentityResolve(resolveRequest *ResolveRequest, options ...CallOption) chan *APIResponse
+	IdentityMapResolve(resolveRequest *ResolveRequest, options ...CallOption) chan *APIResponse
+	IdentityResolveWithTags(resolveRequest *ResolveRequest, options ...CallOption) chan *APIResponse
+	IdentityDelete(resolveRequest *ResolveRequest, options ...CallOption) chan *APIResponse
+}
+
+// Tagger is implemented by clients of the Tags APIs.
+type Tagger interface {
+	TagsCreate(tagsRequest *TagsRequest, options ...CallOption) chan *APIResponse
+	TagsGet(recordId string, options ...CallOption) chan *APIResponse
+	TagsDelete(tagsRequest *TagsRequest, options ...CallOption) chan *APIResponse
+}
+
+// AudienceAPI is implemented by clients of the Audience APIs.
+type AudienceAPI interface {
+	AudienceCreate(audienceRequest *AudienceRequest, options ...CallOption) chan *APIResponse
+	AudienceDownload(requestId string, options ...CallOption) chan *APIResponse
+	AudienceDownloadTo(requestId string, writer io.Writer, options ...CallOption) chan *APIResponse
+	AudienceDownloadToFile(requestId string, fileName string, options ...CallOption) chan *APIResponse
+}
+
+// PermissionAPI is implemented by clients of the Permission APIs.
+type PermissionAPI interface {
+	PermissionCreate(permissionRequest *PermissionRequest, options ...CallOption) chan *APIResponse
+	PermissionDelete(multifieldRequest *MultifieldRequest, options ...CallOption) chan *APIResponse
+	PermissionFind(multifieldRequest *MultifieldRequest, options ...CallOption) chan *APIResponse
+	PermissionCurrent(multifieldRequest *MultifieldRequest, options ...CallOption) chan *APIResponse
+	PermissionVerify(permissionRequest *PermissionRequest, options ...CallOption) chan *APIResponse
+}
+
+// Verifier is implemented by clients of the Verify APIs.
+type Verifier interface {
+	VerifySignals(multifieldRequest *MultifieldRequest, options ...CallOption) chan *APIResponse
+	VerifyMatch(multifieldRequest *MultifieldRequest, options ...CallOption) chan *APIResponse
+	VerifyActivity(multifieldRequest *MultifieldRequest, options ...CallOption) chan *APIResponse
+}
+
+/*
+Client covers every FullContact API supported by this library. It is implemented by the client
+returned from NewFullContactClient and by MockClient, so code can depend on Client (or on one of
+the smaller interfaces above) and substitute a mock in tests.
+*/
+type Client interface {
+	Enricher
+	Resolver
+	Tagger
+	AudienceAPI
+	PermissionAPI
+	Verifier
+}
+
+var _ Client = (*fullContactClient)(nil)
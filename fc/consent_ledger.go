@@ -0,0 +1,459 @@
+package fullcontact
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const consentLedgerStorePrefix = "consent-ledger/"
+
+/*
+ConsentEntry records a Permission Create, Verify or Delete call. Hash is the SHA-256 of the entry
+and of the Hash of the previous entry, so modifying, removing or reordering entries breaks the chain.
+*/
+type ConsentEntry struct {
+	Sequence           int                `json:"sequence"`
+	Time               time.Time          `json:"time"`
+	Endpoint           string             `json:"endpoint"`
+	Query              *MultifieldRequest `json:"query,omitempty"`
+	ConsentPurposes    []*ConsentPurpose  `json:"consentPurposes,omitempty"`
+	PurposeId          int                `json:"purposeId,omitempty"`
+	Channel            string             `json:"channel,omitempty"`
+	CollectionMethod   string             `json:"collectionMethod,omitempty"`
+	CollectionLocation string             `json:"collectionLocation,omitempty"`
+	PolicyUrl          string             `json:"policyUrl,omitempty"`
+	TermsService       string             `json:"termsService,omitempty"`
+	Request            json.RawMessage    `json:"request"`
+	StatusCode         int                `json:"statusCode,omitempty"`
+	Outcome            Outcome            `json:"outcome"`
+	Response           json.RawMessage    `json:"response,omitempty"`
+	Error              string             `json:"error,omitempty"`
+	PreviousHash       string             `json:"previousHash"`
+	Hash               string             `json:"hash"`
+}
+
+func (entry *ConsentEntry) computeHash() (string, error) {
+	unhashed := *entry
+	unhashed.Hash = ""
+	value, err := json.Marshal(&unhashed)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(append([]byte(entry.PreviousHash), value...))
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// copy returns a deep copy of the entry, so that the entries of a ledger can't be modified from outside.
+func (entry *ConsentEntry) copy() *ConsentEntry {
+	entryCopy := *entry
+	if entry.Query != nil {
+		query := *entry.Query
+		query.Emails = append([]string(nil), query.Emails...)
+		query.Phones = append([]string(nil), query.Phones...)
+		query.Maids = append([]string(nil), query.Maids...)
+		if query.Location != nil {
+			location := *query.Location
+			query.Location = &location
+		}
+		if query.Name != nil {
+			name := *query.Name
+			query.Name = &name
+		}
+		query.Profiles = nil
+		for _, profile := range entry.Query.Profiles {
+			if profile != nil {
+				profileCopy := *profile
+				profile = &profileCopy
+			}
+			query.Profiles = append(query.Profiles, profile)
+		}
+		entryCopy.Query = &query
+	}
+	entryCopy.ConsentPurposes = nil
+	for _, purpose := range entry.ConsentPurposes {
+		if purpose != nil {
+			purposeCopy := *purpose
+			if purpose.Channel != nil {
+				// an empty channel is kept non-nil, it's hashed as [] rather than null
+				purposeCopy.Channel = append(make([]string, 0, len(purpose.Channel)), purpose.Channel...)
+			}
+			if purpose.Enabled != nil {
+				enabled := *purpose.Enabled
+				purposeCopy.Enabled = &enabled
+			}
+			purpose = &purposeCopy
+		}
+		entryCopy.ConsentPurposes = append(entryCopy.ConsentPurposes, purpose)
+	}
+	entryCopy.Request = append(json.RawMessage(nil), entry.Request...)
+	entryCopy.Response = append(json.RawMessage(nil), entry.Response...)
+	return &entryCopy
+}
+
+// identifiers returns the identifiers of the query of the entry.
+func (entry *ConsentEntry) identifiers() []string {
+	query := entry.Query
+	if query == nil {
+		return nil
+	}
+	identifiers := append(append(append([]string(nil), query.Emails...), query.Phones...), query.Maids...)
+	identifiers = append(identifiers, query.RecordId, query.PersonId, query.PartnerId, query.LiNonId, query.Placekey, query.PanoramaId)
+	for _, profile := range query.Profiles {
+		if profile != nil {
+			identifiers = append(identifiers, profile.URL, profile.Username, profile.UserId)
+		}
+	}
+	return identifiers
+}
+
+// ConsentQuery selects entries of a ConsentLedger, its zero fields match any entry.
+type ConsentQuery struct {
+	// Identifier matches an email, phone, maid, profile or id of the query of the entry.
+	Identifier string
+	PurposeId  int
+	Endpoint   string
+	Since      time.Time
+	Until      time.Time
+}
+
+func (query ConsentQuery) matches(entry *ConsentEntry) bool {
+	if isPopulated(query.Identifier) && !containsString(entry.identifiers(), query.Identifier) {
+		return false
+	}
+	if query.PurposeId != 0 && entry.PurposeId != query.PurposeId {
+		found := false
+		for _, consentPurpose := range entry.ConsentPurposes {
+			found = found || (consentPurpose != nil && consentPurpose.PurposeId == query.PurposeId)
+		}
+		if !found {
+			return false
+		}
+	}
+	if isPopulated(query.Endpoint) && entry.Endpoint != query.Endpoint {
+		return false
+	}
+	if !query.Since.IsZero() && entry.Time.Before(query.Since) {
+		return false
+	}
+	return query.Until.IsZero() || entry.Time.Before(query.Until)
+}
+
+type ConsentLedgerOption func(cl *ConsentLedger)
+
+/*
+ConsentLedger is a tamper-evident, hash-chained log of the consent recorded with FullContact.
+Registered as a CallObserver with WithCallObserver, it appends an entry for every Permission Create,
+Verify and Delete call made through the client, with the request, consent purposes, collection
+method, policy url and response. Entries are saved to the Store as they are appended.
+
+Entries appended to the Store by another ledger are picked up before each append, but as the Store
+has no atomic operations, ledgers appending at the same time can still overwrite each other's
+entries: a ledger needs exclusive use of its Store while appending.
+
+The chain alone can't tell whether the latest entries were removed, so the Head of the ledger
+should be kept outside of the Store, and checked with VerifyHead.
+*/
+type ConsentLedger struct {
+	mutex   sync.RWMutex
+	store   Store
+	entries []*ConsentEntry
+	err     error
+	now     func() time.Time
+}
+
+var _ CallObserver = (*ConsentLedger)(nil)
+
+// NewConsentLedger creates a ConsentLedger, loaded from the Store set with WithConsentLedgerStore if any.
+func NewConsentLedger(options ...ConsentLedgerOption) (*ConsentLedger, error) {
+	cl := &ConsentLedger{now: time.Now}
+	for _, opts := range options {
+		opts(cl)
+	}
+	if cl.store == nil {
+		return cl, nil
+	}
+	entries, err := cl.load()
+	if err != nil {
+		return nil, err
+	}
+	cl.entries = entries
+	return cl, nil
+}
+
+func WithConsentLedgerStore(store Store) ConsentLedgerOption {
+	return func(cl *ConsentLedger) {
+		cl.store = store
+	}
+}
+
+func (cl *ConsentLedger) ObserveCall(call *CallRecord) {
+	var entry *ConsentEntry
+	var err error
+	switch call.Endpoint {
+	case endpointName(permissionCreateUrl), endpointName(permissionVerifyUrl):
+		var request PermissionRequest
+		if err = json.Unmarshal(call.Request, &request); err == nil {
+			entry = &ConsentEntry{
+				Query:              request.Query,
+				ConsentPurposes:    request.ConsentPurposes,
+				PurposeId:          request.PurposeId,
+				Channel:            request.Channel,
+				CollectionMethod:   request.CollectionMethod,
+				CollectionLocation: request.CollectionLocation,
+				PolicyUrl:          request.PolicyUrl,
+				TermsService:       request.TermsService,
+			}
+		}
+	case endpointName(permissionDeleteUrl):
+		var query MultifieldRequest
+		if err = json.Unmarshal(call.Request, &query); err == nil {
+			entry = &ConsentEntry{Query: &query}
+		}
+	default:
+		return
+	}
+	if err != nil {
+		// The call is still recorded, so that no consent call goes missing from the ledger.
+		entry = &ConsentEntry{Error: "Malformed request: " + err.Error()}
+	}
+	resp := call.Response
+	entry.Endpoint = call.Endpoint
+	entry.Request = call.Request
+	if !json.Valid(call.Request) {
+		entry.Request, _ = json.Marshal(string(call.Request))
+	}
+	entry.StatusCode = resp.StatusCode
+	entry.Outcome = resp.GetOutcome()
+	if resp.Err != nil {
+		entry.Error = strings.TrimPrefix(entry.Error+"; "+resp.Err.Error(), "; ")
+	}
+	if resp.RawHttpResponse != nil && resp.RawHttpResponse.Body != nil {
+		body, err := ioutil.ReadAll(resp.RawHttpResponse.Body)
+		resp.RawHttpResponse.Body = ioutil.NopCloser(bytes.NewBuffer(body))
+		if err == nil && json.Valid(body) {
+			entry.Response = body
+		}
+	}
+	if err := cl.Append(entry); err != nil {
+		cl.mutex.Lock()
+		cl.err = err
+		cl.mutex.Unlock()
+	}
+}
+
+/*
+Append chains the entry to the ledger and saves it, setting its Sequence, PreviousHash, Hash and Time
+if unset. Entries appended to the Store by another ledger since are loaded first.
+*/
+func (cl *ConsentLedger) Append(entry *ConsentEntry) error {
+	cl.mutex.Lock()
+	defer cl.mutex.Unlock()
+	if entry.Time.IsZero() {
+		entry.Time = cl.now()
+	}
+	if cl.store != nil {
+		if err := cl.loadTail(); err != nil {
+			return err
+		}
+	}
+	entry.Sequence = len(cl.entries) + 1
+	entry.PreviousHash = ""
+	if len(cl.entries) > 0 {
+		entry.PreviousHash = cl.entries[len(cl.entries)-1].Hash
+	}
+	hash, err := entry.computeHash()
+	if err != nil {
+		return err
+	}
+	entry.Hash = hash
+	if cl.store != nil {
+		value, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		if err = cl.store.Save(consentLedgerKey(entry.Sequence), value); err != nil {
+			return err
+		}
+	}
+	cl.entries = append(cl.entries, entry.copy())
+	return nil
+}
+
+// loadTail loads the entries saved to the Store after the last entry of the ledger.
+func (cl *ConsentLedger) loadTail() error {
+	for {
+		value, err := cl.store.Load(consentLedgerKey(len(cl.entries) + 1))
+		if err != nil || value == nil {
+			return err
+		}
+		var entry ConsentEntry
+		if err = json.Unmarshal(value, &entry); err != nil {
+			return err
+		}
+		cl.entries = append(cl.entries, &entry)
+	}
+}
+
+/*
+Head returns the Sequence and Hash of the last entry of the ledger, 0 and "" if it's empty. Kept
+outside of the Store, e.g. in a separate database or signed log, it lets VerifyHead detect the
+removal of the latest entries, which the hash chain alone can't.
+*/
+func (cl *ConsentLedger) Head() (int, string) {
+	cl.mutex.RLock()
+	defer cl.mutex.RUnlock()
+	if len(cl.entries) == 0 {
+		return 0, ""
+	}
+	last := cl.entries[len(cl.entries)-1]
+	return last.Sequence, last.Hash
+}
+
+// Err returns the last error met saving an entry observed from a call.
+func (cl *ConsentLedger) Err() error {
+	cl.mutex.RLock()
+	defer cl.mutex.RUnlock()
+	return cl.err
+}
+
+// Len returns the number of entries of the ledger.
+func (cl *ConsentLedger) Len() int {
+	cl.mutex.RLock()
+	defer cl.mutex.RUnlock()
+	return len(cl.entries)
+}
+
+// Query returns copies of the entries matching the query, oldest first.
+func (cl *ConsentLedger) Query(query ConsentQuery) []*ConsentEntry {
+	cl.mutex.RLock()
+	defer cl.mutex.RUnlock()
+	entries := make([]*ConsentEntry, 0)
+	for _, entry := range cl.entries {
+		if query.matches(entry) {
+			entries = append(entries, entry.copy())
+		}
+	}
+	return entries
+}
+
+/*
+Verify checks the hash chain of the ledger, as saved in its Store if any, returning an error for
+the first entry modified, removed or out of order. Entries removed from the end of the Store are
+only detected up to the Head of this ledger, use VerifyHead with a Head kept elsewhere to detect
+them across restarts.
+*/
+func (cl *ConsentLedger) Verify() error {
+	sequence, hash := cl.Head()
+	return cl.VerifyHead(sequence, hash)
+}
+
+// VerifyHead checks the hash chain of the ledger like Verify, and that it contains the given Head.
+func (cl *ConsentLedger) VerifyHead(sequence int, hash string) error {
+	entries := cl.Query(ConsentQuery{})
+	if cl.store != nil {
+		var err error
+		if entries, err = cl.load(); err != nil {
+			return err
+		}
+	}
+	return VerifyConsentEntriesHead(entries, sequence, hash)
+}
+
+/*
+VerifyConsentEntries checks the hash chain of entries of a ConsentLedger, e.g. read with
+ReadConsentEntries. It can't detect the removal of the last entries, see VerifyConsentEntriesHead.
+*/
+func VerifyConsentEntries(entries []*ConsentEntry) error {
+	return VerifyConsentEntriesHead(entries, 0, "")
+}
+
+// VerifyConsentEntriesHead checks the hash chain of the entries, and that it contains the Head of a ConsentLedger.
+func VerifyConsentEntriesHead(entries []*ConsentEntry, sequence int, hash string) error {
+	previousHash := ""
+	for i, entry := range entries {
+		if entry.Sequence != i+1 {
+			return NewFullContactError(fmt.Sprintf("Consent ledger entry %d is out of sequence, expected %d", entry.Sequence, i+1))
+		}
+		if entry.PreviousHash != previousHash {
+			return NewFullContactError(fmt.Sprintf("Consent ledger entry %d isn't chained to the previous entry", entry.Sequence))
+		}
+		hash, err := entry.computeHash()
+		if err != nil {
+			return err
+		}
+		if hash != entry.Hash {
+			return NewFullContactError(fmt.Sprintf("Consent ledger entry %d was modified", entry.Sequence))
+		}
+		previousHash = entry.Hash
+	}
+	if sequence > len(entries) {
+		return NewFullContactError(fmt.Sprintf("Consent ledger entries %d to %d were removed", len(entries)+1, sequence))
+	}
+	if sequence > 0 && entries[sequence-1].Hash != hash {
+		return NewFullContactError(fmt.Sprintf("Consent ledger entry %d doesn't match the head", sequence))
+	}
+	return nil
+}
+
+// WriteJSONL writes the entries of the ledger, one JSON object per line.
+func (cl *ConsentLedger) WriteJSONL(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	for _, entry := range cl.Query(ConsentQuery{}) {
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadConsentEntries reads entries written with WriteJSONL.
+func ReadConsentEntries(r io.Reader) ([]*ConsentEntry, error) {
+	decoder := json.NewDecoder(r)
+	entries := make([]*ConsentEntry, 0)
+	for {
+		var entry ConsentEntry
+		err := decoder.Decode(&entry)
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, &entry)
+	}
+}
+
+func (cl *ConsentLedger) load() ([]*ConsentEntry, error) {
+	keys, err := cl.store.Keys(consentLedgerStorePrefix)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	entries := make([]*ConsentEntry, 0, len(keys))
+	for _, key := range keys {
+		value, err := cl.store.Load(key)
+		if err != nil {
+			return nil, err
+		}
+		var entry ConsentEntry
+		if err = json.Unmarshal(value, &entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, &entry)
+	}
+	return entries, nil
+}
+
+// consentLedgerKey pads the sequence so that the keys sort in order.
+func consentLedgerKey(sequence int) string {
+	return fmt.Sprintf("%s%012d", consentLedgerStorePrefix, sequence)
+}
@@ -0,0 +1,197 @@
+package fullcontact
+
+import (
+	"bytes"
+	"encoding/json"
+	assert "github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func getConsentLedgerTestClient(t *testing.T, ledger *ConsentLedger) *fullContactClient {
+	transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		switch req.URL.String() {
+		case permissionCreateUrl:
+			return chaosResponse(req, 202, `{"status":"accepted"}`), nil
+		case permissionVerifyUrl:
+			return chaosResponse(req, 200, `{"ttl":365,"enabled":true,"channel":"web","purposeId":2,"purposeName":"Marketing"}`), nil
+		case permissionDeleteUrl:
+			return chaosResponse(req, 202, ""), nil
+		}
+		return chaosResponse(req, 200, "{}"), nil
+	})
+	fcClient, err := NewFullContactClient(
+		WithCredentialsProvider(StaticCredentialsProvider{apiKey: "apikey"}),
+		WithHTTPClient(&http.Client{Transport: transport}),
+		WithCallObserver(ledger))
+	assert.NoError(t, err)
+	return fcClient
+}
+
+func getConsentTestPermissionRequest(t *testing.T) *PermissionRequest {
+	permissionRequest, err := NewPermissionRequest(
+		WithMultifieldRequestForPermission(&MultifieldRequest{Emails: []string{"marquitaross006@gmail.com"}}),
+		WithConsentPurposeForPermission(NewConsentPurpose(
+			WithConsentPurposeId(2),
+			WithConsentPurposeChannel("web"),
+			WithConsentPurposeEnabled(true))),
+		WithCollectionMethodForPermission("cookiePopUp"),
+		WithCollectionLocationForPermission("https://example.com/signup"),
+		WithPolicyUrlForPermission("https://example.com/privacy"),
+		WithTermsServiceForPermission("https://example.com/terms"))
+	assert.NoError(t, err)
+	return permissionRequest
+}
+
+func TestConsentLedgerObservesCalls(t *testing.T) {
+	ledger, err := NewConsentLedger()
+	assert.NoError(t, err)
+	fcClient := getConsentLedgerTestClient(t, ledger)
+
+	assert.NoError(t, (<-fcClient.PermissionCreate(getConsentTestPermissionRequest(t))).Err)
+	verifyRequest, _ := NewPermissionRequest(
+		WithMultifieldRequestForPermission(&MultifieldRequest{Emails: []string{"marquitaross006@gmail.com"}}),
+		WithPurposeIdForPermission(2),
+		WithChannelForPermission("web"))
+	assert.NoError(t, (<-fcClient.PermissionVerify(verifyRequest)).Err)
+	assert.NoError(t, (<-fcClient.PermissionDelete(&MultifieldRequest{Phones: []string{"+15555550102"}})).Err)
+	assert.NoError(t, (<-fcClient.PersonEnrich(&PersonRequest{Emails: []string{"marquitaross006@gmail.com"}})).Err)
+	assert.NoError(t, ledger.Err())
+	assert.Equal(t, 3, ledger.Len())
+
+	entries := ledger.Query(ConsentQuery{Identifier: "marquitaross006@gmail.com"})
+	assert.Len(t, entries, 2)
+	created := entries[0]
+	assert.Equal(t, "permission.create", created.Endpoint)
+	assert.Equal(t, "cookiePopUp", created.CollectionMethod)
+	assert.Equal(t, "https://example.com/privacy", created.PolicyUrl)
+	assert.Equal(t, 2, created.ConsentPurposes[0].PurposeId)
+	assert.Equal(t, 202, created.StatusCode)
+	assert.JSONEq(t, `{"status":"accepted"}`, string(created.Response))
+	assert.Equal(t, "", created.PreviousHash)
+	assert.Equal(t, created.Hash, entries[1].PreviousHash)
+
+	assert.Len(t, ledger.Query(ConsentQuery{PurposeId: 2}), 2)
+	assert.Len(t, ledger.Query(ConsentQuery{Endpoint: "permission.delete", Identifier: "+15555550102"}), 1)
+	assert.Empty(t, ledger.Query(ConsentQuery{Since: time.Now().Add(time.Hour)}))
+	assert.NoError(t, ledger.Verify())
+}
+
+func TestConsentLedgerStoreAndTampering(t *testing.T) {
+	store := NewMemoryStore()
+	ledger, err := NewConsentLedger(WithConsentLedgerStore(store))
+	assert.NoError(t, err)
+	for purposeId := 1; purposeId <= 3; purposeId++ {
+		assert.NoError(t, ledger.Append(&ConsentEntry{Endpoint: "permission.create", PurposeId: purposeId, Request: json.RawMessage(`{}`)}))
+	}
+
+	loaded, err := NewConsentLedger(WithConsentLedgerStore(store))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, loaded.Len())
+	assert.NoError(t, loaded.Append(&ConsentEntry{Endpoint: "permission.delete"}))
+	assert.NoError(t, loaded.Verify())
+
+	value, _ := store.Load(consentLedgerKey(2))
+	var entry ConsentEntry
+	assert.NoError(t, json.Unmarshal(value, &entry))
+	entry.PurposeId = 9
+	value, _ = json.Marshal(&entry)
+	assert.NoError(t, store.Save(consentLedgerKey(2), value))
+	assert.EqualError(t, loaded.Verify(), "FullContactError: Consent ledger entry 2 was modified")
+
+	assert.NoError(t, store.Delete(consentLedgerKey(2)))
+	assert.EqualError(t, loaded.Verify(), "FullContactError: Consent ledger entry 3 is out of sequence, expected 2")
+}
+
+func TestConsentLedgerJSONL(t *testing.T) {
+	ledger, _ := NewConsentLedger()
+	assert.NoError(t, ledger.Append(&ConsentEntry{Endpoint: "permission.create", PurposeId: 1}))
+	assert.NoError(t, ledger.Append(&ConsentEntry{Endpoint: "permission.verify", PurposeId: 1}))
+
+	var buffer bytes.Buffer
+	assert.NoError(t, ledger.WriteJSONL(&buffer))
+	entries, err := ReadConsentEntries(bytes.NewReader(buffer.Bytes()))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.NoError(t, VerifyConsentEntries(entries))
+
+	entries = entries[1:]
+	entries[0].Sequence = 1
+	assert.EqualError(t, VerifyConsentEntries(entries), "FullContactError: Consent ledger entry 1 isn't chained to the previous entry")
+}
+
+func TestConsentLedgerHead(t *testing.T) {
+	store := NewMemoryStore()
+	ledger, err := NewConsentLedger(WithConsentLedgerStore(store))
+	assert.NoError(t, err)
+	sequence, hash := ledger.Head()
+	assert.Equal(t, 0, sequence)
+	assert.Equal(t, "", hash)
+	for purposeId := 1; purposeId <= 3; purposeId++ {
+		assert.NoError(t, ledger.Append(&ConsentEntry{Endpoint: "permission.create", PurposeId: purposeId}))
+	}
+	sequence, hash = ledger.Head()
+	assert.Equal(t, 3, sequence)
+
+	assert.NoError(t, store.Delete(consentLedgerKey(3)))
+	assert.EqualError(t, ledger.Verify(), "FullContactError: Consent ledger entries 3 to 3 were removed")
+	restarted, err := NewConsentLedger(WithConsentLedgerStore(store))
+	assert.NoError(t, err)
+	assert.NoError(t, restarted.Verify())
+	assert.EqualError(t, restarted.VerifyHead(sequence, hash), "FullContactError: Consent ledger entries 3 to 3 were removed")
+
+	assert.NoError(t, restarted.Append(&ConsentEntry{Endpoint: "permission.delete"}))
+	assert.EqualError(t, restarted.VerifyHead(sequence, hash), "FullContactError: Consent ledger entry 3 doesn't match the head")
+}
+
+func TestConsentLedgersSharingStore(t *testing.T) {
+	store := NewMemoryStore()
+	first, _ := NewConsentLedger(WithConsentLedgerStore(store))
+	second, _ := NewConsentLedger(WithConsentLedgerStore(store))
+	assert.NoError(t, first.Append(&ConsentEntry{Endpoint: "permission.create", PurposeId: 1}))
+	assert.NoError(t, second.Append(&ConsentEntry{Endpoint: "permission.create", PurposeId: 2}))
+	assert.NoError(t, first.Append(&ConsentEntry{Endpoint: "permission.create", PurposeId: 3}))
+
+	assert.Equal(t, 3, first.Len())
+	keys, _ := store.Keys(consentLedgerStorePrefix)
+	assert.Len(t, keys, 3)
+	assert.NoError(t, first.Verify())
+	assert.NoError(t, second.Verify())
+}
+
+func TestConsentLedgerRecordsMalformedRequests(t *testing.T) {
+	ledger, _ := NewConsentLedger()
+	ledger.ObserveCall(&CallRecord{
+		Endpoint: endpointName(permissionCreateUrl),
+		Request:  []byte(`{"purposeId":"x`),
+		Response: &APIResponse{StatusCode: 202, Outcome: OutcomeAccepted},
+	})
+	assert.NoError(t, ledger.Err())
+	entries := ledger.Query(ConsentQuery{})
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "permission.create", entries[0].Endpoint)
+	assert.JSONEq(t, `"{\"purposeId\":\"x"`, string(entries[0].Request))
+	assert.Contains(t, entries[0].Error, "Malformed request: ")
+	assert.NoError(t, ledger.Verify())
+}
+
+func TestConsentLedgerQueryReturnsCopies(t *testing.T) {
+	ledger, _ := NewConsentLedger()
+	query := &MultifieldRequest{Emails: []string{"marquitaross006@gmail.com"}}
+	entry := &ConsentEntry{Endpoint: "permission.create", Query: query,
+		ConsentPurposes: []*ConsentPurpose{{PurposeId: 1, Channel: []string{}}}}
+	assert.NoError(t, ledger.Append(entry))
+	query.Emails[0] = "other@example.com"
+
+	entries := ledger.Query(ConsentQuery{})
+	entries[0].Endpoint = "permission.delete"
+	entries[0].Query.Emails[0] = "other@example.com"
+	entries[0].ConsentPurposes[0].PurposeId = 2
+
+	entries = ledger.Query(ConsentQuery{Identifier: "marquitaross006@gmail.com"})
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "permission.create", entries[0].Endpoint)
+	assert.Equal(t, 1, entries[0].ConsentPurposes[0].PurposeId)
+	assert.NoError(t, ledger.Verify())
+}
@@ -1,11 +1,48 @@
 package fullcontact
 
-import "os"
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
 
 type CredentialsProvider interface {
 	GetApiKey() string
 }
 
+/*
+ContextCredentialsProvider can be implemented by a CredentialsProvider which may fail or block
+while looking up the API key. When implemented, the client uses GetApiKeyWithContext instead of
+GetApiKey and fails the request with the returned error.
+*/
+type ContextCredentialsProvider interface {
+	CredentialsProvider
+	GetApiKeyWithContext(ctx context.Context) (string, error)
+}
+
+/*
+CredentialsFeedback can be implemented by a CredentialsProvider which wants to know the outcome
+of the requests made with its API keys. The client reports the status code of every HTTP response,
+and sends a request which got a 401 or 403 again once if the provider then returns another API key.
+*/
+type CredentialsFeedback interface {
+	ReportResponse(apiKey string, statusCode int)
+}
+
+func getApiKey(ctx context.Context, credentialsProvider CredentialsProvider) (string, error) {
+	if cp, ok := credentialsProvider.(ContextCredentialsProvider); ok {
+		return cp.GetApiKeyWithContext(ctx)
+	}
+	apiKey := credentialsProvider.GetApiKey()
+	if !isPopulated(apiKey) {
+		return "", NewFullContactError("API Key can't be empty")
+	}
+	return apiKey, nil
+}
+
 type StaticCredentialsProvider struct {
 	apiKey string
 }
@@ -22,6 +59,13 @@ func (scp StaticCredentialsProvider) GetApiKey() string {
 	return scp.apiKey
 }
 
+func (scp StaticCredentialsProvider) GetApiKeyWithContext(ctx context.Context) (string, error) {
+	if !isPopulated(scp.apiKey) {
+		return "", NewFullContactError("API Key can't be empty")
+	}
+	return scp.apiKey, nil
+}
+
 type DefaultCredentialsProvider struct {
 	apiKey string
 }
@@ -38,3 +82,237 @@ func NewDefaultCredentialsProvider(envVar string) (DefaultCredentialsProvider, e
 func (dcp DefaultCredentialsProvider) GetApiKey() string {
 	return dcp.apiKey
 }
+
+func (dcp DefaultCredentialsProvider) GetApiKeyWithContext(ctx context.Context) (string, error) {
+	if !isPopulated(dcp.apiKey) {
+		return "", NewFullContactError("API Key can't be empty")
+	}
+	return dcp.apiKey, nil
+}
+
+// EnvCredentialsProvider reads the API key from an environment variable on every request.
+type EnvCredentialsProvider struct {
+	envVar string
+}
+
+func NewEnvCredentialsProvider(envVar string) EnvCredentialsProvider {
+	return EnvCredentialsProvider{envVar: envVar}
+}
+
+func (ecp EnvCredentialsProvider) GetApiKey() string {
+	return os.Getenv(ecp.envVar)
+}
+
+func (ecp EnvCredentialsProvider) GetApiKeyWithContext(ctx context.Context) (string, error) {
+	apiKey := os.Getenv(ecp.envVar)
+	if !isPopulated(apiKey) {
+		return "", NewFullContactError("Couldn't find valid API Key from ENV variable: " + ecp.envVar)
+	}
+	return apiKey, nil
+}
+
+/*
+FileCredentialsProvider reads the API key from a file, e.g. a mounted Kubernetes secret.
+The file is read again whenever its modification time or size changes, so rotated keys are
+picked up without restarting. Leading and trailing whitespace is ignored. If the file can't be
+reloaded, the last valid API key is still used and the error is reported by Err.
+*/
+type FileCredentialsProvider struct {
+	path    string
+	mutex   sync.Mutex
+	apiKey  string
+	modTime time.Time
+	size    int64
+	err     error
+}
+
+func NewFileCredentialsProvider(path string) (*FileCredentialsProvider, error) {
+	fcp := &FileCredentialsProvider{path: path}
+	_, err := fcp.GetApiKeyWithContext(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return fcp, nil
+}
+
+func (fcp *FileCredentialsProvider) GetApiKey() string {
+	apiKey, _ := fcp.GetApiKeyWithContext(context.Background())
+	return apiKey
+}
+
+// GetApiKeyWithContext returns the last valid API key, and an error only if no API key was ever loaded.
+func (fcp *FileCredentialsProvider) GetApiKeyWithContext(ctx context.Context) (string, error) {
+	fcp.mutex.Lock()
+	defer fcp.mutex.Unlock()
+	fcp.err = fcp.reload()
+	if !isPopulated(fcp.apiKey) {
+		return "", fcp.err
+	}
+	return fcp.apiKey, nil
+}
+
+// Err returns the error of the last reload of the file, nil if it succeeded.
+func (fcp *FileCredentialsProvider) Err() error {
+	fcp.mutex.Lock()
+	defer fcp.mutex.Unlock()
+	return fcp.err
+}
+
+func (fcp *FileCredentialsProvider) reload() error {
+	info, err := os.Stat(fcp.path)
+	if err != nil {
+		return NewFullContactError("Couldn't read API Key from file: " + err.Error())
+	}
+	if isPopulated(fcp.apiKey) && info.ModTime().Equal(fcp.modTime) && info.Size() == fcp.size {
+		return nil
+	}
+	content, err := ioutil.ReadFile(fcp.path)
+	if err != nil {
+		return NewFullContactError("Couldn't read API Key from file: " + err.Error())
+	}
+	apiKey := strings.TrimSpace(string(content))
+	if !isPopulated(apiKey) {
+		return NewFullContactError("Couldn't find valid API Key in file: " + fcp.path)
+	}
+	fcp.apiKey = apiKey
+	fcp.modTime = info.ModTime()
+	fcp.size = info.Size()
+	return nil
+}
+
+// ChainCredentialsProvider tries its providers in order and uses the first API key found.
+type ChainCredentialsProvider struct {
+	providers []CredentialsProvider
+}
+
+func NewChainCredentialsProvider(providers ...CredentialsProvider) (*ChainCredentialsProvider, error) {
+	if len(providers) < 1 {
+		return nil, NewFullContactError("At least 1 CredentialsProvider is required for the chain")
+	}
+	return &ChainCredentialsProvider{providers: providers}, nil
+}
+
+func (ccp *ChainCredentialsProvider) GetApiKey() string {
+	apiKey, _ := ccp.GetApiKeyWithContext(context.Background())
+	return apiKey
+}
+
+func (ccp *ChainCredentialsProvider) GetApiKeyWithContext(ctx context.Context) (string, error) {
+	errs := make([]string, 0)
+	for _, provider := range ccp.providers {
+		apiKey, err := getApiKey(ctx, provider)
+		if err == nil {
+			return apiKey, nil
+		}
+		errs = append(errs, err.Error())
+	}
+	return "", NewFullContactError("No CredentialsProvider in the chain returned an API Key: " + strings.Join(errs, "; "))
+}
+
+// ReportResponse forwards the outcome to every provider in the chain which accepts feedback.
+func (ccp *ChainCredentialsProvider) ReportResponse(apiKey string, statusCode int) {
+	for _, provider := range ccp.providers {
+		if feedback, ok := provider.(CredentialsFeedback); ok {
+			feedback.ReportResponse(apiKey, statusCode)
+		}
+	}
+}
+
+type PoolCredentialsProviderOption func(pcp *PoolCredentialsProvider)
+
+/*
+PoolCredentialsProvider spreads requests across several API keys in round-robin order.
+A key which gets a 401 or 403 is taken out of the pool for the auth failure cooldown, and a
+key which is rate limited (429) for the rate limit cooldown. Once every key is cooling down,
+requests fail until the first one becomes available again. A request which gets a 401 or 403 is
+sent again once by the client with the next key of the pool.
+*/
+type PoolCredentialsProvider struct {
+	mutex               sync.Mutex
+	apiKeys             []string
+	next                int
+	unavailableUntil    map[string]time.Time
+	authFailureCooldown time.Duration
+	rateLimitCooldown   time.Duration
+	now                 func() time.Time
+}
+
+func NewPoolCredentialsProvider(apiKeys []string, options ...PoolCredentialsProviderOption) (*PoolCredentialsProvider, error) {
+	pcp := &PoolCredentialsProvider{
+		unavailableUntil:    make(map[string]time.Time),
+		authFailureCooldown: time.Hour,
+		rateLimitCooldown:   time.Minute,
+		now:                 time.Now,
+	}
+	for _, apiKey := range apiKeys {
+		if !isPopulated(apiKey) {
+			return nil, NewFullContactError("API Key can't be empty")
+		}
+		pcp.apiKeys = append(pcp.apiKeys, apiKey)
+	}
+	if len(pcp.apiKeys) < 1 {
+		return nil, NewFullContactError("At least 1 API Key is required for the pool")
+	}
+
+	for _, opts := range options {
+		opts(pcp)
+	}
+	return pcp, nil
+}
+
+func WithAuthFailureCooldown(cooldown time.Duration) PoolCredentialsProviderOption {
+	return func(pcp *PoolCredentialsProvider) {
+		pcp.authFailureCooldown = cooldown
+	}
+}
+
+func WithRateLimitCooldown(cooldown time.Duration) PoolCredentialsProviderOption {
+	return func(pcp *PoolCredentialsProvider) {
+		pcp.rateLimitCooldown = cooldown
+	}
+}
+
+func (pcp *PoolCredentialsProvider) GetApiKey() string {
+	apiKey, _ := pcp.GetApiKeyWithContext(context.Background())
+	return apiKey
+}
+
+func (pcp *PoolCredentialsProvider) GetApiKeyWithContext(ctx context.Context) (string, error) {
+	pcp.mutex.Lock()
+	defer pcp.mutex.Unlock()
+
+	now := pcp.now()
+	for i := 0; i < len(pcp.apiKeys); i++ {
+		apiKey := pcp.apiKeys[(pcp.next+i)%len(pcp.apiKeys)]
+		if now.Before(pcp.unavailableUntil[apiKey]) {
+			continue
+		}
+		pcp.next = (pcp.next + i + 1) % len(pcp.apiKeys)
+		return apiKey, nil
+	}
+	return "", NewFullContactError("All API Keys in the pool are unavailable")
+}
+
+func (pcp *PoolCredentialsProvider) ReportResponse(apiKey string, statusCode int) {
+	pcp.mutex.Lock()
+	defer pcp.mutex.Unlock()
+
+	if !pcp.contains(apiKey) {
+		return
+	}
+	switch statusCode {
+	case 401, 403:
+		pcp.unavailableUntil[apiKey] = pcp.now().Add(pcp.authFailureCooldown)
+	case 429:
+		pcp.unavailableUntil[apiKey] = pcp.now().Add(pcp.rateLimitCooldown)
+	}
+}
+
+func (pcp *PoolCredentialsProvider) contains(apiKey string) bool {
+	for _, key := range pcp.apiKeys {
+		if key == apiKey {
+			return true
+		}
+	}
+	return false
+}
@@ -1,9 +1,14 @@
 package fullcontact
 
 import (
+	"context"
 	assert "github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestNewStaticCredentialsProvider(t *testing.T) {
@@ -12,6 +17,19 @@ func TestNewStaticCredentialsProvider(t *testing.T) {
 	assert.Equal(t, "apikey", cp.GetApiKey())
 }
 
+// setTestEnv sets the environment variable for the duration of the test and restores its previous value.
+func setTestEnv(t *testing.T, key, value string) {
+	previous, ok := os.LookupEnv(key)
+	assert.NoError(t, os.Setenv(key, value))
+	t.Cleanup(func() {
+		if ok {
+			_ = os.Setenv(key, previous)
+		} else {
+			_ = os.Unsetenv(key)
+		}
+	})
+}
+
 func TestNewStaticCredentialsProviderWithEmptyKey(t *testing.T) {
 	_, err := NewStaticCredentialsProvider("")
 	assert.EqualError(t, err, "FullContactError: API Key can't be empty")
@@ -31,3 +49,137 @@ func TestNewDefaultCredentialsProviderWithoutEnv(t *testing.T) {
 	_, err := NewDefaultCredentialsProvider(FcApiKey)
 	assert.EqualError(t, err, "FullContactError: Couldn't find valid API Key from ENV variable: FC_API_KEY")
 }
+
+func TestEnvCredentialsProviderReadsOnEveryCall(t *testing.T) {
+	cp := NewEnvCredentialsProvider("FC_TEST_API_KEY")
+	_, err := cp.GetApiKeyWithContext(context.Background())
+	assert.EqualError(t, err, "FullContactError: Couldn't find valid API Key from ENV variable: FC_TEST_API_KEY")
+
+	setTestEnv(t, "FC_TEST_API_KEY", "apikey")
+	apiKey, err := cp.GetApiKeyWithContext(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "apikey", apiKey)
+}
+
+func TestFileCredentialsProviderReloadsOnChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api-key")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("apikey1\n"), 0600))
+
+	cp, err := NewFileCredentialsProvider(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "apikey1", cp.GetApiKey())
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte("apikey22\n"), 0600))
+	assert.Equal(t, "apikey22", cp.GetApiKey())
+
+	assert.NoError(t, cp.Err())
+
+	// the cached API key is used while the file can't be reloaded
+	assert.NoError(t, os.Remove(path))
+	apiKey, err := cp.GetApiKeyWithContext(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "apikey22", apiKey)
+	assert.Error(t, cp.Err())
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte("apikey3\n"), 0600))
+	assert.Equal(t, "apikey3", cp.GetApiKey())
+	assert.NoError(t, cp.Err())
+}
+
+func TestNewFileCredentialsProviderWithoutFile(t *testing.T) {
+	_, err := NewFileCredentialsProvider(filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
+
+func TestChainCredentialsProvider(t *testing.T) {
+	static, _ := NewStaticCredentialsProvider("static")
+	cp, err := NewChainCredentialsProvider(NewEnvCredentialsProvider("FC_TEST_API_KEY"), static)
+	assert.NoError(t, err)
+	assert.Equal(t, "static", cp.GetApiKey())
+
+	setTestEnv(t, "FC_TEST_API_KEY", "env")
+	assert.Equal(t, "env", cp.GetApiKey())
+
+	_, err = NewChainCredentialsProvider()
+	assert.EqualError(t, err, "FullContactError: At least 1 CredentialsProvider is required for the chain")
+}
+
+func TestPoolCredentialsProviderFailover(t *testing.T) {
+	now := time.Now()
+	cp, err := NewPoolCredentialsProvider([]string{"k1", "k2", "k3"}, WithRateLimitCooldown(time.Minute))
+	assert.NoError(t, err)
+	cp.now = func() time.Time { return now }
+
+	assert.Equal(t, "k1", cp.GetApiKey())
+	assert.Equal(t, "k2", cp.GetApiKey())
+	assert.Equal(t, "k3", cp.GetApiKey())
+
+	cp.ReportResponse("k1", 401)
+	cp.ReportResponse("k2", 429)
+	assert.Equal(t, "k3", cp.GetApiKey())
+	assert.Equal(t, "k3", cp.GetApiKey())
+
+	cp.ReportResponse("k3", 403)
+	_, err = cp.GetApiKeyWithContext(context.Background())
+	assert.EqualError(t, err, "FullContactError: All API Keys in the pool are unavailable")
+
+	now = now.Add(2 * time.Minute)
+	assert.Equal(t, "k2", cp.GetApiKey())
+}
+
+func TestPoolCredentialsProviderWithClient(t *testing.T) {
+	cp, err := NewPoolCredentialsProvider([]string{"k1", "k2"})
+	assert.NoError(t, err)
+	used := make([]string, 0)
+	transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		used = append(used, req.Header.Get("Authorization"))
+		if req.Header.Get("Authorization") == "Bearer k1" {
+			return chaosResponse(req, 401, ""), nil
+		}
+		return chaosResponse(req, 200, "{}"), nil
+	})
+	fcTestClient, err := NewFullContactClient(
+		WithCredentialsProvider(cp),
+		WithHTTPClient(&http.Client{Transport: transport}))
+	assert.NoError(t, err)
+
+	ch := make(chan *APIResponse)
+	for i := 0; i < 3; i++ {
+		go fcTestClient.do(tagsGetUrl, nil, ch)
+		resp := <-ch
+		assert.Equal(t, 200, resp.StatusCode)
+	}
+	assert.Equal(t, []string{"Bearer k1", "Bearer k2", "Bearer k2", "Bearer k2"}, used)
+}
+
+func TestPoolCredentialsProviderFailoverOnce(t *testing.T) {
+	used := make([]string, 0)
+	transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		used = append(used, req.Header.Get("Authorization"))
+		return chaosResponse(req, 403, ""), nil
+	})
+	cp, err := NewPoolCredentialsProvider([]string{"k1", "k2", "k3"})
+	assert.NoError(t, err)
+	fcTestClient, err := NewFullContactClient(
+		WithCredentialsProvider(cp),
+		WithHTTPClient(&http.Client{Transport: transport}))
+	assert.NoError(t, err)
+
+	ch := make(chan *APIResponse)
+	go fcTestClient.do(tagsGetUrl, nil, ch)
+	assert.Equal(t, 403, (<-ch).StatusCode)
+	assert.Equal(t, []string{"Bearer k1", "Bearer k2"}, used)
+
+	single, err := NewPoolCredentialsProvider([]string{"k1"})
+	assert.NoError(t, err)
+	used = used[:0]
+	go fcTestClient.do(tagsGetUrl, nil, ch, WithCallCredentialsProvider(single))
+	assert.Equal(t, 403, (<-ch).StatusCode)
+	assert.Equal(t, []string{"Bearer k1"}, used)
+
+	static, _ := NewStaticCredentialsProvider("static")
+	used = used[:0]
+	go fcTestClient.do(tagsGetUrl, nil, ch, WithCallCredentialsProvider(static))
+	assert.Equal(t, 403, (<-ch).StatusCode)
+	assert.Equal(t, []string{"Bearer static"}, used)
+}
@@ -0,0 +1,383 @@
+package fullcontact
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	ErasureDiscover         = "identity.resolve"
+	ErasureTagsDelete       = "tags.delete"
+	ErasureIdentityDelete   = "identity.delete"
+	ErasurePermissionDelete = "permission.delete"
+
+	erasureStorePrefix = "erasure/"
+)
+
+type ErasureStepStatus string
+
+const (
+	ErasurePending   ErasureStepStatus = "pending"
+	ErasureSucceeded ErasureStepStatus = "succeeded"
+	ErasureFailed    ErasureStepStatus = "failed"
+)
+
+// ErasureStep is a single step of an erasure, e.g. the Identity Delete of a record.
+type ErasureStep struct {
+	Action     string            `json:"action"`
+	RecordId   string            `json:"recordId,omitempty"`
+	Status     ErasureStepStatus `json:"status"`
+	Attempts   int               `json:"attempts"`
+	StatusCode int               `json:"statusCode,omitempty"`
+	Outcome    Outcome           `json:"outcome"`
+	Error      string            `json:"error,omitempty"`
+	Time       time.Time         `json:"time"`
+}
+
+/*
+ErasureAudit records every step of the erasure of a data subject. It is signed with HMAC-SHA256
+whenever it is updated, see VerifyErasureAudit.
+
+The Identifiers of the subject are only kept until the erasure is completed, so that the audit
+doesn't retain the data erased. IdentifiersHash, a HMAC-SHA256 of the identifiers, remains to
+match later requests for the same subject.
+*/
+type ErasureAudit struct {
+	ErasureId       string             `json:"erasureId"`
+	Identifiers     *MultifieldRequest `json:"identifiers,omitempty"`
+	IdentifiersHash string             `json:"identifiersHash"`
+	RecordIds       []string           `json:"recordIds"`
+	Steps           []*ErasureStep     `json:"steps"`
+	StartedAt       time.Time          `json:"startedAt"`
+	UpdatedAt       time.Time          `json:"updatedAt"`
+	Completed       bool               `json:"completed"`
+	Signature       string             `json:"signature"`
+}
+
+// VerifyErasureAudit returns an error if the audit wasn't signed with the signing key, or was modified since.
+func VerifyErasureAudit(audit *ErasureAudit, signingKey []byte) error {
+	expected, err := signErasureAudit(audit, signingKey)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(expected), []byte(audit.Signature)) {
+		return NewFullContactError("Invalid signature of erasure audit: " + audit.ErasureId)
+	}
+	return nil
+}
+
+func signErasureAudit(audit *ErasureAudit, signingKey []byte) (string, error) {
+	unsigned := *audit
+	unsigned.Signature = ""
+	value, err := json.Marshal(&unsigned)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, signingKey)
+	mac.Write(value)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// hashErasureIdentifiers returns the HMAC-SHA256 of the identifiers, keyed so that they can't be guessed from it.
+func hashErasureIdentifiers(identifiers *MultifieldRequest, signingKey []byte) (string, error) {
+	value, err := json.Marshal(identifiers)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, signingKey)
+	mac.Write([]byte("identifiers:"))
+	mac.Write(value)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+type ErasureOption func(ew *ErasureWorkflow)
+
+/*
+ErasureWorkflow deletes a data subject from FullContact. The recordIds of the subject are discovered
+with Identity Resolve, from its identifiers and from the personIds resolved, then the tags and the
+identity of every record are deleted with Tags Delete and Identity Delete, and the permissions of the
+subject with Permission Delete.
+
+Failed steps are retried, and the ErasureAudit is saved to the Store after every step, so an
+interrupted or failed erasure can be resumed with Resume, without repeating the steps which succeeded.
+*/
+type ErasureWorkflow struct {
+	client      Client
+	store       Store
+	signingKey  []byte
+	maxAttempts int
+	retryDelay  time.Duration
+	now         func() time.Time
+}
+
+func NewErasureWorkflow(client Client, store Store, signingKey []byte, options ...ErasureOption) (*ErasureWorkflow, error) {
+	if client == nil {
+		return nil, NewFullContactError("Client can't be nil")
+	}
+	if store == nil {
+		return nil, NewFullContactError("Store must be present for erasure")
+	}
+	if len(signingKey) == 0 {
+		return nil, NewFullContactError("Signing key must be present for erasure")
+	}
+	ew := &ErasureWorkflow{
+		client:      client,
+		store:       store,
+		signingKey:  signingKey,
+		maxAttempts: 3,
+		retryDelay:  time.Second,
+		now:         time.Now,
+	}
+	for _, opts := range options {
+		opts(ew)
+	}
+	if ew.maxAttempts < 1 {
+		ew.maxAttempts = 1
+	}
+	return ew, nil
+}
+
+// WithErasureRetries sets the attempts of every step, 3 by default, and the initial delay between them, 1 second by default.
+func WithErasureRetries(maxAttempts int, retryDelay time.Duration) ErasureOption {
+	return func(ew *ErasureWorkflow) {
+		ew.maxAttempts = maxAttempts
+		ew.retryDelay = retryDelay
+	}
+}
+
+/*
+Erase deletes the data subject identified by the identifiers, returning the audit of the erasure and
+an error if a step failed. An erasure id already used with the same identifiers resumes the erasure,
+or returns its audit if it is completed, and fails with other identifiers.
+*/
+func (ew *ErasureWorkflow) Erase(ctx context.Context, erasureId string, multifieldRequest *MultifieldRequest, options ...CallOption) (*ErasureAudit, error) {
+	if !isPopulated(erasureId) {
+		return nil, NewFullContactError("Erasure id must be present")
+	}
+	if multifieldRequest == nil {
+		return nil, NewFullContactError("MultifieldRequest can't be nil")
+	}
+	identifiersHash, err := hashErasureIdentifiers(multifieldRequest, ew.signingKey)
+	if err != nil {
+		return nil, err
+	}
+	audit, err := ew.Audit(erasureId)
+	if err != nil {
+		return nil, err
+	}
+	if audit != nil && !hmac.Equal([]byte(audit.IdentifiersHash), []byte(identifiersHash)) {
+		return nil, NewFullContactError("Erasure " + erasureId + " was started with other identifiers")
+	}
+	if audit == nil {
+		now := ew.now()
+		audit = &ErasureAudit{
+			ErasureId:       erasureId,
+			Identifiers:     multifieldRequest,
+			IdentifiersHash: identifiersHash,
+			RecordIds:       make([]string, 0),
+			Steps:           []*ErasureStep{{Action: ErasureDiscover, Status: ErasurePending}},
+			StartedAt:       now,
+			UpdatedAt:       now,
+		}
+		if err = ew.save(audit); err != nil {
+			return nil, err
+		}
+	}
+	return ew.run(ctx, audit, options)
+}
+
+// Resume runs the steps of an erasure which didn't succeed yet.
+func (ew *ErasureWorkflow) Resume(ctx context.Context, erasureId string, options ...CallOption) (*ErasureAudit, error) {
+	audit, err := ew.Audit(erasureId)
+	if err != nil {
+		return nil, err
+	}
+	if audit == nil {
+		return nil, NewFullContactError("Unknown erasure: " + erasureId)
+	}
+	return ew.run(ctx, audit, options)
+}
+
+// Pending returns the sorted ids of the erasures which aren't completed.
+func (ew *ErasureWorkflow) Pending() ([]string, error) {
+	keys, err := ew.store.Keys(erasureStorePrefix)
+	if err != nil {
+		return nil, err
+	}
+	pending := make([]string, 0)
+	for _, key := range keys {
+		audit, err := ew.Audit(strings.TrimPrefix(key, erasureStorePrefix))
+		if err != nil {
+			return nil, err
+		}
+		if audit != nil && !audit.Completed {
+			pending = append(pending, audit.ErasureId)
+		}
+	}
+	sort.Strings(pending)
+	return pending, nil
+}
+
+// Audit returns the audit of the erasure after verifying its signature, or nil if the erasure is unknown.
+func (ew *ErasureWorkflow) Audit(erasureId string) (*ErasureAudit, error) {
+	value, err := ew.store.Load(erasureStorePrefix + erasureId)
+	if err != nil || value == nil {
+		return nil, err
+	}
+	var audit ErasureAudit
+	if err = json.Unmarshal(value, &audit); err != nil {
+		return nil, err
+	}
+	if err = VerifyErasureAudit(&audit, ew.signingKey); err != nil {
+		return nil, err
+	}
+	return &audit, nil
+}
+
+func (ew *ErasureWorkflow) save(audit *ErasureAudit) error {
+	audit.UpdatedAt = ew.now()
+	signature, err := signErasureAudit(audit, ew.signingKey)
+	if err != nil {
+		return err
+	}
+	audit.Signature = signature
+	value, err := json.Marshal(audit)
+	if err != nil {
+		return err
+	}
+	return ew.store.Save(erasureStorePrefix+audit.ErasureId, value)
+}
+
+func (ew *ErasureWorkflow) run(ctx context.Context, audit *ErasureAudit, options []CallOption) (*ErasureAudit, error) {
+	if audit.Completed {
+		return audit, nil
+	}
+	options = append([]CallOption{WithCallContext(ctx)}, options...)
+	// steps are appended once the records are discovered
+	for i := 0; i < len(audit.Steps); i++ {
+		step := audit.Steps[i]
+		if step.Status == ErasureSucceeded {
+			continue
+		}
+		if ctx.Err() != nil {
+			return audit, ctx.Err()
+		}
+		recordIds := ew.execute(ctx, audit, step, options)
+		if step.Action == ErasureDiscover && step.Status == ErasureSucceeded {
+			audit.RecordIds = recordIds
+			for _, recordId := range recordIds {
+				audit.Steps = append(audit.Steps,
+					&ErasureStep{Action: ErasureTagsDelete, RecordId: recordId, Status: ErasurePending},
+					&ErasureStep{Action: ErasureIdentityDelete, RecordId: recordId, Status: ErasurePending})
+			}
+			audit.Steps = append(audit.Steps, &ErasureStep{Action: ErasurePermissionDelete, Status: ErasurePending})
+		}
+		if err := ew.save(audit); err != nil {
+			return audit, err
+		}
+		if step.Action == ErasureDiscover && step.Status != ErasureSucceeded {
+			break
+		}
+	}
+
+	failed := 0
+	for _, step := range audit.Steps {
+		if step.Status != ErasureSucceeded {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return audit, NewFullContactError(fmt.Sprintf("Erasure %s incomplete, %d steps failed", audit.ErasureId, failed))
+	}
+	audit.Completed = true
+	// The identifiers are personal data, which the audit of a completed erasure mustn't retain.
+	audit.Identifiers = nil
+	return audit, ew.save(audit)
+}
+
+// execute runs the step with retries, returning the recordIds discovered for the discovery step.
+func (ew *ErasureWorkflow) execute(ctx context.Context, audit *ErasureAudit, step *ErasureStep, options []CallOption) []string {
+	delay := ew.retryDelay
+	for attempt := 1; ; attempt++ {
+		step.Attempts++
+		recordIds, resp := ew.attempt(audit, step, options)
+		step.Time = ew.now()
+		step.StatusCode = resp.StatusCode
+		step.Outcome = resp.GetOutcome()
+		step.Error = ""
+		if resp.Err != nil {
+			step.Error = resp.Err.Error()
+		} else if resp.IsError() {
+			step.Error = fmt.Sprintf("%s failed with status %d", step.Action, resp.StatusCode)
+		}
+		if !isPopulated(step.Error) {
+			step.Status = ErasureSucceeded
+			return recordIds
+		}
+		step.Status = ErasureFailed
+		if attempt >= ew.maxAttempts || !resp.IsRetryable() {
+			return nil
+		}
+		select {
+		case <-time.After(delay):
+			delay *= 2
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (ew *ErasureWorkflow) attempt(audit *ErasureAudit, step *ErasureStep, options []CallOption) ([]string, *APIResponse) {
+	switch step.Action {
+	case ErasureDiscover:
+		return ew.discover(audit.Identifiers, options)
+	case ErasureTagsDelete:
+		resp := <-ew.client.TagsGet(step.RecordId, options...)
+		if resp.IsError() || resp.IsNoMatch() || resp.TagsResponse == nil || len(resp.TagsResponse.Tags) == 0 {
+			return nil, resp
+		}
+		tags := make([]*Tag, len(resp.TagsResponse.Tags))
+		for i := range resp.TagsResponse.Tags {
+			tags[i] = &resp.TagsResponse.Tags[i]
+		}
+		return nil, <-ew.client.TagsDelete(&TagsRequest{RecordId: step.RecordId, Tags: tags}, options...)
+	case ErasureIdentityDelete:
+		return nil, <-ew.client.IdentityDelete(&ResolveRequest{RecordId: step.RecordId}, options...)
+	case ErasurePermissionDelete:
+		return nil, <-ew.client.PermissionDelete(audit.Identifiers, options...)
+	}
+	return nil, &APIResponse{Err: NewFullContactError("Unknown erasure step: " + step.Action)}
+}
+
+// discover resolves the recordIds of the identifiers, and of the personIds they resolve to.
+func (ew *ErasureWorkflow) discover(identifiers *MultifieldRequest, options []CallOption) ([]string, *APIResponse) {
+	recordIds := make([]string, 0)
+	if isPopulated(identifiers.RecordId) {
+		recordIds = append(recordIds, identifiers.RecordId)
+	}
+	resp := <-ew.client.IdentityResolve(resolveRequestFromMultifield(identifiers), options...)
+	if !resp.IsMatched() || resp.ResolveResponse == nil {
+		return recordIds, resp
+	}
+	recordIds = append(recordIds, resp.ResolveResponse.RecordIds...)
+	for _, personId := range resp.ResolveResponse.PersonIds {
+		if personId == identifiers.PersonId {
+			continue
+		}
+		personResp := <-ew.client.IdentityResolve(&ResolveRequest{PersonId: personId}, options...)
+		if personResp.IsError() {
+			return nil, personResp
+		}
+		if personResp.ResolveResponse != nil {
+			recordIds = append(recordIds, personResp.ResolveResponse.RecordIds...)
+		}
+	}
+	return sortedUnique(recordIds), resp
+}
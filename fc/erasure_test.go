@@ -0,0 +1,130 @@
+package fullcontact
+
+import (
+	"context"
+	"encoding/json"
+	assert "github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+var erasureTestKey = []byte("erasure-signing-key")
+
+func getErasureTestClient() *MockClient {
+	mockClient := NewMockClient()
+	mockClient.On("IdentityResolve", func(args ...interface{}) *APIResponse {
+		if args[0].(*ResolveRequest).PersonId == "p1" {
+			return &APIResponse{StatusCode: 200, ResolveResponse: &ResolveResponse{RecordIds: []string{"r1", "r2"}, PersonIds: []string{"p1"}}}
+		}
+		return &APIResponse{StatusCode: 200, ResolveResponse: &ResolveResponse{RecordIds: []string{"r1"}, PersonIds: []string{"p1"}}}
+	})
+	mockClient.On("TagsGet", func(args ...interface{}) *APIResponse {
+		if args[0].(string) == "r2" {
+			return &APIResponse{StatusCode: 404}
+		}
+		return &APIResponse{StatusCode: 200, TagsResponse: &TagsResponse{RecordId: "r1", Tags: []Tag{{Key: "segment", Value: "vip"}}}}
+	})
+	mockClient.Respond("TagsDelete", &APIResponse{StatusCode: 204})
+	mockClient.Respond("IdentityDelete", &APIResponse{StatusCode: 204})
+	mockClient.Respond("PermissionDelete", &APIResponse{StatusCode: 200})
+	return mockClient
+}
+
+func TestErasureWorkflowErase(t *testing.T) {
+	store := NewMemoryStore()
+	mockClient := getErasureTestClient()
+	workflow, err := NewErasureWorkflow(mockClient, store, erasureTestKey)
+	assert.NoError(t, err)
+
+	identifiers := &MultifieldRequest{Emails: []string{"marquitaross006@gmail.com"}}
+	audit, err := workflow.Erase(context.Background(), "dsr-1", identifiers)
+	assert.NoError(t, err)
+	assert.True(t, audit.Completed)
+	assert.Equal(t, []string{"r1", "r2"}, audit.RecordIds)
+	assert.Len(t, audit.Steps, 6)
+	for _, step := range audit.Steps {
+		assert.Equal(t, ErasureSucceeded, step.Status, step.Action)
+	}
+	assert.NoError(t, VerifyErasureAudit(audit, erasureTestKey))
+
+	assert.Len(t, mockClient.CallsTo("TagsDelete"), 1)
+	deletedTags := mockClient.CallsTo("TagsDelete")[0].Args[0].(*TagsRequest)
+	assert.Equal(t, &TagsRequest{RecordId: "r1", Tags: []*Tag{{Key: "segment", Value: "vip"}}}, deletedTags)
+	assert.Len(t, mockClient.CallsTo("IdentityDelete"), 2)
+	assert.Equal(t, identifiers, mockClient.CallsTo("PermissionDelete")[0].Args[0])
+
+	assert.Nil(t, audit.Identifiers)
+	saved, _ := store.Load("erasure/dsr-1")
+	assert.NotContains(t, string(saved), "marquitaross006@gmail.com")
+
+	again, err := workflow.Erase(context.Background(), "dsr-1", identifiers)
+	assert.NoError(t, err)
+	assert.Equal(t, audit.Signature, again.Signature)
+	assert.Len(t, mockClient.CallsTo("IdentityDelete"), 2)
+
+	_, err = workflow.Erase(context.Background(), "dsr-1", &MultifieldRequest{Emails: []string{"other@example.com"}})
+	assert.EqualError(t, err, "FullContactError: Erasure dsr-1 was started with other identifiers")
+}
+
+func TestErasureWorkflowRetriesAndResumes(t *testing.T) {
+	store := NewMemoryStore()
+	mockClient := getErasureTestClient()
+	identityDeletes := 0
+	mockClient.On("IdentityDelete", func(args ...interface{}) *APIResponse {
+		identityDeletes++
+		if args[0].(*ResolveRequest).RecordId == "r2" && identityDeletes < 5 {
+			return &APIResponse{StatusCode: 503}
+		}
+		return &APIResponse{StatusCode: 204}
+	})
+	workflow, _ := NewErasureWorkflow(mockClient, store, erasureTestKey, WithErasureRetries(2, time.Millisecond))
+
+	audit, err := workflow.Erase(context.Background(), "dsr-2", &MultifieldRequest{Emails: []string{"marquitaross006@gmail.com"}})
+	assert.EqualError(t, err, "FullContactError: Erasure dsr-2 incomplete, 1 steps failed")
+	assert.False(t, audit.Completed)
+	failed := audit.Steps[4]
+	assert.Equal(t, ErasureIdentityDelete, failed.Action)
+	assert.Equal(t, "r2", failed.RecordId)
+	assert.Equal(t, ErasureFailed, failed.Status)
+	assert.Equal(t, 2, failed.Attempts)
+	assert.Equal(t, OutcomeServerError, failed.Outcome)
+	assert.Equal(t, ErasureSucceeded, audit.Steps[5].Status)
+
+	assert.NotNil(t, audit.Identifiers)
+	pending, err := workflow.Pending()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"dsr-2"}, pending)
+
+	audit, err = workflow.Resume(context.Background(), "dsr-2")
+	assert.NoError(t, err)
+	assert.True(t, audit.Completed)
+	assert.Equal(t, 4, audit.Steps[4].Attempts)
+	assert.Len(t, mockClient.CallsTo("PermissionDelete"), 1)
+	pending, _ = workflow.Pending()
+	assert.Empty(t, pending)
+}
+
+func TestErasureAuditTampering(t *testing.T) {
+	store := NewMemoryStore()
+	workflow, _ := NewErasureWorkflow(getErasureTestClient(), store, erasureTestKey)
+	audit, err := workflow.Erase(context.Background(), "dsr-3", &MultifieldRequest{RecordId: "r1"})
+	assert.NoError(t, err)
+	assert.Error(t, VerifyErasureAudit(audit, []byte("other-key")))
+
+	audit.Steps[0].Status = ErasureFailed
+	value, _ := json.Marshal(audit)
+	assert.NoError(t, store.Save("erasure/dsr-3", value))
+	_, err = workflow.Audit("dsr-3")
+	assert.EqualError(t, err, "FullContactError: Invalid signature of erasure audit: dsr-3")
+}
+
+func TestNewErasureWorkflowInvalid(t *testing.T) {
+	_, err := NewErasureWorkflow(NewMockClient(), NewMemoryStore(), nil)
+	assert.EqualError(t, err, "FullContactError: Signing key must be present for erasure")
+	_, err = NewErasureWorkflow(NewMockClient(), nil, erasureTestKey)
+	assert.EqualError(t, err, "FullContactError: Store must be present for erasure")
+
+	workflow, _ := NewErasureWorkflow(NewMockClient(), NewMemoryStore(), erasureTestKey)
+	_, err = workflow.Resume(context.Background(), "unknown")
+	assert.EqualError(t, err, "FullContactError: Unknown erasure: unknown")
+}
@@ -6,39 +6,74 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"strings"
 	"time"
 )
 
-func (fcClient *fullContactClient) newHttpRequest(url string, reqBytes []byte) (*http.Request, error) {
+func (fcClient *fullContactClient) newHttpRequest(url string, reqBytes []byte, co *callOptions) (*http.Request, error) {
 	var method string
-	var buffer *bytes.Buffer
+	// A nil *bytes.Buffer in an io.Reader isn't a nil body, so the body is only set for POST.
+	var body io.Reader
 
 	if isHttpGet(url) {
 		method = "GET"
 		url = url + "?" + string(reqBytes)
-		buffer = nil
 	} else {
 		method = "POST"
-		buffer = bytes.NewBuffer(reqBytes)
+		body = bytes.NewBuffer(reqBytes)
 	}
 
-	req, err := http.NewRequest(method, url, buffer)
+	req, err := http.NewRequestWithContext(co.ctx, method, url, body)
 	if err != nil {
 		return nil, err
 	}
-	req = fcClient.addHeaders(req)
-	return req, nil
-
+	return fcClient.addHeaders(req, co)
 }
 
-func (fcClient *fullContactClient) addHeaders(req *http.Request) *http.Request {
-	for k, v := range fcClient.headers {
+func (fcClient *fullContactClient) addHeaders(req *http.Request, co *callOptions) (*http.Request, error) {
+	apiKey, err := getApiKey(req.Context(), co.credentialsProvider)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range co.headers {
 		req.Header.Add(k, v)
 	}
-	req.Header.Add("Authorization", "Bearer "+fcClient.credentialsProvider.GetApiKey())
+	req.Header.Add("Authorization", "Bearer "+apiKey)
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Add("User-Agent", userAgent)
-	return req
+	return req, nil
+}
+
+/*
+send executes the request and reports the response status to the credentials provider. When the
+provider takes feedback, a 401 or 403 is retried once with the next API key it returns, so that
+a revoked key in a pool fails over without failing the request.
+*/
+func (fcClient *fullContactClient) send(req *http.Request, url string, reqBytes []byte, co *callOptions) (*http.Response, error) {
+	var resp *http.Response
+	var err error
+	if co.download != nil {
+		resp, err = sendDownload(req, co)
+	} else {
+		resp, err = co.httpClient.Do(req)
+	}
+	feedback, ok := co.credentialsProvider.(CredentialsFeedback)
+	if !ok || resp == nil {
+		return resp, err
+	}
+	apiKey := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	feedback.ReportResponse(apiKey, resp.StatusCode)
+	if err != nil || co.authFailedOver || (resp.StatusCode != http.StatusUnauthorized && resp.StatusCode != http.StatusForbidden) {
+		return resp, err
+	}
+	co.authFailedOver = true
+	failoverReq, reqErr := fcClient.newHttpRequest(url, reqBytes, co)
+	if reqErr != nil || failoverReq.Header.Get("Authorization") == req.Header.Get("Authorization") {
+		// No other API key is available, so the auth failure is the response.
+		return resp, err
+	}
+	resp.Body.Close()
+	return fcClient.send(failoverReq, url, reqBytes, co)
 }
 
 func isHttpGet(url string) bool {
@@ -53,60 +88,102 @@ func isHttpGet(url string) bool {
 	return false
 }
 
-func (fcClient *fullContactClient) do(url string, reqBytes []byte, ch chan *APIResponse) {
-	req, err := fcClient.newHttpRequest(url, reqBytes)
+func (fcClient *fullContactClient) do(url string, reqBytes []byte, ch chan *APIResponse, options ...CallOption) {
+	co := fcClient.newCallOptions(options)
+	if fcClient.usageLedger != nil {
+		err := fcClient.usageLedger.allow(endpointName(url))
+		if err != nil {
+			fcClient.respond(ch, nil, url, reqBytes, err, co)
+			return
+		}
+		co.usageReserved = endpointName(url)
+	}
+	req, err := fcClient.newHttpRequest(url, reqBytes, co)
 	if err != nil {
-		sendToChannel(ch, nil, url, err)
+		fcClient.respond(ch, nil, url, reqBytes, err, co)
+		return
 	}
 
-	resp, err := fcClient.httpClient.Do(req) //first attempt
+	resp, err := fcClient.send(req, url, reqBytes, co) //first attempt
 
 	if err != nil {
-		fcClient.autoRetry(ch, err, resp, 0, url, reqBytes)
-	} else if resp != nil && !fcClient.retryHandler.ShouldRetry(resp.StatusCode) {
-		sendToChannel(ch, resp, url, nil)
+		fcClient.autoRetry(ch, err, resp, 0, url, reqBytes, co)
+	} else if resp != nil && !co.retryHandler.ShouldRetry(resp.StatusCode) {
+		fcClient.respond(ch, resp, url, reqBytes, nil, co)
 	} else {
-		fcClient.autoRetry(ch, nil, resp, 0, url, reqBytes)
+		fcClient.autoRetry(ch, nil, resp, 0, url, reqBytes, co)
 	}
 }
 
-func (fcClient *fullContactClient) autoRetry(ch chan *APIResponse, err error, resp *http.Response, retryAttemptsDone int, url string, reqBytes []byte) {
-	if retryAttemptsDone < min(fcClient.retryHandler.RetryAttempts(), 5) {
+func (fcClient *fullContactClient) autoRetry(ch chan *APIResponse, err error, resp *http.Response, retryAttemptsDone int, url string, reqBytes []byte, co *callOptions) {
+	if retryAttemptsDone < min(co.retryHandler.RetryAttempts(), 5) && co.ctx.Err() == nil {
 		retryAttemptsDone++
-		time.Sleep(time.Duration(fcClient.retryHandler.RetryDelayMillis()*(1<<(retryAttemptsDone-1))) * time.Millisecond)
-		req, err := fcClient.newHttpRequest(url, reqBytes)
+		select {
+		case <-time.After(time.Duration(co.retryHandler.RetryDelayMillis()*(1<<(retryAttemptsDone-1))) * time.Millisecond):
+		case <-co.ctx.Done():
+			fcClient.respond(ch, nil, url, reqBytes, co.ctx.Err(), co)
+			return
+		}
+		req, err := fcClient.newHttpRequest(url, reqBytes, co)
 		if err != nil {
-			sendToChannel(ch, nil, url, err)
+			fcClient.respond(ch, nil, url, reqBytes, err, co)
+			return
 		}
-		resp, err = fcClient.httpClient.Do(req)
+		resp, err = fcClient.send(req, url, reqBytes, co)
 		if err != nil {
-			fcClient.autoRetry(ch, err, resp, retryAttemptsDone, url, reqBytes)
-		} else if resp != nil && !fcClient.retryHandler.ShouldRetry(resp.StatusCode) {
-			sendToChannel(ch, resp, url, nil)
+			fcClient.autoRetry(ch, err, resp, retryAttemptsDone, url, reqBytes, co)
+		} else if resp != nil && !co.retryHandler.ShouldRetry(resp.StatusCode) {
+			fcClient.respond(ch, resp, url, reqBytes, nil, co)
 		} else {
-			fcClient.autoRetry(ch, nil, resp, retryAttemptsDone, url, reqBytes)
+			fcClient.autoRetry(ch, nil, resp, retryAttemptsDone, url, reqBytes, co)
 		}
 	} else if err != nil {
-		sendToChannel(ch, nil, url, err)
+		fcClient.respond(ch, nil, url, reqBytes, err, co)
 	} else {
-		sendToChannel(ch, resp, url, nil)
+		fcClient.respond(ch, resp, url, reqBytes, nil, co)
 	}
 
 }
 
 func sendToChannel(ch chan *APIResponse, response *http.Response, url string, err error) {
+	ch <- newAPIResponse(response, url, err)
+	return
+}
+
+// respond builds the APIResponse, notifies the call observers and sends the response to the channel.
+func (fcClient *fullContactClient) respond(ch chan *APIResponse, response *http.Response, url string, reqBytes []byte, err error, co *callOptions) {
+	if co.download != nil && response != nil && err == nil && response.StatusCode == 200 {
+		err = co.download.stream(response, co)
+	}
+	if co.cancel != nil {
+		co.cancel()
+	}
+	apiResponse := newAPIResponse(response, url, err)
+	if co.download != nil && apiResponse.AudienceResponse != nil {
+		apiResponse.AudienceResponse.BytesWritten = co.download.written
+	}
+	if len(fcClient.callObservers) > 0 {
+		call := &CallRecord{
+			Endpoint:         endpointName(responseUrl(response, url)),
+			Request:          reqBytes,
+			Response:         apiResponse,
+			reservedEndpoint: co.usageReserved,
+		}
+		for _, observer := range fcClient.callObservers {
+			observer.ObserveCall(call)
+		}
+	}
+	ch <- apiResponse
+}
+
+func newAPIResponse(response *http.Response, url string, err error) *APIResponse {
 	apiResponse := &APIResponse{
 		RawHttpResponse: response,
 		Err:             err,
 	}
 
+	url = responseUrl(response, url)
 	if response != nil {
-		//For Testing Purposes
-		testType := response.Header.Get(FCGoClientTestType)
-		if isPopulated(testType) {
-			url = testType
-		}
-
 		switch url {
 		case personEnrichUrl:
 			setPersonResponse(apiResponse)
@@ -138,8 +215,28 @@ func sendToChannel(ch chan *APIResponse, response *http.Response, url string, er
 			setVerfiyActivityResponse(apiResponse)
 		}
 	}
-	ch <- apiResponse
-	return
+	apiResponse.Outcome = classifyOutcome(url, apiResponse)
+	return apiResponse
+}
+
+func responseUrl(response *http.Response, url string) string {
+	//For Testing Purposes
+	if response != nil {
+		testType := response.Header.Get(FCGoClientTestType)
+		if isPopulated(testType) {
+			return testType
+		}
+	}
+	return url
+}
+
+// endpointName returns the name of the API endpoint of the url, e.g. "person.enrich".
+func endpointName(url string) string {
+	name := strings.TrimPrefix(url, baseUrl)
+	if i := strings.Index(name, "?"); i >= 0 {
+		name = name[:i]
+	}
+	return name
 }
 
 /*
@@ -147,7 +244,7 @@ func sendToChannel(ch chan *APIResponse, response *http.Response, url string, er
 
 Request is converted to JSON and sends a Asynchronous request
 */
-func (fcClient *fullContactClient) PersonEnrich(personRequest *PersonRequest) chan *APIResponse {
+func (fcClient *fullContactClient) PersonEnrich(personRequest *PersonRequest, options ...CallOption) chan *APIResponse {
 	ch := make(chan *APIResponse)
 
 	if personRequest == nil {
@@ -166,7 +263,7 @@ func (fcClient *fullContactClient) PersonEnrich(personRequest *PersonRequest) ch
 		return ch
 	}
 	// Send Asynchronous Request in Goroutine
-	go fcClient.do(personEnrichUrl, reqBytes, ch)
+	go fcClient.do(personEnrichUrl, reqBytes, ch, options...)
 	return ch
 }
 
@@ -175,7 +272,7 @@ func (fcClient *fullContactClient) PersonEnrich(personRequest *PersonRequest) ch
 
 Request is converted to JSON and sends a Asynchronous request
 */
-func (fcClient *fullContactClient) CompanyEnrich(companyRequest *CompanyRequest) chan *APIResponse {
+func (fcClient *fullContactClient) CompanyEnrich(companyRequest *CompanyRequest, options ...CallOption) chan *APIResponse {
 	ch := make(chan *APIResponse)
 	if companyRequest == nil {
 		go sendToChannel(ch, nil, "", NewFullContactError("Company Request can't be nil"))
@@ -193,7 +290,7 @@ func (fcClient *fullContactClient) CompanyEnrich(companyRequest *CompanyRequest)
 		return ch
 	}
 	// Send Asynchronous Request in Goroutine
-	go fcClient.do(companyEnrichUrl, reqBytes, ch)
+	go fcClient.do(companyEnrichUrl, reqBytes, ch, options...)
 	return ch
 }
 
@@ -203,18 +300,21 @@ func (fcClient *fullContactClient) CompanyEnrich(companyRequest *CompanyRequest)
 FullContact Resolve API - IdentityMap, takes an ResolveRequest and returns a channel of type APIResponse.
 Request is converted to JSON and sends a Asynchronous request
 */
-func (fcClient *fullContactClient) IdentityMap(resolveRequest *ResolveRequest) chan *APIResponse {
+func (fcClient *fullContactClient) IdentityMap(resolveRequest *ResolveRequest, options ...CallOption) chan *APIResponse {
 	ch := make(chan *APIResponse)
 	if resolveRequest == nil {
 		go sendToChannel(ch, nil, "", NewFullContactError("Resolve Request can't be nil"))
 		return ch
 	}
 	err := validateForIdentityMap(resolveRequest)
+	if err == nil {
+		err = fcClient.tagSchema.validateTags(resolveRequest.Tags)
+	}
 	if err != nil {
 		go sendToChannel(ch, nil, "", err)
 		return ch
 	}
-	return fcClient.resolveRequest(ch, resolveRequest, identityMapUrl)
+	return fcClient.resolveRequest(ch, resolveRequest, identityMapUrl, options)
 }
 
 /*
@@ -223,7 +323,7 @@ func (fcClient *fullContactClient) IdentityMap(resolveRequest *ResolveRequest) c
 FullContact Resolve API - IdentityResolve, takes an ResolveRequest and returns a channel of type APIResponse.
 Request is converted to JSON and sends a Asynchronous request
 */
-func (fcClient *fullContactClient) IdentityResolve(resolveRequest *ResolveRequest) chan *APIResponse {
+func (fcClient *fullContactClient) IdentityResolve(resolveRequest *ResolveRequest, options ...CallOption) chan *APIResponse {
 	ch := make(chan *APIResponse)
 	if resolveRequest == nil {
 		go sendToChannel(ch, nil, "", NewFullContactError("Resolve Request can't be nil"))
@@ -234,7 +334,7 @@ func (fcClient *fullContactClient) IdentityResolve(resolveRequest *ResolveReques
 		go sendToChannel(ch, nil, "", err)
 		return ch
 	}
-	return fcClient.resolveRequest(ch, resolveRequest, identityResolveUrl)
+	return fcClient.resolveRequest(ch, resolveRequest, identityResolveUrl, options)
 }
 
 /*
@@ -243,18 +343,21 @@ func (fcClient *fullContactClient) IdentityResolve(resolveRequest *ResolveReques
 FullContact Resolve API - IdentityMapResolve, takes an ResolveRequest and returns a channel of type APIResponse.
 Request is converted to JSON and sends a Asynchronous request
 */
-func (fcClient *fullContactClient) IdentityMapResolve(resolveRequest *ResolveRequest) chan *APIResponse {
+func (fcClient *fullContactClient) IdentityMapResolve(resolveRequest *ResolveRequest, options ...CallOption) chan *APIResponse {
 	ch := make(chan *APIResponse)
 	if resolveRequest == nil {
 		go sendToChannel(ch, nil, "", NewFullContactError("Resolve Request can't be nil"))
 		return ch
 	}
 	err := validateForIdentityMap(resolveRequest)
+	if err == nil {
+		err = fcClient.tagSchema.validateTags(resolveRequest.Tags)
+	}
 	if err != nil {
 		go sendToChannel(ch, nil, "", err)
 		return ch
 	}
-	return fcClient.resolveRequest(ch, resolveRequest, identityMapResolveUrl)
+	return fcClient.resolveRequest(ch, resolveRequest, identityMapResolveUrl, options)
 }
 
 /*
@@ -263,7 +366,7 @@ func (fcClient *fullContactClient) IdentityMapResolve(resolveRequest *ResolveReq
 FullContact Resolve API - IdentityResolve with Tags in response, takes an ResolveRequest and returns a channel of type APIResponse.
 Request is converted to JSON and sends a Asynchronous request
 */
-func (fcClient *fullContactClient) IdentityResolveWithTags(resolveRequest *ResolveRequest) chan *APIResponse {
+func (fcClient *fullContactClient) IdentityResolveWithTags(resolveRequest *ResolveRequest, options ...CallOption) chan *APIResponse {
 	ch := make(chan *APIResponse)
 	if resolveRequest == nil {
 		go sendToChannel(ch, nil, "", NewFullContactError("Resolve Request can't be nil"))
@@ -274,7 +377,7 @@ func (fcClient *fullContactClient) IdentityResolveWithTags(resolveRequest *Resol
 		go sendToChannel(ch, nil, "", err)
 		return ch
 	}
-	return fcClient.resolveRequest(ch, resolveRequest, identityResolveWithTagsUrl)
+	return fcClient.resolveRequest(ch, resolveRequest, identityResolveWithTagsUrl, options)
 }
 
 /*
@@ -283,7 +386,7 @@ func (fcClient *fullContactClient) IdentityResolveWithTags(resolveRequest *Resol
 FullContact Resolve API - IdentityDelete, takes an ResolveRequest and returns a channel of type APIResponse.
 Request is converted to JSON and sends a Asynchronous request
 */
-func (fcClient *fullContactClient) IdentityDelete(resolveRequest *ResolveRequest) chan *APIResponse {
+func (fcClient *fullContactClient) IdentityDelete(resolveRequest *ResolveRequest, options ...CallOption) chan *APIResponse {
 	ch := make(chan *APIResponse)
 	if resolveRequest == nil {
 		go sendToChannel(ch, nil, "", NewFullContactError("Resolve Request can't be nil"))
@@ -294,17 +397,17 @@ func (fcClient *fullContactClient) IdentityDelete(resolveRequest *ResolveRequest
 		go sendToChannel(ch, nil, "", err)
 		return ch
 	}
-	return fcClient.resolveRequest(ch, resolveRequest, identityDeleteUrl)
+	return fcClient.resolveRequest(ch, resolveRequest, identityDeleteUrl, options)
 }
 
-func (fcClient *fullContactClient) resolveRequest(ch chan *APIResponse, resolveRequest *ResolveRequest, url string) chan *APIResponse {
+func (fcClient *fullContactClient) resolveRequest(ch chan *APIResponse, resolveRequest *ResolveRequest, url string, options []CallOption) chan *APIResponse {
 	reqBytes, err := json.Marshal(resolveRequest)
 	if err != nil {
 		go sendToChannel(ch, nil, "", err)
 		return ch
 	}
 	// Send Asynchronous Request in Goroutine
-	go fcClient.do(url, reqBytes, ch)
+	go fcClient.do(url, reqBytes, ch, options...)
 	return ch
 }
 
@@ -313,12 +416,16 @@ func (fcClient *fullContactClient) resolveRequest(ch chan *APIResponse, resolveR
 
 Request is converted to JSON and sends a Asynchronous request
 */
-func (fcClient *fullContactClient) TagsCreate(tagsRequest *TagsRequest) chan *APIResponse {
+func (fcClient *fullContactClient) TagsCreate(tagsRequest *TagsRequest, options ...CallOption) chan *APIResponse {
 	ch := make(chan *APIResponse)
 	if tagsRequest == nil {
 		go sendToChannel(ch, nil, "", NewFullContactError("Tags Request can't be nil"))
 		return ch
 	}
+	if err := fcClient.tagSchema.validateTags(tagsRequest.Tags); err != nil {
+		go sendToChannel(ch, nil, "", err)
+		return ch
+	}
 	reqBytes, err := json.Marshal(tagsRequest)
 
 	if err != nil {
@@ -326,7 +433,7 @@ func (fcClient *fullContactClient) TagsCreate(tagsRequest *TagsRequest) chan *AP
 		return ch
 	}
 	// Send Asynchronous Request in Goroutine
-	go fcClient.do(tagsCreateUrl, reqBytes, ch)
+	go fcClient.do(tagsCreateUrl, reqBytes, ch, options...)
 	return ch
 }
 
@@ -335,7 +442,7 @@ func (fcClient *fullContactClient) TagsCreate(tagsRequest *TagsRequest) chan *AP
 
 Request is converted to JSON and sends a Asynchronous request
 */
-func (fcClient *fullContactClient) TagsGet(recordId string) chan *APIResponse {
+func (fcClient *fullContactClient) TagsGet(recordId string, options ...CallOption) chan *APIResponse {
 	ch := make(chan *APIResponse)
 	if !isPopulated(recordId) {
 		go sendToChannel(ch, nil, "", NewFullContactError("recordId can't be nil"))
@@ -344,7 +451,7 @@ func (fcClient *fullContactClient) TagsGet(recordId string) chan *APIResponse {
 	reqBytes := []byte("{\"recordId\":\"" + recordId + "\"}")
 
 	// Send Asynchronous Request in Goroutine
-	go fcClient.do(tagsGetUrl, reqBytes, ch)
+	go fcClient.do(tagsGetUrl, reqBytes, ch, options...)
 	return ch
 }
 
@@ -353,7 +460,7 @@ func (fcClient *fullContactClient) TagsGet(recordId string) chan *APIResponse {
 
 Request is converted to JSON and sends a Asynchronous request
 */
-func (fcClient *fullContactClient) TagsDelete(tagsRequest *TagsRequest) chan *APIResponse {
+func (fcClient *fullContactClient) TagsDelete(tagsRequest *TagsRequest, options ...CallOption) chan *APIResponse {
 	ch := make(chan *APIResponse)
 	if tagsRequest == nil {
 		go sendToChannel(ch, nil, "", NewFullContactError("Tags Request can't be nil"))
@@ -366,7 +473,7 @@ func (fcClient *fullContactClient) TagsDelete(tagsRequest *TagsRequest) chan *AP
 		return ch
 	}
 	// Send Asynchronous Request in Goroutine
-	go fcClient.do(tagsDeleteUrl, reqBytes, ch)
+	go fcClient.do(tagsDeleteUrl, reqBytes, ch, options...)
 	return ch
 }
 
@@ -375,12 +482,16 @@ func (fcClient *fullContactClient) TagsDelete(tagsRequest *TagsRequest) chan *AP
 
 Request is converted to JSON and sends a Asynchronous request
 */
-func (fcClient *fullContactClient) AudienceCreate(audienceRequest *AudienceRequest) chan *APIResponse {
+func (fcClient *fullContactClient) AudienceCreate(audienceRequest *AudienceRequest, options ...CallOption) chan *APIResponse {
 	ch := make(chan *APIResponse)
 	if audienceRequest == nil {
 		go sendToChannel(ch, nil, "", NewFullContactError("Audience Request can't be nil"))
 		return ch
 	}
+	if err := fcClient.tagSchema.validateTags(audienceRequest.Tags); err != nil {
+		go sendToChannel(ch, nil, "", err)
+		return ch
+	}
 	reqBytes, err := json.Marshal(audienceRequest)
 
 	if err != nil {
@@ -388,7 +499,7 @@ func (fcClient *fullContactClient) AudienceCreate(audienceRequest *AudienceReque
 		return ch
 	}
 	// Send Asynchronous Request in Goroutine
-	go fcClient.do(audienceCreateUrl, reqBytes, ch)
+	go fcClient.do(audienceCreateUrl, reqBytes, ch, options...)
 	return ch
 }
 
@@ -397,8 +508,50 @@ func (fcClient *fullContactClient) AudienceCreate(audienceRequest *AudienceReque
 
 Request is converted to JSON and sends a Asynchronous request
 */
-func (fcClient *fullContactClient) AudienceDownload(requestId string) chan *APIResponse {
+func (fcClient *fullContactClient) AudienceDownload(requestId string, options ...CallOption) chan *APIResponse {
+	ch := make(chan *APIResponse)
+	if !isPopulated(requestId) {
+		go sendToChannel(ch, nil, "", NewFullContactError("requestId can't be nil"))
+		return ch
+	}
+	reqBytes := []byte("requestId=" + requestId)
+
+	// Send Asynchronous Request in Goroutine
+	go fcClient.do(audienceDownloadUrl, reqBytes, ch, options...)
+	return ch
+}
+
+/*
+	FullContact API for downloading Audience created using 'AudienceCreate', takes a requestId and an io.Writer and returns a channel of type APIResponse.
+
+The audience file is streamed to the writer instead of being read into AudienceResponse.AudienceBytes,
+use WithCallDownloadProgress and WithCallDecompression to report the progress and decompress the file.
+*/
+func (fcClient *fullContactClient) AudienceDownloadTo(requestId string, writer io.Writer, options ...CallOption) chan *APIResponse {
 	ch := make(chan *APIResponse)
+	if writer == nil {
+		go sendToChannel(ch, nil, "", NewFullContactError("Writer can't be nil"))
+		return ch
+	}
+	return fcClient.audienceDownloadTo(ch, requestId, &audienceDownload{writer: writer}, options)
+}
+
+/*
+	FullContact API for downloading Audience created using 'AudienceCreate', takes a requestId and a file name and returns a channel of type APIResponse.
+
+The audience file is streamed to a temporary file renamed to fileName once complete, so fileName is
+never left partially written.
+*/
+func (fcClient *fullContactClient) AudienceDownloadToFile(requestId string, fileName string, options ...CallOption) chan *APIResponse {
+	ch := make(chan *APIResponse)
+	if !isPopulated(fileName) {
+		go sendToChannel(ch, nil, "", NewFullContactError("File name can't be empty"))
+		return ch
+	}
+	return fcClient.audienceDownloadTo(ch, requestId, &audienceDownload{fileName: fileName}, options)
+}
+
+func (fcClient *fullContactClient) audienceDownloadTo(ch chan *APIResponse, requestId string, download *audienceDownload, options []CallOption) chan *APIResponse {
 	if !isPopulated(requestId) {
 		go sendToChannel(ch, nil, "", NewFullContactError("requestId can't be nil"))
 		return ch
@@ -406,7 +559,8 @@ func (fcClient *fullContactClient) AudienceDownload(requestId string) chan *APIR
 	reqBytes := []byte("requestId=" + requestId)
 
 	// Send Asynchronous Request in Goroutine
-	go fcClient.do(audienceDownloadUrl, reqBytes, ch)
+	// the options are copied, as appending to the variadic slice of the caller could overwrite its spare capacity
+	go fcClient.do(audienceDownloadUrl, reqBytes, ch, append(append([]CallOption(nil), options...), withAudienceDownload(download))...)
 	return ch
 }
 
@@ -416,7 +570,7 @@ func (fcClient *fullContactClient) AudienceDownload(requestId string) chan *APIR
 FullContact Permission API - PermissionCreate, takes an PermissionRequest and returns a channel of type APIResponse.
 Request is converted to JSON and sends a Asynchronous request
 */
-func (fcClient *fullContactClient) PermissionCreate(permissionRequest *PermissionRequest) chan *APIResponse {
+func (fcClient *fullContactClient) PermissionCreate(permissionRequest *PermissionRequest, options ...CallOption) chan *APIResponse {
 	ch := make(chan *APIResponse)
 	if permissionRequest == nil {
 		go sendToChannel(ch, nil, "", NewFullContactError("Permission Request can't be nil"))
@@ -434,7 +588,7 @@ func (fcClient *fullContactClient) PermissionCreate(permissionRequest *Permissio
 		return ch
 	}
 	// Send Asynchronous Request in Goroutine
-	go fcClient.do(permissionCreateUrl, reqBytes, ch)
+	go fcClient.do(permissionCreateUrl, reqBytes, ch, options...)
 	return ch
 }
 
@@ -443,8 +597,8 @@ func (fcClient *fullContactClient) PermissionCreate(permissionRequest *Permissio
 
 Request is converted to JSON and sends a Asynchronous request
 */
-func (fcClient *fullContactClient) PermissionDelete(multifieldRequest *MultifieldRequest) chan *APIResponse {
-	return fcClient.validateAndSendMultiFieldRequestAsync(permissionDeleteUrl, multifieldRequest)
+func (fcClient *fullContactClient) PermissionDelete(multifieldRequest *MultifieldRequest, options ...CallOption) chan *APIResponse {
+	return fcClient.validateAndSendMultiFieldRequestAsync(permissionDeleteUrl, multifieldRequest, options)
 }
 
 /*
@@ -452,8 +606,8 @@ func (fcClient *fullContactClient) PermissionDelete(multifieldRequest *Multifiel
 
 Request is converted to JSON and sends a Asynchronous request
 */
-func (fcClient *fullContactClient) PermissionFind(multifieldRequest *MultifieldRequest) chan *APIResponse {
-	return fcClient.validateAndSendMultiFieldRequestAsync(permissionFindUrl, multifieldRequest)
+func (fcClient *fullContactClient) PermissionFind(multifieldRequest *MultifieldRequest, options ...CallOption) chan *APIResponse {
+	return fcClient.validateAndSendMultiFieldRequestAsync(permissionFindUrl, multifieldRequest, options)
 }
 
 /*
@@ -461,8 +615,8 @@ func (fcClient *fullContactClient) PermissionFind(multifieldRequest *MultifieldR
 
 Request is converted to JSON and sends a Asynchronous request
 */
-func (fcClient *fullContactClient) PermissionCurrent(multifieldRequest *MultifieldRequest) chan *APIResponse {
-	return fcClient.validateAndSendMultiFieldRequestAsync(permissionCurrentUrl, multifieldRequest)
+func (fcClient *fullContactClient) PermissionCurrent(multifieldRequest *MultifieldRequest, options ...CallOption) chan *APIResponse {
+	return fcClient.validateAndSendMultiFieldRequestAsync(permissionCurrentUrl, multifieldRequest, options)
 }
 
 /*
@@ -470,7 +624,7 @@ func (fcClient *fullContactClient) PermissionCurrent(multifieldRequest *Multifie
 
 Request is converted to JSON and sends a Asynchronous request
 */
-func (fcClient *fullContactClient) PermissionVerify(permissionRequest *PermissionRequest) chan *APIResponse {
+func (fcClient *fullContactClient) PermissionVerify(permissionRequest *PermissionRequest, options ...CallOption) chan *APIResponse {
 	ch := make(chan *APIResponse)
 	if permissionRequest == nil {
 		go sendToChannel(ch, nil, "", NewFullContactError("Permission Request can't be nil"))
@@ -488,7 +642,7 @@ func (fcClient *fullContactClient) PermissionVerify(permissionRequest *Permissio
 		return ch
 	}
 	// Send Asynchronous Request in Goroutine
-	go fcClient.do(permissionVerifyUrl, reqBytes, ch)
+	go fcClient.do(permissionVerifyUrl, reqBytes, ch, options...)
 	return ch
 }
 
@@ -498,8 +652,8 @@ FullContact Verify API - VerfiySignals, takes a MultiFieldRequest and returns a
 Request is converted to JSON and sends an Asynchronous request.
 Response will be avaiable in the VerifySignalsResponse field of APIResponse
 */
-func (fcClient *fullContactClient) VerifySignals(multifieldRequest *MultifieldRequest) chan *APIResponse {
-	return fcClient.validateAndSendMultiFieldRequestAsync(verifySignalsUrl, multifieldRequest)
+func (fcClient *fullContactClient) VerifySignals(multifieldRequest *MultifieldRequest, options ...CallOption) chan *APIResponse {
+	return fcClient.validateAndSendMultiFieldRequestAsync(verifySignalsUrl, multifieldRequest, options)
 }
 
 /*
@@ -508,8 +662,8 @@ FullContact Verify API - VerfiyMatch, takes a MultiFieldRequest and returns a ch
 Request is converted to JSON and sends an Asynchronous request.
 Response will be avaiable in the VerifyMatchResponse field of APIResponse
 */
-func (fcClient *fullContactClient) VerifyMatch(multifieldRequest *MultifieldRequest) chan *APIResponse {
-	return fcClient.validateAndSendMultiFieldRequestAsync(verifyMatchUrl, multifieldRequest)
+func (fcClient *fullContactClient) VerifyMatch(multifieldRequest *MultifieldRequest, options ...CallOption) chan *APIResponse {
+	return fcClient.validateAndSendMultiFieldRequestAsync(verifyMatchUrl, multifieldRequest, options)
 }
 
 /*
@@ -518,8 +672,8 @@ FullContact Verify API - VerfiyActivity, takes a MultiFieldRequest and returns a
 Request is converted to JSON and sends an Asynchronous request.
 Response will be avaiable in the VerifyActivityResponse field of APIResponse
 */
-func (fcClient *fullContactClient) VerifyActivity(multifieldRequest *MultifieldRequest) chan *APIResponse {
-	return fcClient.validateAndSendMultiFieldRequestAsync(verifyActivityUrl, multifieldRequest)
+func (fcClient *fullContactClient) VerifyActivity(multifieldRequest *MultifieldRequest, options ...CallOption) chan *APIResponse {
+	return fcClient.validateAndSendMultiFieldRequestAsync(verifyActivityUrl, multifieldRequest, options)
 }
 
 /*
@@ -529,7 +683,7 @@ made to the specified `url`
 
 Returns a channel frm which the request response can be obtained
 */
-func (fcClient *fullContactClient) validateAndSendMultiFieldRequestAsync(url string, multifieldRequest *MultifieldRequest) chan *APIResponse {
+func (fcClient *fullContactClient) validateAndSendMultiFieldRequestAsync(url string, multifieldRequest *MultifieldRequest, options []CallOption) chan *APIResponse {
 	ch := make(chan *APIResponse)
 	if multifieldRequest == nil {
 		go sendToChannel(ch, nil, "", NewFullContactError("MultiFieldRequest can't be nil"))
@@ -548,7 +702,7 @@ func (fcClient *fullContactClient) validateAndSendMultiFieldRequestAsync(url str
 		return ch
 	}
 	// Send Asynchronous Request in Goroutine
-	go fcClient.do(url, reqBytes, ch)
+	go fcClient.do(url, reqBytes, ch, options...)
 	return ch
 }
 
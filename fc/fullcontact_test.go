@@ -20,3 +20,9 @@ func getTestServerAndClient(testType, respJson string, statusCode int) (fullCont
 		retryHandler:        &DefaultRetryHandler{}}
 	return fcTestClient, testServer
 }
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (fn roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return fn(req)
+}
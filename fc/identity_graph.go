@@ -0,0 +1,448 @@
+package fullcontact
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const identityGraphStoreKey = "identity-graph"
+
+type IdentityNodeKind string
+
+const (
+	IdentityRecord  IdentityNodeKind = "record"
+	IdentityPerson  IdentityNodeKind = "person"
+	IdentityPartner IdentityNodeKind = "partner"
+)
+
+// IdentityNode is a recordId, personId or partnerId of the identity graph.
+type IdentityNode struct {
+	Kind IdentityNodeKind
+	Id   string
+}
+
+// String returns the node as e.g. "record:r1".
+func (node IdentityNode) String() string {
+	return string(node.Kind) + ":" + node.Id
+}
+
+func (node IdentityNode) MarshalText() ([]byte, error) {
+	return []byte(node.String()), nil
+}
+
+func (node *IdentityNode) UnmarshalText(text []byte) error {
+	parts := strings.SplitN(string(text), ":", 2)
+	if len(parts) != 2 {
+		return NewFullContactError("Invalid identity node: " + string(text))
+	}
+	node.Kind, node.Id = IdentityNodeKind(parts[0]), parts[1]
+	return nil
+}
+
+type IdentityGraphOption func(ig *IdentityGraph)
+
+/*
+IdentityGraph keeps the recordId, personId and partnerId resolved together, to look up known
+identities without resolving them again. Registered as a CallObserver with WithCallObserver, it is
+populated from the Identity Map, Identity Resolve and Identity MapResolve calls made through the
+client, and records removed with Identity Delete are removed from the graph.
+
+When a record is resolved by its recordId, the personIds of the response replace the ones of the
+record, so the graph follows the changes of the personIds. Otherwise the ids are only added.
+*/
+type IdentityGraph struct {
+	mutex sync.RWMutex
+	store Store
+	edges map[IdentityNode]map[IdentityNode]bool
+	tags  map[string][]Tag
+}
+
+var _ CallObserver = (*IdentityGraph)(nil)
+
+type identityGraphSnapshot struct {
+	Edges [][2]IdentityNode `json:"edges"`
+	Nodes []IdentityNode    `json:"nodes,omitempty"`
+	Tags  map[string][]Tag  `json:"tags,omitempty"`
+}
+
+// NewIdentityGraph creates an IdentityGraph, loaded from the Store set with WithIdentityGraphStore if any.
+func NewIdentityGraph(options ...IdentityGraphOption) (*IdentityGraph, error) {
+	ig := &IdentityGraph{
+		edges: make(map[IdentityNode]map[IdentityNode]bool),
+		tags:  make(map[string][]Tag),
+	}
+	for _, opts := range options {
+		opts(ig)
+	}
+	if ig.store == nil {
+		return ig, nil
+	}
+	value, err := ig.store.Load(identityGraphStoreKey)
+	if err != nil || value == nil {
+		return ig, err
+	}
+	var snapshot identityGraphSnapshot
+	if err = json.Unmarshal(value, &snapshot); err != nil {
+		return nil, err
+	}
+	for _, node := range snapshot.Nodes {
+		ig.addNode(node)
+	}
+	for _, edge := range snapshot.Edges {
+		ig.link(edge[0], edge[1])
+	}
+	for recordId, tags := range snapshot.Tags {
+		ig.tags[recordId] = tags
+	}
+	return ig, nil
+}
+
+// WithIdentityGraphStore persists the graph to the Store with Save.
+func WithIdentityGraphStore(store Store) IdentityGraphOption {
+	return func(ig *IdentityGraph) {
+		ig.store = store
+	}
+}
+
+// Save persists the graph to its Store.
+func (ig *IdentityGraph) Save() error {
+	if ig.store == nil {
+		return NewFullContactError("IdentityGraph has no Store")
+	}
+	ig.mutex.RLock()
+	snapshot := identityGraphSnapshot{Tags: ig.tags}
+	for _, node := range ig.nodes() {
+		if len(ig.edges[node]) == 0 {
+			snapshot.Nodes = append(snapshot.Nodes, node)
+		}
+		for _, neighbor := range ig.neighbors(node) {
+			if node.String() < neighbor.String() {
+				snapshot.Edges = append(snapshot.Edges, [2]IdentityNode{node, neighbor})
+			}
+		}
+	}
+	value, err := json.Marshal(&snapshot)
+	ig.mutex.RUnlock()
+	if err != nil {
+		return err
+	}
+	return ig.store.Save(identityGraphStoreKey, value)
+}
+
+func (ig *IdentityGraph) ObserveCall(call *CallRecord) {
+	resp := call.Response
+	switch call.Endpoint {
+	case endpointName(identityMapUrl), endpointName(identityResolveUrl), endpointName(identityMapResolveUrl):
+		if !resp.IsMatched() {
+			return
+		}
+		var request ResolveRequest
+		if json.Unmarshal(call.Request, &request) != nil {
+			return
+		}
+		if resp.ResolveResponse != nil {
+			ig.Add(&request, resp.ResolveResponse)
+		} else if resp.ResolveResponseWithTags != nil {
+			ig.AddWithTags(&request, resp.ResolveResponseWithTags)
+		}
+	case endpointName(identityDeleteUrl):
+		var request ResolveRequest
+		if (resp.IsMatched() || resp.IsDeleted()) && json.Unmarshal(call.Request, &request) == nil {
+			ig.RemoveRecord(request.RecordId)
+		}
+	}
+}
+
+// Add links the ids of a resolve response, and the recordId, personId and partnerId of its request.
+func (ig *IdentityGraph) Add(resolveRequest *ResolveRequest, resolveResponse *ResolveResponse) {
+	var records, persons, partners []IdentityNode
+	add := func(nodes []IdentityNode, kind IdentityNodeKind, ids ...string) []IdentityNode {
+		for _, id := range ids {
+			node := IdentityNode{Kind: kind, Id: id}
+			if isPopulated(id) && !containsIdentityNode(nodes, node) {
+				nodes = append(nodes, node)
+			}
+		}
+		return nodes
+	}
+	records = add(records, IdentityRecord, resolveResponse.RecordIds...)
+	persons = add(persons, IdentityPerson, resolveResponse.PersonIds...)
+	partners = add(partners, IdentityPartner, resolveResponse.PartnerIds...)
+	if resolveRequest != nil {
+		records = add(records, IdentityRecord, resolveRequest.RecordId)
+		persons = add(persons, IdentityPerson, resolveRequest.PersonId)
+		partners = add(partners, IdentityPartner, resolveRequest.PartnerId)
+	}
+
+	ig.mutex.Lock()
+	defer ig.mutex.Unlock()
+	if resolveRequest != nil && isPopulated(resolveRequest.RecordId) && len(resolveResponse.PersonIds) > 0 {
+		record := IdentityNode{Kind: IdentityRecord, Id: resolveRequest.RecordId}
+		for neighbor := range ig.edges[record] {
+			if neighbor.Kind == IdentityPerson && !containsIdentityNode(persons, neighbor) {
+				ig.unlink(record, neighbor)
+			}
+		}
+	}
+
+	linked := false
+	groups := [][]IdentityNode{records, persons, partners}
+	for i := range groups {
+		for j := i + 1; j < len(groups); j++ {
+			for _, a := range groups[i] {
+				for _, b := range groups[j] {
+					ig.link(a, b)
+					linked = true
+				}
+			}
+		}
+	}
+	if linked {
+		return
+	}
+	// ids of a single kind are the same identity, chain them
+	for _, nodes := range groups {
+		for i, node := range nodes {
+			ig.addNode(node)
+			if i > 0 {
+				ig.link(nodes[i-1], node)
+			}
+		}
+	}
+}
+
+// AddWithTags links the ids of a resolve response with tags like Add, and keeps the tags of its records.
+func (ig *IdentityGraph) AddWithTags(resolveRequest *ResolveRequest, resolveResponse *ResolveResponseWithTags) {
+	ig.Add(resolveRequest, &ResolveResponse{
+		RecordIds:  resolveResponse.RecordIds,
+		PersonIds:  resolveResponse.PersonIds,
+		PartnerIds: resolveResponse.PartnerIds,
+	})
+	ig.mutex.Lock()
+	defer ig.mutex.Unlock()
+	for recordId, tags := range resolveResponse.Tags {
+		ig.tags[recordId] = tags
+	}
+}
+
+// RemoveRecord removes the record, and the personIds and partnerIds left without any link.
+func (ig *IdentityGraph) RemoveRecord(recordId string) {
+	ig.mutex.Lock()
+	defer ig.mutex.Unlock()
+	record := IdentityNode{Kind: IdentityRecord, Id: recordId}
+	for neighbor := range ig.edges[record] {
+		ig.unlink(record, neighbor)
+	}
+	delete(ig.edges, record)
+	delete(ig.tags, recordId)
+}
+
+// Len returns the number of nodes of the graph.
+func (ig *IdentityGraph) Len() int {
+	ig.mutex.RLock()
+	defer ig.mutex.RUnlock()
+	return len(ig.edges)
+}
+
+func (ig *IdentityGraph) Contains(node IdentityNode) bool {
+	ig.mutex.RLock()
+	defer ig.mutex.RUnlock()
+	_, ok := ig.edges[node]
+	return ok
+}
+
+// Nodes returns the nodes of the graph sorted by kind and id.
+func (ig *IdentityGraph) Nodes() []IdentityNode {
+	ig.mutex.RLock()
+	defer ig.mutex.RUnlock()
+	return ig.nodes()
+}
+
+// Neighbors returns the nodes linked to the node sorted by kind and id.
+func (ig *IdentityGraph) Neighbors(node IdentityNode) []IdentityNode {
+	ig.mutex.RLock()
+	defer ig.mutex.RUnlock()
+	return ig.neighbors(node)
+}
+
+// RecordsOfPerson returns the sorted recordIds linked to the personId.
+func (ig *IdentityGraph) RecordsOfPerson(personId string) []string {
+	return ig.neighborIds(IdentityNode{Kind: IdentityPerson, Id: personId}, IdentityRecord)
+}
+
+// PersonsOfRecord returns the sorted personIds linked to the recordId.
+func (ig *IdentityGraph) PersonsOfRecord(recordId string) []string {
+	return ig.neighborIds(IdentityNode{Kind: IdentityRecord, Id: recordId}, IdentityPerson)
+}
+
+// PartnersOfRecord returns the sorted partnerIds linked to the recordId.
+func (ig *IdentityGraph) PartnersOfRecord(recordId string) []string {
+	return ig.neighborIds(IdentityNode{Kind: IdentityRecord, Id: recordId}, IdentityPartner)
+}
+
+// TagsOf returns the tags of the record received from Identity Resolve with tags.
+func (ig *IdentityGraph) TagsOf(recordId string) []Tag {
+	ig.mutex.RLock()
+	defer ig.mutex.RUnlock()
+	return append([]Tag(nil), ig.tags[recordId]...)
+}
+
+// Component returns the nodes connected to the node, including itself, sorted by kind and id.
+func (ig *IdentityGraph) Component(node IdentityNode) []IdentityNode {
+	ig.mutex.RLock()
+	defer ig.mutex.RUnlock()
+	if _, ok := ig.edges[node]; !ok {
+		return nil
+	}
+	return ig.component(node, make(map[IdentityNode]bool))
+}
+
+// Components returns the connected components of the graph, largest first.
+func (ig *IdentityGraph) Components() [][]IdentityNode {
+	ig.mutex.RLock()
+	defer ig.mutex.RUnlock()
+	visited := make(map[IdentityNode]bool)
+	var components [][]IdentityNode
+	for _, node := range ig.nodes() {
+		if !visited[node] {
+			components = append(components, ig.component(node, visited))
+		}
+	}
+	sort.SliceStable(components, func(i, j int) bool {
+		return len(components[i]) > len(components[j])
+	})
+	return components
+}
+
+/*
+Lookup returns the ids connected to the recordId, personId or partnerId of the request, as a
+resolve response, and false if none of them is known.
+*/
+func (ig *IdentityGraph) Lookup(resolveRequest *ResolveRequest) (*ResolveResponse, bool) {
+	for _, node := range []IdentityNode{
+		{Kind: IdentityRecord, Id: resolveRequest.RecordId},
+		{Kind: IdentityPerson, Id: resolveRequest.PersonId},
+		{Kind: IdentityPartner, Id: resolveRequest.PartnerId},
+	} {
+		if !isPopulated(node.Id) {
+			continue
+		}
+		component := ig.Component(node)
+		if component == nil {
+			continue
+		}
+		resolveResponse := &ResolveResponse{}
+		for _, componentNode := range component {
+			switch componentNode.Kind {
+			case IdentityRecord:
+				resolveResponse.RecordIds = append(resolveResponse.RecordIds, componentNode.Id)
+			case IdentityPerson:
+				resolveResponse.PersonIds = append(resolveResponse.PersonIds, componentNode.Id)
+			case IdentityPartner:
+				resolveResponse.PartnerIds = append(resolveResponse.PartnerIds, componentNode.Id)
+			}
+		}
+		return resolveResponse, true
+	}
+	return nil, false
+}
+
+func (ig *IdentityGraph) addNode(node IdentityNode) {
+	if _, ok := ig.edges[node]; !ok {
+		ig.edges[node] = make(map[IdentityNode]bool)
+	}
+}
+
+func (ig *IdentityGraph) link(a, b IdentityNode) {
+	ig.addNode(a)
+	ig.addNode(b)
+	ig.edges[a][b] = true
+	ig.edges[b][a] = true
+}
+
+// unlink removes the edge, and the personIds and partnerIds left without any link.
+func (ig *IdentityGraph) unlink(a, b IdentityNode) {
+	delete(ig.edges[a], b)
+	delete(ig.edges[b], a)
+	for _, node := range []IdentityNode{a, b} {
+		if node.Kind != IdentityRecord && len(ig.edges[node]) == 0 {
+			delete(ig.edges, node)
+		}
+	}
+}
+
+func (ig *IdentityGraph) nodes() []IdentityNode {
+	nodes := make([]IdentityNode, 0, len(ig.edges))
+	for node := range ig.edges {
+		nodes = append(nodes, node)
+	}
+	sortIdentityNodes(nodes)
+	return nodes
+}
+
+func (ig *IdentityGraph) neighbors(node IdentityNode) []IdentityNode {
+	neighbors := make([]IdentityNode, 0, len(ig.edges[node]))
+	for neighbor := range ig.edges[node] {
+		neighbors = append(neighbors, neighbor)
+	}
+	sortIdentityNodes(neighbors)
+	return neighbors
+}
+
+func (ig *IdentityGraph) neighborIds(node IdentityNode, kind IdentityNodeKind) []string {
+	ig.mutex.RLock()
+	defer ig.mutex.RUnlock()
+	ids := make([]string, 0)
+	for _, neighbor := range ig.neighbors(node) {
+		if neighbor.Kind == kind {
+			ids = append(ids, neighbor.Id)
+		}
+	}
+	return ids
+}
+
+func (ig *IdentityGraph) component(start IdentityNode, visited map[IdentityNode]bool) []IdentityNode {
+	visited[start] = true
+	queue := []IdentityNode{start}
+	for i := 0; i < len(queue); i++ {
+		for neighbor := range ig.edges[queue[i]] {
+			if !visited[neighbor] {
+				visited[neighbor] = true
+				queue = append(queue, neighbor)
+			}
+		}
+	}
+	sortIdentityNodes(queue)
+	return queue
+}
+
+func sortIdentityNodes(nodes []IdentityNode) {
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].Kind != nodes[j].Kind {
+			return identityNodeKindOrder(nodes[i].Kind) < identityNodeKindOrder(nodes[j].Kind)
+		}
+		return nodes[i].Id < nodes[j].Id
+	})
+}
+
+func identityNodeKindOrder(kind IdentityNodeKind) int {
+	switch kind {
+	case IdentityRecord:
+		return 0
+	case IdentityPerson:
+		return 1
+	default:
+		return 2
+	}
+}
+
+func containsIdentityNode(nodes []IdentityNode, node IdentityNode) bool {
+	for _, existing := range nodes {
+		if existing == node {
+			return true
+		}
+	}
+	return false
+}
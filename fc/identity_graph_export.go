@@ -0,0 +1,215 @@
+package fullcontact
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+type GraphExportOption func(ge *graphExport)
+
+type graphExport struct {
+	minSize   int
+	maxSize   int
+	tagFilter *TagExpression
+	err       error
+}
+
+type exportedComponent struct {
+	nodes []IdentityNode
+	edges [][2]IdentityNode
+}
+
+// WithExportMinComponentSize only exports the components having at least minSize nodes.
+func WithExportMinComponentSize(minSize int) GraphExportOption {
+	return func(ge *graphExport) {
+		ge.minSize = minSize
+	}
+}
+
+// WithExportMaxComponentSize only exports the components having at most maxSize nodes.
+func WithExportMaxComponentSize(maxSize int) GraphExportOption {
+	return func(ge *graphExport) {
+		ge.maxSize = maxSize
+	}
+}
+
+/*
+WithExportTagFilter only exports the components having a record matching the tag expression, see
+TagExpression, e.g. "segment:* AND NOT region:us".
+*/
+func WithExportTagFilter(expression string) GraphExportOption {
+	return func(ge *graphExport) {
+		ge.tagFilter, ge.err = parseTagQuery(expression)
+	}
+}
+
+/*
+WriteDOT writes the graph in the Graphviz DOT format. Every node is identified as kind:id, e.g.
+"record:r1", and has kind and component attributes, and a tag:key attribute per tag key of a record.
+*/
+func (ig *IdentityGraph) WriteDOT(w io.Writer, options ...GraphExportOption) error {
+	components, err := ig.export(options)
+	if err != nil {
+		return err
+	}
+	shapes := map[IdentityNodeKind]string{IdentityRecord: "box", IdentityPerson: "ellipse", IdentityPartner: "diamond"}
+	writer := bufio.NewWriter(w)
+	writer.WriteString("graph identity {\n")
+	for i, component := range components {
+		for _, node := range component.nodes {
+			fmt.Fprintf(writer, "  %s [label=%s, shape=%s, kind=%s, component=%d",
+				dotQuote(node.String()), dotQuote(node.Id), shapes[node.Kind], dotQuote(string(node.Kind)), i)
+			for _, attribute := range ig.tagAttributes(node) {
+				fmt.Fprintf(writer, ", %s=%s", dotQuote(attribute[0]), dotQuote(attribute[1]))
+			}
+			writer.WriteString("];\n")
+		}
+		for _, edge := range component.edges {
+			fmt.Fprintf(writer, "  %s -- %s;\n", dotQuote(edge[0].String()), dotQuote(edge[1].String()))
+		}
+	}
+	writer.WriteString("}\n")
+	return writer.Flush()
+}
+
+// WriteGraphML writes the graph in the GraphML format, with the same node ids and attributes as WriteDOT.
+func (ig *IdentityGraph) WriteGraphML(w io.Writer, options ...GraphExportOption) error {
+	components, err := ig.export(options)
+	if err != nil {
+		return err
+	}
+	var tagKeys []string
+	keyIds := make(map[string]string)
+	for _, component := range components {
+		for _, node := range component.nodes {
+			for _, attribute := range ig.tagAttributes(node) {
+				if _, ok := keyIds[attribute[0]]; !ok {
+					tagKeys = append(tagKeys, attribute[0])
+					keyIds[attribute[0]] = ""
+				}
+			}
+		}
+	}
+	sort.Strings(tagKeys)
+	for i, tagKey := range tagKeys {
+		keyIds[tagKey] = fmt.Sprintf("t%d", i)
+	}
+
+	writer := bufio.NewWriter(w)
+	writer.WriteString(xml.Header)
+	writer.WriteString("<graphml xmlns=\"http://graphml.graphdrawing.org/xmlns\">\n")
+	writer.WriteString("  <key id=\"kind\" for=\"node\" attr.name=\"kind\" attr.type=\"string\"/>\n")
+	writer.WriteString("  <key id=\"label\" for=\"node\" attr.name=\"label\" attr.type=\"string\"/>\n")
+	writer.WriteString("  <key id=\"component\" for=\"node\" attr.name=\"component\" attr.type=\"int\"/>\n")
+	for _, tagKey := range tagKeys {
+		fmt.Fprintf(writer, "  <key id=\"%s\" for=\"node\" attr.name=\"%s\" attr.type=\"string\"/>\n", keyIds[tagKey], xmlEscape(tagKey))
+	}
+	writer.WriteString("  <graph id=\"identity\" edgedefault=\"undirected\">\n")
+	for i, component := range components {
+		for _, node := range component.nodes {
+			fmt.Fprintf(writer, "    <node id=\"%s\">\n", xmlEscape(node.String()))
+			fmt.Fprintf(writer, "      <data key=\"kind\">%s</data>\n", xmlEscape(string(node.Kind)))
+			fmt.Fprintf(writer, "      <data key=\"label\">%s</data>\n", xmlEscape(node.Id))
+			fmt.Fprintf(writer, "      <data key=\"component\">%d</data>\n", i)
+			for _, attribute := range ig.tagAttributes(node) {
+				fmt.Fprintf(writer, "      <data key=\"%s\">%s</data>\n", keyIds[attribute[0]], xmlEscape(attribute[1]))
+			}
+			writer.WriteString("    </node>\n")
+		}
+		for _, edge := range component.edges {
+			fmt.Fprintf(writer, "    <edge source=\"%s\" target=\"%s\"/>\n", xmlEscape(edge[0].String()), xmlEscape(edge[1].String()))
+		}
+	}
+	writer.WriteString("  </graph>\n</graphml>\n")
+	return writer.Flush()
+}
+
+// export returns the components of the graph selected by the options, largest first.
+func (ig *IdentityGraph) export(options []GraphExportOption) ([]*exportedComponent, error) {
+	ge := &graphExport{}
+	for _, opts := range options {
+		opts(ge)
+	}
+	if ge.err != nil {
+		return nil, ge.err
+	}
+
+	var components []*exportedComponent
+	for _, nodes := range ig.Components() {
+		if len(nodes) < ge.minSize || (ge.maxSize > 0 && len(nodes) > ge.maxSize) {
+			continue
+		}
+		if ge.tagFilter != nil && !ig.anyRecordMatches(nodes, ge.tagFilter) {
+			continue
+		}
+		component := &exportedComponent{nodes: nodes}
+		positions := make(map[IdentityNode]int, len(nodes))
+		for i, node := range nodes {
+			positions[node] = i
+		}
+		for i, node := range nodes {
+			for _, neighbor := range ig.Neighbors(node) {
+				if position, ok := positions[neighbor]; ok && i < position {
+					component.edges = append(component.edges, [2]IdentityNode{node, neighbor})
+				}
+			}
+		}
+		components = append(components, component)
+	}
+	return components, nil
+}
+
+func (ig *IdentityGraph) anyRecordMatches(nodes []IdentityNode, te *TagExpression) bool {
+	for _, node := range nodes {
+		if node.Kind != IdentityRecord {
+			continue
+		}
+		tags := ig.TagsOf(node.Id)
+		tagPointers := make([]*Tag, len(tags))
+		for i := range tags {
+			tagPointers[i] = &tags[i]
+		}
+		matched := te.Matches(tagPointers)
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// tagAttributes returns the tags of a record as sorted tag:key attributes, joining the values of a key with ",".
+func (ig *IdentityGraph) tagAttributes(node IdentityNode) [][2]string {
+	if node.Kind != IdentityRecord {
+		return nil
+	}
+	values := make(map[string][]string)
+	var keys []string
+	for _, tag := range ig.TagsOf(node.Id) {
+		if _, ok := values[tag.Key]; !ok {
+			keys = append(keys, tag.Key)
+		}
+		values[tag.Key] = append(values[tag.Key], tag.Value)
+	}
+	sort.Strings(keys)
+	attributes := make([][2]string, len(keys))
+	for i, key := range keys {
+		sort.Strings(values[key])
+		attributes[i] = [2]string{"tag:" + key, strings.Join(values[key], ",")}
+	}
+	return attributes
+}
+
+func dotQuote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value) + `"`
+}
+
+func xmlEscape(value string) string {
+	var buffer bytes.Buffer
+	_ = xml.EscapeText(&buffer, []byte(value))
+	return buffer.String()
+}
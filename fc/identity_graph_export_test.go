@@ -0,0 +1,85 @@
+package fullcontact
+
+import (
+	"bytes"
+	assert "github.com/stretchr/testify/require"
+	"testing"
+)
+
+func getExportTestGraph() *IdentityGraph {
+	graph, _ := NewIdentityGraph()
+	graph.AddWithTags(nil, &ResolveResponseWithTags{
+		RecordIds: []string{"r1", "r2"},
+		PersonIds: []string{"p1"},
+		Tags: map[string][]Tag{
+			"r1": {{Key: "segment", Value: "vip"}, {Key: "segment", Value: "gold"}},
+			"r2": {{Key: "crm.owner", Value: `"sales"`}},
+		},
+	})
+	graph.Add(nil, &ResolveResponse{RecordIds: []string{"r3"}, PartnerIds: []string{"x3"}})
+	graph.Add(&ResolveRequest{RecordId: "r4"}, &ResolveResponse{})
+	return graph
+}
+
+func TestIdentityGraphWriteDOT(t *testing.T) {
+	var buffer bytes.Buffer
+	assert.NoError(t, getExportTestGraph().WriteDOT(&buffer, WithExportMinComponentSize(2)))
+	assert.Equal(t, `graph identity {
+  "record:r1" [label="r1", shape=box, kind="record", component=0, "tag:segment"="gold,vip"];
+  "record:r2" [label="r2", shape=box, kind="record", component=0, "tag:crm.owner"="\"sales\""];
+  "person:p1" [label="p1", shape=ellipse, kind="person", component=0];
+  "record:r1" -- "person:p1";
+  "record:r2" -- "person:p1";
+  "record:r3" [label="r3", shape=box, kind="record", component=1];
+  "partner:x3" [label="x3", shape=diamond, kind="partner", component=1];
+  "record:r3" -- "partner:x3";
+}
+`, buffer.String())
+}
+
+func TestIdentityGraphWriteGraphML(t *testing.T) {
+	var buffer bytes.Buffer
+	assert.NoError(t, getExportTestGraph().WriteGraphML(&buffer, WithExportTagFilter("crm.owner:*")))
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<graphml xmlns="http://graphml.graphdrawing.org/xmlns">
+  <key id="kind" for="node" attr.name="kind" attr.type="string"/>
+  <key id="label" for="node" attr.name="label" attr.type="string"/>
+  <key id="component" for="node" attr.name="component" attr.type="int"/>
+  <key id="t0" for="node" attr.name="tag:crm.owner" attr.type="string"/>
+  <key id="t1" for="node" attr.name="tag:segment" attr.type="string"/>
+  <graph id="identity" edgedefault="undirected">
+    <node id="record:r1">
+      <data key="kind">record</data>
+      <data key="label">r1</data>
+      <data key="component">0</data>
+      <data key="t1">gold,vip</data>
+    </node>
+    <node id="record:r2">
+      <data key="kind">record</data>
+      <data key="label">r2</data>
+      <data key="component">0</data>
+      <data key="t0">&#34;sales&#34;</data>
+    </node>
+    <node id="person:p1">
+      <data key="kind">person</data>
+      <data key="label">p1</data>
+      <data key="component">0</data>
+    </node>
+    <edge source="record:r1" target="person:p1"/>
+    <edge source="record:r2" target="person:p1"/>
+  </graph>
+</graphml>
+`, buffer.String())
+}
+
+func TestIdentityGraphExportFilters(t *testing.T) {
+	var buffer bytes.Buffer
+	assert.NoError(t, getExportTestGraph().WriteDOT(&buffer, WithExportMaxComponentSize(1)))
+	assert.Equal(t, "graph identity {\n  \"record:r4\" [label=\"r4\", shape=box, kind=\"record\", component=0];\n}\n", buffer.String())
+
+	buffer.Reset()
+	assert.NoError(t, getExportTestGraph().WriteDOT(&buffer, WithExportTagFilter("segment:silver")))
+	assert.Equal(t, "graph identity {\n}\n", buffer.String())
+
+	assert.Error(t, getExportTestGraph().WriteDOT(&buffer, WithExportTagFilter("segment:")))
+}
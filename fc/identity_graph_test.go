@@ -0,0 +1,101 @@
+package fullcontact
+
+import (
+	assert "github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+func TestIdentityGraphObservesCalls(t *testing.T) {
+	graph, err := NewIdentityGraph()
+	assert.NoError(t, err)
+	transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		switch req.URL.String() {
+		case identityMapUrl:
+			return chaosResponse(req, 200, `{"recordIds":["r1"]}`), nil
+		case identityResolveUrl:
+			return chaosResponse(req, 200, `{"recordIds":["r1","r2"],"personIds":["p1"],"partnerIds":["x1"]}`), nil
+		case identityDeleteUrl:
+			return chaosResponse(req, 204, ""), nil
+		}
+		return chaosResponse(req, 404, "{}"), nil
+	})
+	fcClient, err := NewFullContactClient(
+		WithCredentialsProvider(StaticCredentialsProvider{apiKey: "apikey"}),
+		WithHTTPClient(&http.Client{Transport: transport}),
+		WithCallObserver(graph))
+	assert.NoError(t, err)
+
+	resolveRequest, _ := NewResolveRequest(WithEmailForResolve("marquitaross006@gmail.com"), WithRecordIdForResolve("r1"))
+	assert.NoError(t, (<-fcClient.IdentityMap(resolveRequest)).Err)
+	assert.Equal(t, 1, graph.Len())
+
+	resolveRequest, _ = NewResolveRequest(WithEmailForResolve("marquitaross006@gmail.com"))
+	assert.NoError(t, (<-fcClient.IdentityResolve(resolveRequest)).Err)
+	assert.Equal(t, []string{"r1", "r2"}, graph.RecordsOfPerson("p1"))
+	assert.Equal(t, []string{"p1"}, graph.PersonsOfRecord("r2"))
+	assert.Equal(t, []string{"x1"}, graph.PartnersOfRecord("r1"))
+
+	resolveResponse, ok := graph.Lookup(&ResolveRequest{PartnerId: "x1"})
+	assert.True(t, ok)
+	assert.Equal(t, &ResolveResponse{RecordIds: []string{"r1", "r2"}, PersonIds: []string{"p1"}, PartnerIds: []string{"x1"}}, resolveResponse)
+	_, ok = graph.Lookup(&ResolveRequest{RecordId: "r9"})
+	assert.False(t, ok)
+
+	resolveRequest, _ = NewResolveRequest(WithRecordIdForResolve("r1"))
+	assert.NoError(t, (<-fcClient.IdentityDelete(resolveRequest)).Err)
+	assert.Equal(t, []string{"r2"}, graph.RecordsOfPerson("p1"))
+	assert.False(t, graph.Contains(IdentityNode{Kind: IdentityRecord, Id: "r1"}))
+}
+
+func TestIdentityGraphComponents(t *testing.T) {
+	graph, _ := NewIdentityGraph()
+	graph.Add(nil, &ResolveResponse{RecordIds: []string{"r1", "r2"}, PersonIds: []string{"p1"}})
+	graph.Add(nil, &ResolveResponse{RecordIds: []string{"r2"}, PartnerIds: []string{"x1"}})
+	graph.Add(nil, &ResolveResponse{RecordIds: []string{"r3"}, PersonIds: []string{"p2"}})
+	graph.Add(&ResolveRequest{RecordId: "r4"}, &ResolveResponse{})
+
+	components := graph.Components()
+	assert.Len(t, components, 3)
+	assert.Equal(t, []IdentityNode{
+		{Kind: IdentityRecord, Id: "r1"},
+		{Kind: IdentityRecord, Id: "r2"},
+		{Kind: IdentityPerson, Id: "p1"},
+		{Kind: IdentityPartner, Id: "x1"},
+	}, components[0])
+	assert.Equal(t, components[0], graph.Component(IdentityNode{Kind: IdentityPartner, Id: "x1"}))
+	assert.Equal(t, []IdentityNode{{Kind: IdentityRecord, Id: "r4"}}, components[2])
+	assert.Nil(t, graph.Component(IdentityNode{Kind: IdentityPerson, Id: "p9"}))
+}
+
+func TestIdentityGraphReplacesPersonOfResolvedRecord(t *testing.T) {
+	graph, _ := NewIdentityGraph()
+	graph.Add(&ResolveRequest{RecordId: "r1"}, &ResolveResponse{RecordIds: []string{"r1"}, PersonIds: []string{"p1"}})
+	graph.Add(&ResolveRequest{RecordId: "r1"}, &ResolveResponse{RecordIds: []string{"r1"}, PersonIds: []string{"p2"}})
+	assert.Equal(t, []string{"p2"}, graph.PersonsOfRecord("r1"))
+	assert.False(t, graph.Contains(IdentityNode{Kind: IdentityPerson, Id: "p1"}))
+}
+
+func TestIdentityGraphStore(t *testing.T) {
+	store := NewMemoryStore()
+	graph, err := NewIdentityGraph(WithIdentityGraphStore(store))
+	assert.NoError(t, err)
+	graph.Add(nil, &ResolveResponse{RecordIds: []string{"r1", "r2"}, PersonIds: []string{"p1"}, PartnerIds: []string{"x1"}})
+	graph.Add(&ResolveRequest{RecordId: "r3"}, &ResolveResponse{})
+	graph.ObserveCall(&CallRecord{
+		Endpoint: endpointName(identityResolveWithTagsUrl),
+		Request:  []byte(`{"recordId":"r3"}`),
+		Response: &APIResponse{StatusCode: 200, ResolveResponseWithTags: &ResolveResponseWithTags{
+			RecordIds: []string{"r3"}, PersonIds: []string{"p3"}, Tags: map[string][]Tag{"r3": {{Key: "segment", Value: "vip"}}}}},
+	})
+	assert.NoError(t, graph.Save())
+
+	loaded, err := NewIdentityGraph(WithIdentityGraphStore(store))
+	assert.NoError(t, err)
+	assert.Equal(t, graph.Nodes(), loaded.Nodes())
+	assert.Equal(t, graph.Components(), loaded.Components())
+	assert.Equal(t, []Tag{{Key: "segment", Value: "vip"}}, loaded.TagsOf("r3"))
+
+	graph, _ = NewIdentityGraph()
+	assert.EqualError(t, graph.Save(), "FullContactError: IdentityGraph has no Store")
+}
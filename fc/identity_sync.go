@@ -0,0 +1,424 @@
+package fullcontact
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SyncRecord is a record of the source of an IdentitySync, with the identifiers and tags to map.
+type SyncRecord struct {
+	RecordId string
+	// Identifiers holds the emails, phones, profiles, name and location of the record, its RecordId and Tags are ignored.
+	Identifiers *ResolveRequest
+	Tags        []*Tag
+}
+
+// SyncSource iterates over the records to sync, like AudienceReader.
+type SyncSource interface {
+	Next() bool
+	Record() *SyncRecord
+	Err() error
+}
+
+type sliceSyncSource struct {
+	records []*SyncRecord
+	index   int
+}
+
+// NewSliceSyncSource returns a SyncSource iterating over the records.
+func NewSliceSyncSource(records []*SyncRecord) SyncSource {
+	return &sliceSyncSource{records: records, index: -1}
+}
+
+func (source *sliceSyncSource) Next() bool {
+	source.index++
+	return source.index < len(source.records)
+}
+
+func (source *sliceSyncSource) Record() *SyncRecord {
+	return source.records[source.index]
+}
+
+func (source *sliceSyncSource) Err() error {
+	return nil
+}
+
+type SyncAction string
+
+const (
+	SyncCreate    SyncAction = "create"
+	SyncUpdate    SyncAction = "update"
+	SyncDelete    SyncAction = "delete"
+	SyncUnchanged SyncAction = "unchanged"
+)
+
+// SyncError is the failure of the action of a single record.
+type SyncError struct {
+	RecordId string
+	Action   SyncAction
+	Err      error
+}
+
+// SyncSummary is the summary of an IdentitySync run. In dry run, it holds the actions which would be taken.
+type SyncSummary struct {
+	DryRun    bool
+	Created   int
+	Updated   int
+	Deleted   int
+	Unchanged int
+	Failed    int
+	Errors    []*SyncError
+	Started   time.Time
+	Finished  time.Time
+}
+
+func (summary *SyncSummary) String() string {
+	return fmt.Sprintf("created: %d, updated: %d, deleted: %d, unchanged: %d, failed: %d",
+		summary.Created, summary.Updated, summary.Deleted, summary.Unchanged, summary.Failed)
+}
+
+type IdentitySyncOption func(is *IdentitySync)
+
+/*
+IdentitySync pushes the records of a source, e.g. a CRM table, to the Private Identity Cloud. The
+records mapped by previous runs are kept in a Store, so every run only calls Identity Map for the
+new and changed records, with their tags, and Identity Delete for the records removed from the source.
+A record changes when its identifiers or tags change.
+
+Nothing is deleted if the source fails, and a run failing for some records can be run again, as the
+state only records the actions which succeeded. The source is streamed, only the recordIds are kept.
+*/
+type IdentitySync struct {
+	client      Resolver
+	store       Store
+	stateKey    string
+	dryRun      bool
+	concurrency int
+	maxDeletes  int
+	// checkpointEvery is the number of actions after which the state is saved during a run
+	checkpointEvery int
+	now             func() time.Time
+}
+
+type syncJob struct {
+	action   SyncAction
+	recordId string
+	request  *ResolveRequest
+	digest   string
+}
+
+func NewIdentitySync(client Resolver, store Store, options ...IdentitySyncOption) (*IdentitySync, error) {
+	if client == nil {
+		return nil, NewFullContactError("Client can't be nil")
+	}
+	if store == nil {
+		return nil, NewFullContactError("Store must be present for identity sync")
+	}
+	is := &IdentitySync{
+		client:          client,
+		store:           store,
+		stateKey:        "identity-sync/state",
+		concurrency:     4,
+		maxDeletes:      -1,
+		checkpointEvery: 100,
+		now:             time.Now,
+	}
+	for _, opts := range options {
+		opts(is)
+	}
+	if is.concurrency < 1 {
+		is.concurrency = 1
+	}
+	return is, nil
+}
+
+// WithSyncDryRun computes the actions of the run without calling the API nor updating the state.
+func WithSyncDryRun() IdentitySyncOption {
+	return func(is *IdentitySync) {
+		is.dryRun = true
+	}
+}
+
+// WithSyncConcurrency sets how many records are synced at once, 4 by default.
+func WithSyncConcurrency(concurrency int) IdentitySyncOption {
+	return func(is *IdentitySync) {
+		is.concurrency = concurrency
+	}
+}
+
+// WithSyncStateKey sets the key of the state in the Store, to sync several sources with the same Store.
+func WithSyncStateKey(stateKey string) IdentitySyncOption {
+	return func(is *IdentitySync) {
+		is.stateKey = stateKey
+	}
+}
+
+// WithSyncCheckpointEvery saves the state every checkpointEvery actions of a run, 100 by default, 0 only saves it at the end.
+func WithSyncCheckpointEvery(checkpointEvery int) IdentitySyncOption {
+	return func(is *IdentitySync) {
+		is.checkpointEvery = checkpointEvery
+	}
+}
+
+// WithSyncMaxDeletes aborts runs which would delete more than maxDeletes records, e.g. for a truncated source.
+func WithSyncMaxDeletes(maxDeletes int) IdentitySyncOption {
+	return func(is *IdentitySync) {
+		is.maxDeletes = maxDeletes
+	}
+}
+
+/*
+Run syncs the records of the source as they are read. Invalid and duplicate records are failures of
+the summary. The state is saved every checkpoint and at the end of the run, so that a run stopped
+midway doesn't map its records again. The records mapped before the source failed, the maximum of
+deletes was exceeded or the context was cancelled are saved too, and the summary is returned along
+with the error.
+*/
+func (is *IdentitySync) Run(ctx context.Context, source SyncSource, options ...CallOption) (*SyncSummary, error) {
+	summary := &SyncSummary{DryRun: is.dryRun, Started: is.now()}
+	state, err := is.loadState()
+	if err != nil {
+		return nil, err
+	}
+
+	run := is.start(ctx, state, summary, options)
+	seen := make(map[string]bool)
+	for ctx.Err() == nil && source.Next() {
+		record := source.Record()
+		action := SyncCreate
+		if _, ok := run.previous[record.RecordId]; ok {
+			action = SyncUpdate
+		}
+		if seen[record.RecordId] {
+			run.fail(record.RecordId, action, NewFullContactError("Duplicate record in sync source: "+record.RecordId))
+			continue
+		}
+		if isPopulated(record.RecordId) {
+			seen[record.RecordId] = true
+		}
+		job, err := newSyncJob(record)
+		if err != nil {
+			run.fail(record.RecordId, action, err)
+			continue
+		}
+		if run.previous[record.RecordId] == job.digest {
+			run.unchanged()
+			continue
+		}
+		job.action = action
+		run.submit(job)
+	}
+	if err = source.Err(); err != nil {
+		return summary, is.finish(run, err)
+	}
+	if err = ctx.Err(); err != nil {
+		return summary, is.finish(run, err)
+	}
+
+	var deletes []string
+	for recordId := range run.previous {
+		if !seen[recordId] {
+			deletes = append(deletes, recordId)
+		}
+	}
+	if is.maxDeletes >= 0 && len(deletes) > is.maxDeletes {
+		return summary, is.finish(run, NewFullContactError(fmt.Sprintf("Sync would delete %d records, more than the maximum of %d", len(deletes), is.maxDeletes)))
+	}
+	sort.Strings(deletes)
+	for _, recordId := range deletes {
+		if ctx.Err() != nil {
+			break
+		}
+		run.submit(&syncJob{action: SyncDelete, recordId: recordId})
+	}
+	return summary, is.finish(run, ctx.Err())
+}
+
+// finish waits for the actions submitted and saves the state, returning err if any.
+func (is *IdentitySync) finish(run *syncRun, err error) error {
+	run.wait()
+	run.summary.Finished = is.now()
+	if is.dryRun {
+		return err
+	}
+	if saveErr := is.saveState(run.state); err == nil {
+		err = saveErr
+	}
+	return err
+}
+
+func newSyncJob(record *SyncRecord) (*syncJob, error) {
+	if !isPopulated(record.RecordId) {
+		return nil, NewFullContactError("RecordId must be present for every record of the sync source")
+	}
+	request, err := NewResolveRequest(func(resolveRequest *ResolveRequest) {
+		if record.Identifiers != nil {
+			*resolveRequest = *record.Identifiers
+			resolveRequest.Tags = nil
+		}
+	}, WithRecordIdForResolve(record.RecordId), WithTagsForResolve(record.Tags))
+	if err != nil {
+		return nil, err
+	}
+	sortTags(request.Tags)
+	requestBytes, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(requestBytes)
+	return &syncJob{recordId: record.RecordId, request: request, digest: hex.EncodeToString(hash[:])}, nil
+}
+
+// syncRun applies the actions of a run with concurrent workers, updating the state and summary.
+type syncRun struct {
+	is        *IdentitySync
+	mutex     sync.Mutex
+	state     map[string]string
+	summary   *SyncSummary
+	options   []CallOption
+	queue     chan *syncJob
+	wg        sync.WaitGroup
+	completed int
+	// previous is the state before the run, as state is updated by the workers
+	previous map[string]string
+}
+
+func (is *IdentitySync) start(ctx context.Context, state map[string]string, summary *SyncSummary, options []CallOption) *syncRun {
+	run := &syncRun{
+		is:       is,
+		state:    state,
+		summary:  summary,
+		options:  append([]CallOption{WithCallContext(ctx)}, options...),
+		queue:    make(chan *syncJob),
+		previous: make(map[string]string, len(state)),
+	}
+	for recordId, digest := range state {
+		run.previous[recordId] = digest
+	}
+	if is.dryRun {
+		return run
+	}
+	for w := 0; w < is.concurrency; w++ {
+		run.wg.Add(1)
+		go func() {
+			defer run.wg.Done()
+			for job := range run.queue {
+				run.done(job, is.apply(job, run.options))
+			}
+		}()
+	}
+	return run
+}
+
+func (run *syncRun) submit(job *syncJob) {
+	if run.is.dryRun {
+		run.mutex.Lock()
+		run.summary.count(job.action)
+		run.mutex.Unlock()
+		return
+	}
+	run.queue <- job
+}
+
+func (run *syncRun) unchanged() {
+	run.mutex.Lock()
+	defer run.mutex.Unlock()
+	run.summary.Unchanged++
+}
+
+func (run *syncRun) fail(recordId string, action SyncAction, err error) {
+	run.mutex.Lock()
+	defer run.mutex.Unlock()
+	run.summary.Failed++
+	run.summary.Errors = append(run.summary.Errors, &SyncError{RecordId: recordId, Action: action, Err: err})
+}
+
+func (run *syncRun) done(job *syncJob, err error) {
+	if err != nil {
+		run.fail(job.recordId, job.action, err)
+		return
+	}
+	run.mutex.Lock()
+	defer run.mutex.Unlock()
+	run.summary.count(job.action)
+	if job.action == SyncDelete {
+		delete(run.state, job.recordId)
+	} else {
+		run.state[job.recordId] = job.digest
+	}
+	run.completed++
+	if run.is.checkpointEvery > 0 && run.completed%run.is.checkpointEvery == 0 {
+		// a failed checkpoint only loses progress, the state is saved again at the end of the run
+		_ = run.is.saveState(run.state)
+	}
+}
+
+func (run *syncRun) wait() {
+	if !run.is.dryRun {
+		close(run.queue)
+		run.wg.Wait()
+	}
+	sort.SliceStable(run.summary.Errors, func(i, j int) bool {
+		return run.summary.Errors[i].RecordId < run.summary.Errors[j].RecordId
+	})
+}
+
+func (is *IdentitySync) apply(job *syncJob, options []CallOption) error {
+	var resp *APIResponse
+	operation := "map"
+	if job.action == SyncDelete {
+		operation = "delete"
+		resp = <-is.client.IdentityDelete(&ResolveRequest{RecordId: job.recordId}, options...)
+		// the record is already absent from the PIC
+		if resp.Err == nil && resp.IsNoMatch() {
+			return nil
+		}
+	} else {
+		resp = <-is.client.IdentityMap(job.request, options...)
+	}
+	if resp.Err != nil {
+		return resp.Err
+	}
+	if resp.IsError() {
+		return NewFullContactError(fmt.Sprintf("Identity %s failed for record %s with status %d", operation, job.recordId, resp.StatusCode))
+	}
+	return nil
+}
+
+func (summary *SyncSummary) count(action SyncAction) {
+	switch action {
+	case SyncCreate:
+		summary.Created++
+	case SyncUpdate:
+		summary.Updated++
+	case SyncDelete:
+		summary.Deleted++
+	}
+}
+
+// loadState returns the digests of the records mapped by the previous runs.
+func (is *IdentitySync) loadState() (map[string]string, error) {
+	state := make(map[string]string)
+	value, err := is.store.Load(is.stateKey)
+	if err != nil || value == nil {
+		return state, err
+	}
+	if err = json.Unmarshal(value, &state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func (is *IdentitySync) saveState(state map[string]string) error {
+	value, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return is.store.Save(is.stateKey, value)
+}
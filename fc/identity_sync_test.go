@@ -0,0 +1,175 @@
+package fullcontact
+
+import (
+	"context"
+	"errors"
+	assert "github.com/stretchr/testify/require"
+	"testing"
+)
+
+func getSyncTestRecords() []*SyncRecord {
+	return []*SyncRecord{
+		{RecordId: "c1", Identifiers: &ResolveRequest{Emails: []string{"one@example.com"}}, Tags: []*Tag{{Key: "segment", Value: "vip"}}},
+		{RecordId: "c2", Identifiers: &ResolveRequest{Phones: []string{"+15555550102"}}},
+		{RecordId: "c3", Identifiers: &ResolveRequest{Emails: []string{"three@example.com"}}},
+	}
+}
+
+func getSyncTestClient() *MockClient {
+	mockClient := NewMockClient()
+	mockClient.Respond("IdentityMap", &APIResponse{StatusCode: 200})
+	mockClient.Respond("IdentityDelete", &APIResponse{StatusCode: 204})
+	return mockClient
+}
+
+func TestIdentitySyncRun(t *testing.T) {
+	store := NewMemoryStore()
+	mockClient := getSyncTestClient()
+	identitySync, err := NewIdentitySync(mockClient, store)
+	assert.NoError(t, err)
+
+	summary, err := identitySync.Run(context.Background(), NewSliceSyncSource(getSyncTestRecords()))
+	assert.NoError(t, err)
+	assert.Equal(t, "created: 3, updated: 0, deleted: 0, unchanged: 0, failed: 0", summary.String())
+	mapped := mockClient.CallsTo("IdentityMap")
+	assert.Len(t, mapped, 3)
+
+	records := getSyncTestRecords()
+	records[0].Tags = []*Tag{{Key: "segment", Value: "churned"}}
+	summary, err = identitySync.Run(context.Background(), NewSliceSyncSource(records[:2]))
+	assert.NoError(t, err)
+	assert.Equal(t, "created: 0, updated: 1, deleted: 1, unchanged: 1, failed: 0", summary.String())
+
+	mapped = mockClient.CallsTo("IdentityMap")
+	assert.Len(t, mapped, 4)
+	updated := mapped[3].Args[0].(*ResolveRequest)
+	assert.Equal(t, &ResolveRequest{Emails: []string{"one@example.com"}, RecordId: "c1", Tags: []*Tag{{Key: "segment", Value: "churned"}}}, updated)
+	deleted := mockClient.CallsTo("IdentityDelete")[0].Args[0].(*ResolveRequest)
+	assert.Equal(t, "c3", deleted.RecordId)
+}
+
+func TestIdentitySyncDryRun(t *testing.T) {
+	store := NewMemoryStore()
+	mockClient := getSyncTestClient()
+	identitySync, _ := NewIdentitySync(mockClient, store, WithSyncDryRun())
+
+	summary, err := identitySync.Run(context.Background(), NewSliceSyncSource(getSyncTestRecords()))
+	assert.NoError(t, err)
+	assert.True(t, summary.DryRun)
+	assert.Equal(t, 3, summary.Created)
+	assert.Empty(t, mockClient.CallsTo("IdentityMap"))
+	keys, _ := store.Keys("")
+	assert.Empty(t, keys)
+}
+
+func TestIdentitySyncFailuresAreRetried(t *testing.T) {
+	store := NewMemoryStore()
+	failing := true
+	mockClient := NewMockClient()
+	mockClient.On("IdentityMap", func(args ...interface{}) *APIResponse {
+		if failing && args[0].(*ResolveRequest).RecordId == "c2" {
+			return &APIResponse{StatusCode: 400}
+		}
+		return &APIResponse{StatusCode: 200}
+	})
+	identitySync, _ := NewIdentitySync(mockClient, store, WithSyncConcurrency(2))
+
+	summary, err := identitySync.Run(context.Background(), NewSliceSyncSource(getSyncTestRecords()))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, summary.Created)
+	assert.Equal(t, 1, summary.Failed)
+	assert.Equal(t, "c2", summary.Errors[0].RecordId)
+	assert.EqualError(t, summary.Errors[0].Err, "FullContactError: Identity map failed for record c2 with status 400")
+
+	failing = false
+	summary, err = identitySync.Run(context.Background(), NewSliceSyncSource(getSyncTestRecords()))
+	assert.NoError(t, err)
+	assert.Equal(t, "created: 1, updated: 0, deleted: 0, unchanged: 2, failed: 0", summary.String())
+}
+
+func TestIdentitySyncMaxDeletes(t *testing.T) {
+	store := NewMemoryStore()
+	identitySync, _ := NewIdentitySync(getSyncTestClient(), store, WithSyncMaxDeletes(1))
+	_, err := identitySync.Run(context.Background(), NewSliceSyncSource(getSyncTestRecords()))
+	assert.NoError(t, err)
+	_, err = identitySync.Run(context.Background(), NewSliceSyncSource(nil))
+	assert.EqualError(t, err, "FullContactError: Sync would delete 3 records, more than the maximum of 1")
+}
+
+func TestIdentitySyncInvalidRecordsFail(t *testing.T) {
+	mockClient := getSyncTestClient()
+	identitySync, _ := NewIdentitySync(mockClient, NewMemoryStore())
+	records := getSyncTestRecords()
+	summary, err := identitySync.Run(context.Background(), NewSliceSyncSource(append(records, records[0], &SyncRecord{})))
+	assert.NoError(t, err)
+	assert.Equal(t, "created: 3, updated: 0, deleted: 0, unchanged: 0, failed: 2", summary.String())
+	assert.Equal(t, "", summary.Errors[0].RecordId)
+	assert.EqualError(t, summary.Errors[0].Err, "FullContactError: RecordId must be present for every record of the sync source")
+	assert.Equal(t, "c1", summary.Errors[1].RecordId)
+	assert.EqualError(t, summary.Errors[1].Err, "FullContactError: Duplicate record in sync source: c1")
+	assert.Len(t, mockClient.CallsTo("IdentityMap"), 3)
+
+	_, err = NewIdentitySync(getSyncTestClient(), nil)
+	assert.EqualError(t, err, "FullContactError: Store must be present for identity sync")
+}
+
+type syncTestSource struct {
+	SyncSource
+	failAfter int
+	read      int
+}
+
+func (source *syncTestSource) Next() bool {
+	source.read++
+	return source.read <= source.failAfter && source.SyncSource.Next()
+}
+
+func (source *syncTestSource) Err() error {
+	if source.read > source.failAfter {
+		return errors.New("source failed")
+	}
+	return nil
+}
+
+func TestIdentitySyncCheckpoints(t *testing.T) {
+	store := NewMemoryStore()
+	checkpointed := 0
+	mockClient := NewMockClient()
+	mockClient.On("IdentityMap", func(args ...interface{}) *APIResponse {
+		value, _ := store.Load("identity-sync/state")
+		if value != nil {
+			checkpointed++
+		}
+		return &APIResponse{StatusCode: 200}
+	})
+	identitySync, _ := NewIdentitySync(mockClient, store, WithSyncConcurrency(1), WithSyncCheckpointEvery(1))
+
+	summary, err := identitySync.Run(context.Background(), &syncTestSource{SyncSource: NewSliceSyncSource(getSyncTestRecords()), failAfter: 2})
+	assert.EqualError(t, err, "source failed")
+	assert.Equal(t, 2, summary.Created)
+	assert.Equal(t, 1, checkpointed)
+
+	summary, err = identitySync.Run(context.Background(), NewSliceSyncSource(getSyncTestRecords()))
+	assert.NoError(t, err)
+	assert.Equal(t, "created: 1, updated: 0, deleted: 0, unchanged: 2, failed: 0", summary.String())
+}
+
+func TestIdentitySyncCancelled(t *testing.T) {
+	store := NewMemoryStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	mockClient := NewMockClient()
+	mockClient.On("IdentityMap", func(args ...interface{}) *APIResponse {
+		cancel()
+		return &APIResponse{StatusCode: 200}
+	})
+	identitySync, _ := NewIdentitySync(mockClient, store, WithSyncConcurrency(1))
+
+	summary, err := identitySync.Run(ctx, NewSliceSyncSource(getSyncTestRecords()))
+	assert.Equal(t, context.Canceled, err)
+	assert.True(t, summary.Created < 3)
+
+	summary, err = identitySync.Run(context.Background(), NewSliceSyncSource(getSyncTestRecords()))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, summary.Created+summary.Unchanged)
+	assert.True(t, summary.Unchanged > 0)
+}
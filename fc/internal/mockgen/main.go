@@ -0,0 +1,125 @@
+/*
+Command mockgen generates the methods of MockClient from the interfaces declared in a source file.
+
+Every method of every interface in the source file must return `chan *APIResponse`; the generated
+method records the call and returns the programmed response.
+
+	go run ./internal/mockgen -source client_interface.go -destination mock_client_gen.go -type MockClient
+*/
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+)
+
+const returnType = "chan *APIResponse"
+
+func main() {
+	source := flag.String("source", "", "file declaring the interfaces")
+	destination := flag.String("destination", "", "file to write the generated methods to")
+	typeName := flag.String("type", "MockClient", "name of the mock type")
+	flag.Parse()
+	if *source == "" || *destination == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	code, err := generate(*source, *typeName)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	err = ioutil.WriteFile(*destination, code, 0644)
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func generate(source, typeName string) ([]byte, error) {
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, source, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "// Code generated by mockgen from %s; DO NOT EDIT.\n\n", source)
+	fmt.Fprintf(&buffer, "package %s\n", file.Name.Name)
+	// Imports of the source file are used by the method signatures.
+	for _, imp := range file.Imports {
+		if imp.Name != nil {
+			fmt.Fprintf(&buffer, "\nimport %s %s\n", imp.Name.Name, imp.Path.Value)
+		} else {
+			fmt.Fprintf(&buffer, "\nimport %s\n", imp.Path.Value)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			typeSpec := spec.(*ast.TypeSpec)
+			iface, ok := typeSpec.Type.(*ast.InterfaceType)
+			if !ok {
+				continue
+			}
+			for _, method := range iface.Methods.List {
+				funcType, ok := method.Type.(*ast.FuncType)
+				if !ok {
+					// Embedded interfaces are generated from their own declaration.
+					continue
+				}
+				name := method.Names[0].Name
+				if seen[name] {
+					continue
+				}
+				seen[name] = true
+				err = writeMethod(&buffer, fileSet, typeName, name, funcType)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return format.Source(buffer.Bytes())
+}
+
+func writeMethod(buffer *bytes.Buffer, fileSet *token.FileSet, typeName, name string, funcType *ast.FuncType) error {
+	if funcType.Results == nil || len(funcType.Results.List) != 1 || expr(fileSet, funcType.Results.List[0].Type) != returnType {
+		return fmt.Errorf("method %s must return %s", name, returnType)
+	}
+
+	params := make([]string, 0)
+	args := []string{fmt.Sprintf("%q", name)}
+	for _, field := range funcType.Params.List {
+		if len(field.Names) == 0 {
+			return fmt.Errorf("parameters of method %s must be named", name)
+		}
+		for _, paramName := range field.Names {
+			params = append(params, paramName.Name+" "+expr(fileSet, field.Type))
+			args = append(args, paramName.Name)
+		}
+	}
+	fmt.Fprintf(buffer, "\nfunc (mc *%s) %s(%s) %s {\n", typeName, name, strings.Join(params, ", "), returnType)
+	fmt.Fprintf(buffer, "\treturn mc.call(%s)\n}\n", strings.Join(args, ", "))
+	return nil
+}
+
+func expr(fileSet *token.FileSet, node ast.Expr) string {
+	var buffer bytes.Buffer
+	printer.Fprint(&buffer, fileSet, node)
+	return buffer.String()
+}
@@ -0,0 +1,137 @@
+package fullcontact
+
+import "sync"
+
+// MockCall records a single call made on a MockClient.
+type MockCall struct {
+	Method string
+	Args   []interface{}
+}
+
+// MockHandler computes the response of a MockClient method from the arguments it was called with.
+type MockHandler func(args ...interface{}) *APIResponse
+
+/*
+MockClient is an implementation of Client for tests. Responses are programmed per method name
+(e.g. "PersonEnrich") with On or Respond, and every call is recorded.
+
+The methods of MockClient are generated from the Client interface, see mock_client_gen.go.
+*/
+type MockClient struct {
+	mutex     sync.Mutex
+	handlers  map[string]MockHandler
+	responses map[string][]*APIResponse
+	calls     []MockCall
+}
+
+var _ Client = (*MockClient)(nil)
+
+// mockEndpoints maps the MockClient methods to the endpoint their responses are classified as.
+var mockEndpoints = map[string]string{
+	"PersonEnrich":            endpointName(personEnrichUrl),
+	"CompanyEnrich":           endpointName(companyEnrichUrl),
+	"IdentityMap":             endpointName(identityMapUrl),
+	"IdentityResolve":         endpointName(identityResolveUrl),
+	"IdentityMapResolve":      endpointName(identityMapResolveUrl),
+	"IdentityResolveWithTags": endpointName(identityResolveWithTagsUrl),
+	"IdentityDelete":          endpointName(identityDeleteUrl),
+	"TagsCreate":              endpointName(tagsCreateUrl),
+	"TagsGet":                 endpointName(tagsGetUrl),
+	"TagsDelete":              endpointName(tagsDeleteUrl),
+	"AudienceCreate":          endpointName(audienceCreateUrl),
+	"AudienceDownload":        endpointName(audienceDownloadUrl),
+	"AudienceDownloadTo":      endpointName(audienceDownloadUrl),
+	"AudienceDownloadToFile":  endpointName(audienceDownloadUrl),
+	"PermissionCreate":        endpointName(permissionCreateUrl),
+	"PermissionDelete":        endpointName(permissionDeleteUrl),
+	"PermissionFind":          endpointName(permissionFindUrl),
+	"PermissionCurrent":       endpointName(permissionCurrentUrl),
+	"PermissionVerify":        endpointName(permissionVerifyUrl),
+	"VerifySignals":           endpointName(verifySignalsUrl),
+	"VerifyMatch":             endpointName(verifyMatchUrl),
+	"VerifyActivity":          endpointName(verifyActivityUrl),
+}
+
+func NewMockClient() *MockClient {
+	return &MockClient{
+		handlers:  make(map[string]MockHandler),
+		responses: make(map[string][]*APIResponse),
+	}
+}
+
+// On sets a handler which computes the responses of the given method. It takes precedence over Respond.
+func (mc *MockClient) On(method string, handler MockHandler) *MockClient {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	mc.handlers[method] = handler
+	return mc
+}
+
+// Respond queues responses for the given method. They are returned in order and the last one is repeated.
+func (mc *MockClient) Respond(method string, responses ...*APIResponse) *MockClient {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	mc.responses[method] = append(mc.responses[method], responses...)
+	return mc
+}
+
+// Calls returns all the calls made so far, in order.
+func (mc *MockClient) Calls() []MockCall {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	calls := make([]MockCall, len(mc.calls))
+	copy(calls, mc.calls)
+	return calls
+}
+
+// CallsTo returns the calls made so far to the given method, in order.
+func (mc *MockClient) CallsTo(method string) []MockCall {
+	calls := make([]MockCall, 0)
+	for _, call := range mc.Calls() {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Reset removes all programmed responses and recorded calls.
+func (mc *MockClient) Reset() {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	mc.handlers = make(map[string]MockHandler)
+	mc.responses = make(map[string][]*APIResponse)
+	mc.calls = nil
+}
+
+func (mc *MockClient) call(method string, args ...interface{}) chan *APIResponse {
+	mc.mutex.Lock()
+	mc.calls = append(mc.calls, MockCall{Method: method, Args: args})
+	handler := mc.handlers[method]
+	var response *APIResponse
+	if handler == nil {
+		queued := mc.responses[method]
+		if len(queued) > 0 {
+			response = queued[0]
+			if len(queued) > 1 {
+				mc.responses[method] = queued[1:]
+			}
+		}
+	}
+	mc.mutex.Unlock()
+
+	if handler != nil {
+		response = handler(args...)
+	}
+	if response == nil {
+		response = &APIResponse{Err: NewFullContactError("MockClient: no response programmed for " + method)}
+	} else if response.endpoint == "" {
+		// Copy so that the same programmed response can be returned for several methods.
+		copied := *response
+		copied.endpoint = mockEndpoints[method]
+		response = &copied
+	}
+	ch := make(chan *APIResponse, 1)
+	ch <- response
+	return ch
+}
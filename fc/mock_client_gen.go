@@ -0,0 +1,93 @@
+// Code generated by mockgen from client_interface.go; DO NOT EDIT.
+
+package fullcontact
+
+import "io"
+
+func (mc *MockClient) PersonEnrich(personRequest *PersonRequest, options ...CallOption) chan *APIResponse {
+	return mc.call("PersonEnrich", personRequest, options)
+}
+
+func (mc *MockClient) CompanyEnrich(companyRequest *CompanyRequest, options ...CallOption) chan *APIResponse {
+	return mc.call("CompanyEnrich", companyRequest, options)
+}
+
+func (mc *MockClient) IdentityMap(resolveRequest *ResolveRequest, options ...CallOption) chan *APIResponse {
+	return mc.call("IdentityMap", resolveRequest, options)
+}
+
+func (mc *MockClient) IdentityResolve(resolveRequest *ResolveRequest, options ...CallOption) chan *APIResponse {
+	return mc.call("IdentityResolve", resolveRequest, options)
+}
+
+func (mc *MockClient) IdentityMapResolve(resolveRequest *ResolveRequest, options ...CallOption) chan *APIResponse {
+	return mc.call("IdentityMapResolve", resolveRequest, options)
+}
+
+func (mc *MockClient) IdentityResolveWithTags(resolveRequest *ResolveRequest, options ...CallOption) chan *APIResponse {
+	return mc.call("IdentityResolveWithTags", resolveRequest, options)
+}
+
+func (mc *MockClient) IdentityDelete(resolveRequest *ResolveRequest, options ...CallOption) chan *APIResponse {
+	return mc.call("IdentityDelete", resolveRequest, options)
+}
+
+func (mc *MockClient) TagsCreate(tagsRequest *TagsRequest, options ...CallOption) chan *APIResponse {
+	return mc.call("TagsCreate", tagsRequest, options)
+}
+
+func (mc *MockClient) TagsGet(recordId string, options ...CallOption) chan *APIResponse {
+	return mc.call("TagsGet", recordId, options)
+}
+
+func (mc *MockClient) TagsDelete(tagsRequest *TagsRequest, options ...CallOption) chan *APIResponse {
+	return mc.call("TagsDelete", tagsRequest, options)
+}
+
+func (mc *MockClient) AudienceCreate(audienceRequest *AudienceRequest, options ...CallOption) chan *APIResponse {
+	return mc.call("AudienceCreate", audienceRequest, options)
+}
+
+func (mc *MockClient) AudienceDownload(requestId string, options ...CallOption) chan *APIResponse {
+	return mc.call("AudienceDownload", requestId, options)
+}
+
+func (mc *MockClient) AudienceDownloadTo(requestId string, writer io.Writer, options ...CallOption) chan *APIResponse {
+	return mc.call("AudienceDownloadTo", requestId, writer, options)
+}
+
+func (mc *MockClient) AudienceDownloadToFile(requestId string, fileName string, options ...CallOption) chan *APIResponse {
+	return mc.call("AudienceDownloadToFile", requestId, fileName, options)
+}
+
+func (mc *MockClient) PermissionCreate(permissionRequest *PermissionRequest, options ...CallOption) chan *APIResponse {
+	return mc.call("PermissionCreate", permissionRequest, options)
+}
+
+func (mc *MockClient) PermissionDelete(multifieldRequest *MultifieldRequest, options ...CallOption) chan *APIResponse {
+	return mc.call("PermissionDelete", multifieldRequest, options)
+}
+
+func (mc *MockClient) PermissionFind(multifieldRequest *MultifieldRequest, options ...CallOption) chan *APIResponse {
+	return mc.call("PermissionFind", multifieldRequest, options)
+}
+
+func (mc *MockClient) PermissionCurrent(multifieldRequest *MultifieldRequest, options ...CallOption) chan *APIResponse {
+	return mc.call("PermissionCurrent", multifieldRequest, options)
+}
+
+func (mc *MockClient) PermissionVerify(permissionRequest *PermissionRequest, options ...CallOption) chan *APIResponse {
+	return mc.call("PermissionVerify", permissionRequest, options)
+}
+
+func (mc *MockClient) VerifySignals(multifieldRequest *MultifieldRequest, options ...CallOption) chan *APIResponse {
+	return mc.call("VerifySignals", multifieldRequest, options)
+}
+
+func (mc *MockClient) VerifyMatch(multifieldRequest *MultifieldRequest, options ...CallOption) chan *APIResponse {
+	return mc.call("VerifyMatch", multifieldRequest, options)
+}
+
+func (mc *MockClient) VerifyActivity(multifieldRequest *MultifieldRequest, options ...CallOption) chan *APIResponse {
+	return mc.call("VerifyActivity", multifieldRequest, options)
+}
@@ -0,0 +1,49 @@
+package fullcontact
+
+import (
+	assert "github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestMockClientRespond(t *testing.T) {
+	mock := NewMockClient().
+		Respond("TagsGet",
+			&APIResponse{StatusCode: 200, IsSuccessful: true, TagsResponse: &TagsResponse{RecordId: "r1"}},
+			&APIResponse{StatusCode: 404, IsSuccessful: true})
+	var tagger Tagger = mock
+
+	resp := <-tagger.TagsGet("r1")
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "r1", resp.TagsResponse.RecordId)
+	assert.Equal(t, 404, (<-tagger.TagsGet("r2")).StatusCode)
+	assert.Equal(t, 404, (<-tagger.TagsGet("r3")).StatusCode)
+
+	calls := mock.CallsTo("TagsGet")
+	assert.Len(t, calls, 3)
+	assert.Equal(t, "r2", calls[1].Args[0])
+}
+
+func TestMockClientOn(t *testing.T) {
+	mock := NewMockClient().On("PersonEnrich", func(args ...interface{}) *APIResponse {
+		personRequest := args[0].(*PersonRequest)
+		return &APIResponse{StatusCode: 200, IsSuccessful: true, PersonResponse: &PersonResp{Email: personRequest.Emails[0]}}
+	})
+	var client Client = mock
+
+	personRequest, _ := NewPersonRequest(WithEmail("marquitaross006@gmail.com"))
+	resp := <-client.PersonEnrich(personRequest)
+	assert.Equal(t, "marquitaross006@gmail.com", resp.PersonResponse.Email)
+	calls := mock.Calls()
+	assert.Len(t, calls, 1)
+	assert.Equal(t, "PersonEnrich", calls[0].Method)
+	assert.Equal(t, personRequest, calls[0].Args[0])
+}
+
+func TestMockClientWithoutResponse(t *testing.T) {
+	mock := NewMockClient()
+	resp := <-mock.AudienceDownload("requestId")
+	assert.EqualError(t, resp.Err, "FullContactError: MockClient: no response programmed for AudienceDownload")
+
+	mock.Reset()
+	assert.Empty(t, mock.Calls())
+}
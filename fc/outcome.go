@@ -0,0 +1,182 @@
+package fullcontact
+
+import "net/http"
+
+// Outcome classifies the result of a request independently of the endpoint specific status codes.
+type Outcome int
+
+const (
+	OutcomeUnknown Outcome = iota
+	// OutcomeMatched means the request succeeded, and for lookups that data was found.
+	OutcomeMatched
+	// OutcomeNoMatch means no data was found for the request (404).
+	OutcomeNoMatch
+	// OutcomeAccepted means the request was accepted and the result will be sent to the webhook (202).
+	OutcomeAccepted
+	// OutcomeDeleted means the delete request succeeded.
+	OutcomeDeleted
+	// OutcomeClientError means the request was invalid or not authorized, or was rejected before being sent.
+	OutcomeClientError
+	OutcomeRateLimited
+	OutcomeServerError
+	// OutcomeTransportError means no valid response was received, e.g. on timeouts or malformed bodies.
+	OutcomeTransportError
+)
+
+var outcomeNames = map[Outcome]string{
+	OutcomeUnknown:        "Unknown",
+	OutcomeMatched:        "Matched",
+	OutcomeNoMatch:        "NoMatch",
+	OutcomeAccepted:       "Accepted",
+	OutcomeDeleted:        "Deleted",
+	OutcomeClientError:    "ClientError",
+	OutcomeRateLimited:    "RateLimited",
+	OutcomeServerError:    "ServerError",
+	OutcomeTransportError: "TransportError",
+}
+
+func (outcome Outcome) String() string {
+	name, ok := outcomeNames[outcome]
+	if !ok {
+		return "Unknown"
+	}
+	return name
+}
+
+func (outcome Outcome) MarshalText() ([]byte, error) {
+	return []byte(outcome.String()), nil
+}
+
+func (outcome *Outcome) UnmarshalText(text []byte) error {
+	for value, name := range outcomeNames {
+		if name == string(text) {
+			*outcome = value
+			return nil
+		}
+	}
+	return NewFullContactError("Unknown outcome: " + string(text))
+}
+
+// statusOutcomes maps the status codes of successful responses of each endpoint to their outcome.
+// Status codes which aren't listed classify as Unknown below 400, see outcomeOf.
+var statusOutcomes = map[string]map[int]Outcome{
+	"person.enrich":       {http.StatusOK: OutcomeMatched, http.StatusAccepted: OutcomeAccepted},
+	"company.enrich":      {http.StatusOK: OutcomeMatched, http.StatusAccepted: OutcomeAccepted},
+	"identity.map":        {http.StatusOK: OutcomeMatched},
+	"identity.resolve":    {http.StatusOK: OutcomeMatched},
+	"identity.mapResolve": {http.StatusOK: OutcomeMatched},
+	"identity.delete":     {http.StatusOK: OutcomeDeleted, http.StatusNoContent: OutcomeDeleted},
+	"tags.create":         {http.StatusOK: OutcomeMatched},
+	"tags.get":            {http.StatusOK: OutcomeMatched},
+	"tags.delete":         {http.StatusOK: OutcomeDeleted, http.StatusNoContent: OutcomeDeleted},
+	"audience.create":     {http.StatusOK: OutcomeMatched, http.StatusAccepted: OutcomeAccepted},
+	"audience.download":   {http.StatusOK: OutcomeMatched},
+	"permission.create":   {http.StatusOK: OutcomeMatched, http.StatusAccepted: OutcomeAccepted},
+	"permission.delete":   {http.StatusOK: OutcomeDeleted, http.StatusNoContent: OutcomeDeleted},
+	"permission.find":     {http.StatusOK: OutcomeMatched},
+	"permission.current":  {http.StatusOK: OutcomeMatched},
+	"permission.verify":   {http.StatusOK: OutcomeMatched},
+	"verify.signals":      {http.StatusOK: OutcomeMatched},
+	"verify.match":        {http.StatusOK: OutcomeMatched},
+	"verify.activity":     {http.StatusOK: OutcomeMatched},
+}
+
+// defaultStatusOutcomes is used for responses whose endpoint isn't known, e.g. ones built by hand.
+var defaultStatusOutcomes = map[int]Outcome{http.StatusOK: OutcomeMatched, http.StatusAccepted: OutcomeAccepted}
+
+// outcomeOf classifies the status code of a response of the named endpoint, e.g. "tags.delete".
+func outcomeOf(endpoint string, statusCode int) Outcome {
+	outcomes, ok := statusOutcomes[endpoint]
+	if !ok {
+		outcomes = defaultStatusOutcomes
+	}
+	if outcome, ok := outcomes[statusCode]; ok {
+		return outcome
+	}
+	switch {
+	case statusCode == http.StatusNotFound:
+		return OutcomeNoMatch
+	case statusCode == http.StatusTooManyRequests:
+		return OutcomeRateLimited
+	case statusCode >= 500:
+		return OutcomeServerError
+	case statusCode >= 400:
+		return OutcomeClientError
+	}
+	return OutcomeUnknown
+}
+
+// classifyOutcome computes the outcome of a response of the endpoint with the given url.
+func classifyOutcome(url string, apiResponse *APIResponse) Outcome {
+	apiResponse.endpoint = endpointName(url)
+	if apiResponse.Err != nil {
+		if apiResponse.RawHttpResponse == nil && isClientSideError(apiResponse.Err) {
+			return OutcomeClientError
+		}
+		return OutcomeTransportError
+	}
+	if apiResponse.RawHttpResponse == nil {
+		return OutcomeUnknown
+	}
+	return outcomeOf(apiResponse.endpoint, apiResponse.RawHttpResponse.StatusCode)
+}
+
+func isClientSideError(err error) bool {
+	switch err.(type) {
+	case *FullContactError, *BudgetExceededError:
+		return true
+	}
+	return false
+}
+
+/*
+GetOutcome returns the Outcome of the response. If it isn't set, it's derived from Err and
+StatusCode in the same way as for responses of the client, using the endpoint of the response
+when it's known.
+*/
+func (resp *APIResponse) GetOutcome() Outcome {
+	if resp.Outcome != OutcomeUnknown {
+		return resp.Outcome
+	}
+	if resp.Err != nil {
+		if isClientSideError(resp.Err) {
+			return OutcomeClientError
+		}
+		return OutcomeTransportError
+	}
+	return outcomeOf(resp.endpoint, resp.StatusCode)
+}
+
+func (resp *APIResponse) IsMatched() bool {
+	return resp.GetOutcome() == OutcomeMatched
+}
+
+func (resp *APIResponse) IsNoMatch() bool {
+	return resp.GetOutcome() == OutcomeNoMatch
+}
+
+func (resp *APIResponse) IsAccepted() bool {
+	return resp.GetOutcome() == OutcomeAccepted
+}
+
+func (resp *APIResponse) IsDeleted() bool {
+	return resp.GetOutcome() == OutcomeDeleted
+}
+
+// IsError returns true for client, rate limit, server and transport errors.
+func (resp *APIResponse) IsError() bool {
+	switch resp.GetOutcome() {
+	case OutcomeClientError, OutcomeRateLimited, OutcomeServerError, OutcomeTransportError:
+		return true
+	}
+	return false
+}
+
+// IsRetryable returns true for errors which may succeed if the request is sent again later.
+func (resp *APIResponse) IsRetryable() bool {
+	switch resp.GetOutcome() {
+	case OutcomeRateLimited, OutcomeServerError, OutcomeTransportError:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package fullcontact
+
+import (
+	"encoding/json"
+	assert "github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestOutcomePerEndpoint(t *testing.T) {
+	cases := []struct {
+		url        string
+		statusCode int
+		outcome    Outcome
+	}{
+		{personEnrichUrl, 200, OutcomeMatched},
+		{personEnrichUrl, 202, OutcomeAccepted},
+		{personEnrichUrl, 404, OutcomeNoMatch},
+		{companyEnrichUrl, 400, OutcomeClientError},
+		{identityResolveUrl, 200, OutcomeMatched},
+		{identityDeleteUrl, 204, OutcomeDeleted},
+		{tagsDeleteUrl, 204, OutcomeDeleted},
+		{permissionDeleteUrl, 200, OutcomeDeleted},
+		{tagsGetUrl, 404, OutcomeNoMatch},
+		{audienceCreateUrl, 429, OutcomeRateLimited},
+		{verifyMatchUrl, 503, OutcomeServerError},
+		{permissionCreateUrl, 401, OutcomeClientError},
+		{tagsCreateUrl, 204, OutcomeUnknown},
+		{personEnrichUrl, 201, OutcomeUnknown},
+		{identityMapUrl, 302, OutcomeUnknown},
+	}
+	for _, c := range cases {
+		ch := make(chan *APIResponse)
+		fcTestClient, testServer := getTestServerAndClient(c.url, "", c.statusCode)
+		fcTestClient.retryHandler = fastRetryHandler{}
+		go fcTestClient.do(testServer.URL, nil, ch)
+		resp := <-ch
+		testServer.Close()
+		assert.Equal(t, c.outcome, resp.Outcome, "%s %d", c.url, c.statusCode)
+		resp.Outcome = OutcomeUnknown
+		assert.Equal(t, c.outcome, resp.GetOutcome(), "%s %d", c.url, c.statusCode)
+	}
+}
+
+func TestOutcomeOfErrors(t *testing.T) {
+	resp := <-(&fullContactClient{}).PersonEnrich(nil)
+	assert.Equal(t, OutcomeClientError, resp.Outcome)
+	assert.True(t, resp.IsError())
+	assert.False(t, resp.IsRetryable())
+
+	ch := make(chan *APIResponse)
+	fcTestClient, testServer := getTestServerAndClient(identityMapUrl, "{\"recordIds\":", 200)
+	defer testServer.Close()
+	go fcTestClient.do(testServer.URL, nil, ch)
+	resp = <-ch
+	assert.Equal(t, OutcomeTransportError, resp.Outcome)
+	assert.True(t, resp.IsRetryable())
+}
+
+func TestOutcomePredicatesWithoutOutcome(t *testing.T) {
+	assert.True(t, (&APIResponse{StatusCode: 200}).IsMatched())
+	assert.True(t, (&APIResponse{StatusCode: 404}).IsNoMatch())
+	assert.True(t, (&APIResponse{StatusCode: 202}).IsAccepted())
+	assert.True(t, (&APIResponse{StatusCode: 204, endpoint: "tags.delete"}).IsDeleted())
+	assert.Equal(t, OutcomeUnknown, (&APIResponse{StatusCode: 204}).GetOutcome())
+	assert.Equal(t, OutcomeUnknown, (&APIResponse{StatusCode: 204, endpoint: "tags.create"}).GetOutcome())
+	assert.True(t, (&APIResponse{StatusCode: 429}).IsRetryable())
+	assert.Equal(t, OutcomeUnknown, (&APIResponse{}).GetOutcome())
+}
+
+func TestMockClientOutcomes(t *testing.T) {
+	response := &APIResponse{StatusCode: 204}
+	mockClient := NewMockClient().Respond("TagsDelete", response).Respond("TagsCreate", response)
+	assert.True(t, (<-mockClient.TagsDelete(nil)).IsDeleted())
+	assert.Equal(t, OutcomeUnknown, (<-mockClient.TagsCreate(nil)).GetOutcome())
+}
+
+func TestOutcomeJSON(t *testing.T) {
+	bytes, err := json.Marshal(map[string]Outcome{"outcome": OutcomeNoMatch})
+	assert.NoError(t, err)
+	assert.Equal(t, "{\"outcome\":\"NoMatch\"}", string(bytes))
+
+	var outcomes map[string]Outcome
+	assert.NoError(t, json.Unmarshal(bytes, &outcomes))
+	assert.Equal(t, OutcomeNoMatch, outcomes["outcome"])
+}
@@ -0,0 +1,335 @@
+package fullcontact
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+)
+
+const personIdTrackerStoreKey = "personid-tracker"
+
+type PersonIdEventType string
+
+const (
+	// PersonIdChanged is emitted whenever the personIds of a record change.
+	PersonIdChanged PersonIdEventType = "PersonIdChanged"
+	// RecordsMerged is emitted when a record joins a personId which already had other records.
+	RecordsMerged PersonIdEventType = "RecordsMerged"
+	// RecordSplit is emitted when a record leaves a personId which keeps other records.
+	RecordSplit PersonIdEventType = "RecordSplit"
+)
+
+/*
+PersonIdEvent describes a change of the personIds of a record. Before and After map the personIds
+involved in the change to their sorted recordIds, before and after the change.
+*/
+type PersonIdEvent struct {
+	Type            PersonIdEventType   `json:"type"`
+	RecordId        string              `json:"recordId"`
+	BeforePersonIds []string            `json:"beforePersonIds"`
+	AfterPersonIds  []string            `json:"afterPersonIds"`
+	Before          map[string][]string `json:"before"`
+	After           map[string][]string `json:"after"`
+	Time            time.Time           `json:"time"`
+}
+
+type PersonIdTrackerOption func(pt *PersonIdTracker)
+
+/*
+PersonIdTracker compares the personIds successively resolved for the same recordId, and emits a
+PersonIdEvent for every change, so that data keyed by personId can be re-keyed. Registered as a
+CallObserver with WithCallObserver, it tracks the Identity Resolve and Identity MapResolve calls
+made through the client, with a recordId in the request: the personIds of a response without one
+can't be attributed to the records of the response. The first personIds seen for a record don't
+emit any event.
+
+Events are sent to the callbacks and channels in the order of the changes, from the goroutine of a
+call. Channels must be buffered and events are dropped when a channel is full, see DroppedEvents.
+*/
+type PersonIdTracker struct {
+	mutex     sync.Mutex
+	store     Store
+	persons   map[string][]string
+	records   map[string]map[string]bool
+	callbacks []func(event *PersonIdEvent)
+	channels  []chan<- *PersonIdEvent
+	// queue holds the events not delivered yet, in the order of the changes
+	queue      []*PersonIdEvent
+	delivering bool
+	dropped    int
+	now        func() time.Time
+}
+
+var _ CallObserver = (*PersonIdTracker)(nil)
+
+// NewPersonIdTracker creates a PersonIdTracker, loaded from the Store set with WithPersonIdTrackerStore if any.
+func NewPersonIdTracker(options ...PersonIdTrackerOption) (*PersonIdTracker, error) {
+	pt := &PersonIdTracker{
+		persons: make(map[string][]string),
+		records: make(map[string]map[string]bool),
+		now:     time.Now,
+	}
+	for _, opts := range options {
+		opts(pt)
+	}
+	for _, ch := range pt.channels {
+		if cap(ch) == 0 {
+			return nil, NewFullContactError("PersonIdEvent channel must be buffered")
+		}
+	}
+	if pt.store == nil {
+		return pt, nil
+	}
+	value, err := pt.store.Load(personIdTrackerStoreKey)
+	if err != nil || value == nil {
+		return pt, err
+	}
+	var persons map[string][]string
+	if err = json.Unmarshal(value, &persons); err != nil {
+		return nil, err
+	}
+	for recordId, personIds := range persons {
+		pt.set(recordId, personIds)
+	}
+	return pt, nil
+}
+
+// WithPersonIdTrackerStore persists the personIds of the records to the Store with Save.
+func WithPersonIdTrackerStore(store Store) PersonIdTrackerOption {
+	return func(pt *PersonIdTracker) {
+		pt.store = store
+	}
+}
+
+func WithPersonIdCallback(callback func(event *PersonIdEvent)) PersonIdTrackerOption {
+	return func(pt *PersonIdTracker) {
+		pt.callbacks = append(pt.callbacks, callback)
+	}
+}
+
+// WithPersonIdEventChannel sends the events to a buffered channel, dropping them when it's full.
+func WithPersonIdEventChannel(ch chan<- *PersonIdEvent) PersonIdTrackerOption {
+	return func(pt *PersonIdTracker) {
+		pt.channels = append(pt.channels, ch)
+	}
+}
+
+// Save persists the personIds of the records to its Store.
+func (pt *PersonIdTracker) Save() error {
+	if pt.store == nil {
+		return NewFullContactError("PersonIdTracker has no Store")
+	}
+	pt.mutex.Lock()
+	value, err := json.Marshal(pt.persons)
+	pt.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	return pt.store.Save(personIdTrackerStoreKey, value)
+}
+
+func (pt *PersonIdTracker) ObserveCall(call *CallRecord) {
+	resp := call.Response
+	switch call.Endpoint {
+	case endpointName(identityResolveUrl), endpointName(identityMapResolveUrl):
+		if !resp.IsMatched() {
+			return
+		}
+		var personIds []string
+		if resp.ResolveResponse != nil {
+			personIds = resp.ResolveResponse.PersonIds
+		} else if resp.ResolveResponseWithTags != nil {
+			personIds = resp.ResolveResponseWithTags.PersonIds
+		}
+		if len(personIds) == 0 {
+			return
+		}
+		// the personIds only belong to the record when the request was for a single recordId
+		var request ResolveRequest
+		if json.Unmarshal(call.Request, &request) == nil && isPopulated(request.RecordId) {
+			pt.Track(request.RecordId, personIds)
+		}
+	case endpointName(identityDeleteUrl):
+		var request ResolveRequest
+		if (resp.IsMatched() || resp.IsDeleted()) && json.Unmarshal(call.Request, &request) == nil {
+			pt.Forget(request.RecordId)
+		}
+	}
+}
+
+/*
+Track records the personIds resolved for the record, and emits and returns the events of the change.
+The events may be delivered by a concurrent call of Track, after the events it's delivering.
+*/
+func (pt *PersonIdTracker) Track(recordId string, personIds []string) []*PersonIdEvent {
+	if !isPopulated(recordId) {
+		return nil
+	}
+	after := sortedUnique(personIds)
+	pt.mutex.Lock()
+	before, known := pt.persons[recordId]
+	if known && equalStrings(before, after) {
+		pt.mutex.Unlock()
+		return nil
+	}
+	involved := sortedUnique(append(append([]string(nil), before...), after...))
+	beforeGroups := pt.groups(involved)
+	pt.set(recordId, after)
+	afterGroups := pt.groups(involved)
+	if !known {
+		pt.mutex.Unlock()
+		return nil
+	}
+
+	now := pt.now()
+	newEvent := func(eventType PersonIdEventType) *PersonIdEvent {
+		return &PersonIdEvent{
+			Type:            eventType,
+			RecordId:        recordId,
+			BeforePersonIds: before,
+			AfterPersonIds:  after,
+			Before:          beforeGroups,
+			After:           afterGroups,
+			Time:            now,
+		}
+	}
+	events := []*PersonIdEvent{newEvent(PersonIdChanged)}
+	for _, personId := range after {
+		if !containsString(before, personId) && len(beforeGroups[personId]) > 0 {
+			events = append(events, newEvent(RecordsMerged))
+			break
+		}
+	}
+	for _, personId := range before {
+		if !containsString(after, personId) && len(afterGroups[personId]) > 0 {
+			events = append(events, newEvent(RecordSplit))
+			break
+		}
+	}
+	pt.queue = append(pt.queue, events...)
+	pt.mutex.Unlock()
+
+	pt.deliver()
+	return events
+}
+
+/*
+deliver sends the queued events in order. Only one goroutine delivers at a time, the events queued
+meanwhile by other goroutines are delivered by it.
+*/
+func (pt *PersonIdTracker) deliver() {
+	pt.mutex.Lock()
+	if pt.delivering {
+		pt.mutex.Unlock()
+		return
+	}
+	pt.delivering = true
+	for len(pt.queue) > 0 {
+		event := pt.queue[0]
+		pt.queue = pt.queue[1:]
+		pt.mutex.Unlock()
+
+		for _, callback := range pt.callbacks {
+			callback(event)
+		}
+		dropped := 0
+		for _, ch := range pt.channels {
+			select {
+			case ch <- event:
+			default:
+				dropped++
+			}
+		}
+
+		pt.mutex.Lock()
+		pt.dropped += dropped
+	}
+	pt.delivering = false
+	pt.mutex.Unlock()
+}
+
+// DroppedEvents returns the number of events dropped because a channel was full.
+func (pt *PersonIdTracker) DroppedEvents() int {
+	pt.mutex.Lock()
+	defer pt.mutex.Unlock()
+	return pt.dropped
+}
+
+// Forget stops tracking the record, e.g. once deleted.
+func (pt *PersonIdTracker) Forget(recordId string) {
+	pt.mutex.Lock()
+	defer pt.mutex.Unlock()
+	pt.set(recordId, nil)
+	delete(pt.persons, recordId)
+}
+
+// PersonIds returns the last personIds resolved for the record, and false if the record isn't tracked.
+func (pt *PersonIdTracker) PersonIds(recordId string) ([]string, bool) {
+	pt.mutex.Lock()
+	defer pt.mutex.Unlock()
+	personIds, ok := pt.persons[recordId]
+	return append([]string(nil), personIds...), ok
+}
+
+func (pt *PersonIdTracker) set(recordId string, personIds []string) {
+	for _, personId := range pt.persons[recordId] {
+		delete(pt.records[personId], recordId)
+		if len(pt.records[personId]) == 0 {
+			delete(pt.records, personId)
+		}
+	}
+	pt.persons[recordId] = personIds
+	for _, personId := range personIds {
+		if pt.records[personId] == nil {
+			pt.records[personId] = make(map[string]bool)
+		}
+		pt.records[personId][recordId] = true
+	}
+}
+
+// groups maps the personIds to their sorted recordIds.
+func (pt *PersonIdTracker) groups(personIds []string) map[string][]string {
+	groups := make(map[string][]string, len(personIds))
+	for _, personId := range personIds {
+		recordIds := make([]string, 0, len(pt.records[personId]))
+		for recordId := range pt.records[personId] {
+			recordIds = append(recordIds, recordId)
+		}
+		sort.Strings(recordIds)
+		groups[personId] = recordIds
+	}
+	return groups
+}
+
+func sortedUnique(values []string) []string {
+	unique := make([]string, 0, len(values))
+	for _, value := range values {
+		if isPopulated(value) && !containsString(unique, value) {
+			unique = append(unique, value)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
+
+func containsString(values []string, value string) bool {
+	for _, existing := range values {
+		if existing == value {
+			return true
+		}
+	}
+	return false
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,119 @@
+package fullcontact
+
+import (
+	assert "github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestPersonIdTrackerChanged(t *testing.T) {
+	var received []*PersonIdEvent
+	tracker, err := NewPersonIdTracker(WithPersonIdCallback(func(event *PersonIdEvent) {
+		received = append(received, event)
+	}))
+	assert.NoError(t, err)
+
+	assert.Empty(t, tracker.Track("r1", []string{"p1"}))
+	assert.Empty(t, tracker.Track("r1", []string{"p1"}))
+	events := tracker.Track("r1", []string{"p2"})
+	assert.Len(t, events, 1)
+	assert.Equal(t, received, events)
+	assert.Equal(t, PersonIdChanged, events[0].Type)
+	assert.Equal(t, []string{"p1"}, events[0].BeforePersonIds)
+	assert.Equal(t, []string{"p2"}, events[0].AfterPersonIds)
+	assert.Equal(t, map[string][]string{"p1": {"r1"}, "p2": {}}, events[0].Before)
+	assert.Equal(t, map[string][]string{"p1": {}, "p2": {"r1"}}, events[0].After)
+}
+
+func TestPersonIdTrackerMergedAndSplit(t *testing.T) {
+	ch := make(chan *PersonIdEvent, 10)
+	tracker, _ := NewPersonIdTracker(WithPersonIdEventChannel(ch))
+	tracker.Track("r1", []string{"p1"})
+	tracker.Track("r2", []string{"p1"})
+	tracker.Track("r3", []string{"p2"})
+
+	events := tracker.Track("r2", []string{"p2"})
+	assert.Len(t, events, 3)
+	assert.Equal(t, PersonIdChanged, events[0].Type)
+	assert.Equal(t, RecordsMerged, events[1].Type)
+	assert.Equal(t, RecordSplit, events[2].Type)
+	assert.Equal(t, map[string][]string{"p1": {"r1", "r2"}, "p2": {"r3"}}, events[1].Before)
+	assert.Equal(t, map[string][]string{"p1": {"r1"}, "p2": {"r2", "r3"}}, events[1].After)
+	assert.Len(t, ch, 3)
+}
+
+func TestPersonIdTrackerObservesCalls(t *testing.T) {
+	tracker, _ := NewPersonIdTracker()
+	resolve := func(request string, recordIds, personIds []string) {
+		tracker.ObserveCall(&CallRecord{
+			Endpoint: endpointName(identityResolveUrl),
+			Request:  []byte(request),
+			Response: &APIResponse{StatusCode: 200, ResolveResponse: &ResolveResponse{RecordIds: recordIds, PersonIds: personIds}},
+		})
+	}
+	resolve(`{"emails":["marquitaross006@gmail.com"]}`, []string{"r1", "r2"}, []string{"p1"})
+	_, ok := tracker.PersonIds("r2")
+	assert.False(t, ok)
+	resolve(`{"recordId":"r1"}`, []string{"r1"}, []string{"p1"})
+	resolve(`{"recordId":"r3"}`, nil, []string{"p3"})
+	personIds, ok := tracker.PersonIds("r1")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"p1"}, personIds)
+	personIds, _ = tracker.PersonIds("r3")
+	assert.Equal(t, []string{"p3"}, personIds)
+
+	tracker.ObserveCall(&CallRecord{
+		Endpoint: endpointName(identityDeleteUrl),
+		Request:  []byte(`{"recordId":"r1"}`),
+		Response: &APIResponse{StatusCode: 204, Outcome: OutcomeDeleted},
+	})
+	_, ok = tracker.PersonIds("r1")
+	assert.False(t, ok)
+	assert.Empty(t, tracker.Track("r1", []string{"p2"}))
+}
+
+func TestPersonIdTrackerStore(t *testing.T) {
+	store := NewMemoryStore()
+	tracker, err := NewPersonIdTracker(WithPersonIdTrackerStore(store))
+	assert.NoError(t, err)
+	tracker.Track("r1", []string{"p1"})
+	tracker.Track("r2", []string{"p1"})
+	assert.NoError(t, tracker.Save())
+
+	loaded, err := NewPersonIdTracker(WithPersonIdTrackerStore(store))
+	assert.NoError(t, err)
+	events := loaded.Track("r1", []string{"p2"})
+	assert.Len(t, events, 2)
+	assert.Equal(t, RecordSplit, events[1].Type)
+}
+
+func TestPersonIdTrackerDeliversInOrder(t *testing.T) {
+	var tracker *PersonIdTracker
+	var received []string
+	tracker, _ = NewPersonIdTracker(WithPersonIdCallback(func(event *PersonIdEvent) {
+		received = append(received, string(event.Type)+" "+event.RecordId)
+		if event.Type == PersonIdChanged && event.RecordId == "r1" {
+			// tracked while the events of r1 are delivered, so delivered after them
+			tracker.Track("r2", []string{"p3"})
+		}
+	}))
+	tracker.Track("r1", []string{"p1"})
+	tracker.Track("r2", []string{"p1"})
+
+	events := tracker.Track("r1", []string{"p2"})
+	assert.Len(t, events, 2)
+	assert.Equal(t, []string{"PersonIdChanged r1", "RecordSplit r1", "PersonIdChanged r2"}, received)
+}
+
+func TestPersonIdTrackerDropsEventsOfFullChannels(t *testing.T) {
+	_, err := NewPersonIdTracker(WithPersonIdEventChannel(make(chan *PersonIdEvent)))
+	assert.EqualError(t, err, "FullContactError: PersonIdEvent channel must be buffered")
+
+	ch := make(chan *PersonIdEvent, 1)
+	tracker, err := NewPersonIdTracker(WithPersonIdEventChannel(ch))
+	assert.NoError(t, err)
+	tracker.Track("r1", []string{"p1"})
+	tracker.Track("r1", []string{"p2"})
+	tracker.Track("r1", []string{"p3"})
+	assert.Equal(t, []string{"p2"}, (<-ch).AfterPersonIds)
+	assert.Equal(t, 1, tracker.DroppedEvents())
+}
@@ -17,6 +17,7 @@ type ResolveRequest struct {
 	Placekey    string      `json:"placekey,omitempty"`
 	PanoramaId  string      `json:"panoramaId,omitempty"`
 	GeneratePid bool        `json:"generatePid,omitempty"`
+	tagSchema   *TagSchema
 }
 
 func NewResolveRequest(option ...ResolveRequestOption) (*ResolveRequest, error) {
@@ -24,6 +25,9 @@ func NewResolveRequest(option ...ResolveRequestOption) (*ResolveRequest, error)
 	for _, opt := range option {
 		opt(resolveRequest)
 	}
+	if err := resolveRequest.tagSchema.validateTags(resolveRequest.Tags); err != nil {
+		return nil, err
+	}
 	return resolveRequest, nil
 }
 
@@ -207,6 +211,13 @@ func WithProfilesForResolve(profiles []*Profile) ResolveRequestOption {
 	}
 }
 
+// WithTagSchemaForResolve validates the tags of the request against the schema when it's created.
+func WithTagSchemaForResolve(schema *TagSchema) ResolveRequestOption {
+	return func(resolveRequest *ResolveRequest) {
+		resolveRequest.tagSchema = schema
+	}
+}
+
 func WithTagForResolve(tag *Tag) ResolveRequestOption {
 	return func(resolveRequest *ResolveRequest) {
 		if resolveRequest.Tags == nil {
@@ -0,0 +1,146 @@
+package fullcontact
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+/*
+Store persists the state of long running workflows, so they can resume after a restart.
+Load returns nil and no error if the key is missing. Keys returns the keys starting with the
+prefix, sorted.
+*/
+type Store interface {
+	Load(key string) ([]byte, error)
+	Save(key string, value []byte) error
+	Delete(key string) error
+	Keys(prefix string) ([]string, error)
+}
+
+// MemoryStore is a Store keeping the values in memory, for tests and short lived processes.
+type MemoryStore struct {
+	mutex  sync.Mutex
+	values map[string][]byte
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{values: make(map[string][]byte)}
+}
+
+func (ms *MemoryStore) Load(key string) ([]byte, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	value, ok := ms.values[key]
+	if !ok {
+		return nil, nil
+	}
+	return append([]byte(nil), value...), nil
+}
+
+func (ms *MemoryStore) Save(key string, value []byte) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	ms.values[key] = append([]byte(nil), value...)
+	return nil
+}
+
+func (ms *MemoryStore) Delete(key string) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	delete(ms.values, key)
+	return nil
+}
+
+func (ms *MemoryStore) Keys(prefix string) ([]string, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	keys := make([]string, 0)
+	for key := range ms.values {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// FileStore is a Store keeping every value in a file of a directory. Values are written to a
+// temporary file first and renamed, so a crash never leaves a partially written value.
+type FileStore struct {
+	dir string
+}
+
+var _ Store = (*FileStore)(nil)
+
+func NewFileStore(dir string) (*FileStore, error) {
+	if !isPopulated(dir) {
+		return nil, NewFullContactError("Store directory must be present")
+	}
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (fs *FileStore) path(key string) string {
+	return filepath.Join(fs.dir, url.PathEscape(key))
+}
+
+func (fs *FileStore) Load(key string) ([]byte, error) {
+	value, err := ioutil.ReadFile(fs.path(key))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return value, err
+}
+
+func (fs *FileStore) Save(key string, value []byte) error {
+	tmp, err := ioutil.TempFile(fs.dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(value)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), fs.path(key))
+}
+
+func (fs *FileStore) Delete(key string) error {
+	err := os.Remove(fs.path(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (fs *FileStore) Keys(prefix string) ([]string, error) {
+	files, err := ioutil.ReadDir(fs.dir)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0)
+	for _, file := range files {
+		if file.IsDir() || strings.HasPrefix(file.Name(), ".tmp-") {
+			continue
+		}
+		key, err := url.PathUnescape(file.Name())
+		if err == nil && strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
@@ -0,0 +1,55 @@
+package fullcontact
+
+import (
+	assert "github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func testStore(t *testing.T, store Store) {
+	value, err := store.Load("missing")
+	assert.NoError(t, err)
+	assert.Nil(t, value)
+
+	assert.NoError(t, store.Save("audience/a1", []byte("one")))
+	assert.NoError(t, store.Save("audience/a2", []byte("two")))
+	assert.NoError(t, store.Save("tags/r1", []byte("three")))
+	assert.NoError(t, store.Save("audience/a1", []byte("four")))
+
+	value, err = store.Load("audience/a1")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("four"), value)
+	keys, err := store.Keys("audience/")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"audience/a1", "audience/a2"}, keys)
+
+	assert.NoError(t, store.Delete("audience/a1"))
+	assert.NoError(t, store.Delete("audience/a1"))
+	keys, err = store.Keys("")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"audience/a2", "tags/r1"}, keys)
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fc-store")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	store, err := NewFileStore(dir)
+	assert.NoError(t, err)
+	testStore(t, store)
+
+	reopened, err := NewFileStore(dir)
+	assert.NoError(t, err)
+	value, err := reopened.Load("tags/r1")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("three"), value)
+
+	_, err = NewFileStore("")
+	assert.EqualError(t, err, "FullContactError: Store directory must be present")
+}
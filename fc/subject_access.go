@@ -0,0 +1,237 @@
+package fullcontact
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+// SubjectAccessEntry holds the response of a single call made for a SubjectAccessReport.
+type SubjectAccessEntry struct {
+	Endpoint   string          `json:"endpoint"`
+	RecordId   string          `json:"recordId,omitempty"`
+	Time       time.Time       `json:"time"`
+	StatusCode int             `json:"statusCode,omitempty"`
+	Outcome    Outcome         `json:"outcome"`
+	Response   json.RawMessage `json:"response,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+/*
+SubjectAccessReport gathers what FullContact holds for a data subject: the ids resolved from the
+identifiers with their tags, the tags of every record, the permission history and current
+permissions, and optionally the Person Enrich data. Every entry holds the raw response of its call.
+*/
+type SubjectAccessReport struct {
+	GeneratedAt        time.Time             `json:"generatedAt"`
+	CompletedAt        time.Time             `json:"completedAt"`
+	Identifiers        *MultifieldRequest    `json:"identifiers"`
+	RecordIds          []string              `json:"recordIds"`
+	PersonIds          []string              `json:"personIds"`
+	PartnerIds         []string              `json:"partnerIds"`
+	Identity           *SubjectAccessEntry   `json:"identity"`
+	Tags               []*SubjectAccessEntry `json:"tags"`
+	PermissionHistory  *SubjectAccessEntry   `json:"permissionHistory"`
+	CurrentPermissions *SubjectAccessEntry   `json:"currentPermissions"`
+	Person             *SubjectAccessEntry   `json:"person,omitempty"`
+}
+
+// IsComplete returns false if any call of the report failed, in which case the export should be run again.
+func (report *SubjectAccessReport) IsComplete() bool {
+	entries := append([]*SubjectAccessEntry{report.Identity, report.PermissionHistory, report.CurrentPermissions, report.Person}, report.Tags...)
+	for _, entry := range entries {
+		if entry != nil && isPopulated(entry.Error) {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteJSON writes the report as indented JSON.
+func (report *SubjectAccessReport) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+type SubjectAccessOption func(sa *SubjectAccessExporter)
+
+/*
+SubjectAccessExporter builds the SubjectAccessReport of a data subject, for GDPR and CCPA access
+requests, with Identity Resolve with tags, Tags Get for every resolved record, Permission Find,
+Permission Current and optionally Person Enrich. Failed calls are reported in their entry rather
+than failing the export.
+*/
+type SubjectAccessExporter struct {
+	client     Client
+	enrich     bool
+	dataFilter []string
+	now        func() time.Time
+}
+
+func NewSubjectAccessExporter(client Client, options ...SubjectAccessOption) (*SubjectAccessExporter, error) {
+	if client == nil {
+		return nil, NewFullContactError("Client can't be nil")
+	}
+	sa := &SubjectAccessExporter{client: client, now: time.Now}
+	for _, opts := range options {
+		opts(sa)
+	}
+	return sa, nil
+}
+
+// WithSubjectAccessPersonEnrich adds the Person Enrich data of the subject to the report, for the data packs given.
+func WithSubjectAccessPersonEnrich(dataFilter ...string) SubjectAccessOption {
+	return func(sa *SubjectAccessExporter) {
+		sa.enrich = true
+		sa.dataFilter = dataFilter
+	}
+}
+
+// ExportResolveRequest builds the report of the subject identified by the identifiers of a ResolveRequest.
+func (sa *SubjectAccessExporter) ExportResolveRequest(ctx context.Context, resolveRequest *ResolveRequest, options ...CallOption) (*SubjectAccessReport, error) {
+	if resolveRequest == nil {
+		return nil, NewFullContactError("ResolveRequest can't be nil")
+	}
+	return sa.Export(ctx, &MultifieldRequest{
+		Emails:     resolveRequest.Emails,
+		Phones:     resolveRequest.Phones,
+		Maids:      resolveRequest.Maid,
+		Location:   resolveRequest.Location,
+		Name:       resolveRequest.Name,
+		Profiles:   resolveRequest.Profiles,
+		PersonId:   resolveRequest.PersonId,
+		RecordId:   resolveRequest.RecordId,
+		PartnerId:  resolveRequest.PartnerId,
+		LiNonId:    resolveRequest.LiNonId,
+		Placekey:   resolveRequest.Placekey,
+		PanoramaId: resolveRequest.PanoramaId,
+	}, options...)
+}
+
+// Export builds the report of the subject identified by the identifiers of a MultifieldRequest.
+func (sa *SubjectAccessExporter) Export(ctx context.Context, multifieldRequest *MultifieldRequest, options ...CallOption) (*SubjectAccessReport, error) {
+	if multifieldRequest == nil {
+		return nil, NewFullContactError("MultifieldRequest can't be nil")
+	}
+	options = append([]CallOption{WithCallContext(ctx)}, options...)
+	report := &SubjectAccessReport{
+		GeneratedAt: sa.now(),
+		Identifiers: multifieldRequest,
+		RecordIds:   make([]string, 0),
+		PersonIds:   make([]string, 0),
+		PartnerIds:  make([]string, 0),
+		Tags:        make([]*SubjectAccessEntry, 0),
+	}
+
+	resp := <-sa.client.IdentityResolveWithTags(resolveRequestFromMultifield(multifieldRequest), options...)
+	report.Identity = sa.entry(endpointName(identityResolveUrl), "", resp)
+	if resp.ResolveResponseWithTags != nil && resp.IsMatched() {
+		report.RecordIds = append(report.RecordIds, resp.ResolveResponseWithTags.RecordIds...)
+		report.PersonIds = append(report.PersonIds, resp.ResolveResponseWithTags.PersonIds...)
+		report.PartnerIds = append(report.PartnerIds, resp.ResolveResponseWithTags.PartnerIds...)
+	}
+	if isPopulated(multifieldRequest.RecordId) && !containsString(report.RecordIds, multifieldRequest.RecordId) {
+		report.RecordIds = append(report.RecordIds, multifieldRequest.RecordId)
+	}
+
+	for _, recordId := range report.RecordIds {
+		report.Tags = append(report.Tags, sa.entry(endpointName(tagsGetUrl), recordId, <-sa.client.TagsGet(recordId, options...)))
+	}
+	report.PermissionHistory = sa.entry(endpointName(permissionFindUrl), "", <-sa.client.PermissionFind(multifieldRequest, options...))
+	report.CurrentPermissions = sa.entry(endpointName(permissionCurrentUrl), "", <-sa.client.PermissionCurrent(multifieldRequest, options...))
+
+	if sa.enrich {
+		personRequest := &PersonRequest{
+			Emails:     multifieldRequest.Emails,
+			Phones:     multifieldRequest.Phones,
+			DataFilter: sa.dataFilter,
+			Maid:       multifieldRequest.Maids,
+			Location:   multifieldRequest.Location,
+			Name:       multifieldRequest.Name,
+			Profiles:   multifieldRequest.Profiles,
+			RecordId:   multifieldRequest.RecordId,
+			PersonId:   multifieldRequest.PersonId,
+			PartnerId:  multifieldRequest.PartnerId,
+			LiNonId:    multifieldRequest.LiNonId,
+			Placekey:   multifieldRequest.Placekey,
+			PanoramaId: multifieldRequest.PanoramaId,
+		}
+		report.Person = sa.entry(endpointName(personEnrichUrl), "", <-sa.client.PersonEnrich(personRequest, options...))
+	}
+	report.CompletedAt = sa.now()
+	return report, nil
+}
+
+func resolveRequestFromMultifield(multifieldRequest *MultifieldRequest) *ResolveRequest {
+	return &ResolveRequest{
+		Emails:     multifieldRequest.Emails,
+		Phones:     multifieldRequest.Phones,
+		Maid:       multifieldRequest.Maids,
+		Location:   multifieldRequest.Location,
+		Name:       multifieldRequest.Name,
+		Profiles:   multifieldRequest.Profiles,
+		RecordId:   multifieldRequest.RecordId,
+		PersonId:   multifieldRequest.PersonId,
+		PartnerId:  multifieldRequest.PartnerId,
+		LiNonId:    multifieldRequest.LiNonId,
+		Placekey:   multifieldRequest.Placekey,
+		PanoramaId: multifieldRequest.PanoramaId,
+	}
+}
+
+func (sa *SubjectAccessExporter) entry(endpoint string, recordId string, resp *APIResponse) *SubjectAccessEntry {
+	entry := &SubjectAccessEntry{
+		Endpoint:   endpoint,
+		RecordId:   recordId,
+		Time:       sa.now(),
+		StatusCode: resp.StatusCode,
+		Outcome:    resp.GetOutcome(),
+		Response:   rawResponseBody(resp),
+	}
+	if resp.Err != nil {
+		entry.Error = resp.Err.Error()
+	} else if resp.IsError() {
+		entry.Error = "Request failed with status " + resp.Status
+		if !isPopulated(resp.Status) {
+			entry.Error = "Request failed with outcome " + entry.Outcome.String()
+		}
+	}
+	return entry
+}
+
+// rawResponseBody returns the JSON body of the response, or its decoded content if the body isn't available.
+func rawResponseBody(resp *APIResponse) json.RawMessage {
+	if resp.RawHttpResponse != nil && resp.RawHttpResponse.Body != nil {
+		body, err := ioutil.ReadAll(resp.RawHttpResponse.Body)
+		resp.RawHttpResponse.Body = ioutil.NopCloser(bytes.NewBuffer(body))
+		if err == nil && json.Valid(body) {
+			return body
+		}
+	}
+	var decoded interface{}
+	switch {
+	case resp.PersonResponse != nil:
+		decoded = resp.PersonResponse
+	case resp.ResolveResponseWithTags != nil:
+		decoded = resp.ResolveResponseWithTags
+	case resp.ResolveResponse != nil:
+		decoded = resp.ResolveResponse
+	case resp.TagsResponse != nil:
+		decoded = resp.TagsResponse
+	case resp.PermissionFindResponse != nil:
+		decoded = resp.PermissionFindResponse
+	case resp.PermissionCurrentResponse != nil:
+		decoded = resp.PermissionCurrentResponse
+	default:
+		return nil
+	}
+	body, err := json.Marshal(decoded)
+	if err != nil {
+		return nil
+	}
+	return body
+}
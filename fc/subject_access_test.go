@@ -0,0 +1,90 @@
+package fullcontact
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	assert "github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func getSubjectAccessTestClient(t *testing.T) *fullContactClient {
+	transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		switch req.URL.String() {
+		case identityResolveWithTagsUrl:
+			return chaosResponse(req, 200, `{"recordIds":["r1","r2"],"personIds":["p1"],"tags":{"r1":[{"key":"segment","value":"vip"}]}}`), nil
+		case tagsGetUrl:
+			body, _ := ioutil.ReadAll(req.Body)
+			if strings.Contains(string(body), "r2") {
+				return chaosResponse(req, 404, `{"status":404,"message":"Not Found"}`), nil
+			}
+			return chaosResponse(req, 200, `{"recordId":"r1","tags":[{"key":"segment","value":"vip"}]}`), nil
+		case permissionFindUrl:
+			return chaosResponse(req, 200, `[{"permissionType":"collect","permissionId":"1"}]`), nil
+		case permissionCurrentUrl:
+			return chaosResponse(req, 503, ""), nil
+		case personEnrichUrl:
+			return chaosResponse(req, 200, `{"fullName":"Marquita H Ross"}`), nil
+		}
+		return chaosResponse(req, 400, "{}"), nil
+	})
+	fcClient, err := NewFullContactClient(
+		WithCredentialsProvider(StaticCredentialsProvider{apiKey: "apikey"}),
+		WithHTTPClient(&http.Client{Transport: transport}),
+		WithRetryHandler(fastRetryHandler{attempts: 0}))
+	assert.NoError(t, err)
+	return fcClient
+}
+
+func TestSubjectAccessExport(t *testing.T) {
+	exporter, err := NewSubjectAccessExporter(getSubjectAccessTestClient(t), WithSubjectAccessPersonEnrich("core"))
+	assert.NoError(t, err)
+	now := time.Date(2020, 10, 19, 12, 0, 0, 0, time.UTC)
+	exporter.now = func() time.Time { return now }
+
+	report, err := exporter.Export(context.Background(), &MultifieldRequest{Emails: []string{"marquitaross006@gmail.com"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"r1", "r2"}, report.RecordIds)
+	assert.Equal(t, []string{"p1"}, report.PersonIds)
+	assert.Equal(t, now, report.GeneratedAt)
+	assert.Equal(t, OutcomeMatched, report.Identity.Outcome)
+	assert.Len(t, report.Tags, 2)
+	assert.Equal(t, "r2", report.Tags[1].RecordId)
+	assert.Equal(t, OutcomeNoMatch, report.Tags[1].Outcome)
+	assert.JSONEq(t, `[{"permissionType":"collect","permissionId":"1"}]`, string(report.PermissionHistory.Response))
+	assert.Equal(t, "Request failed with status 503 Service Unavailable", report.CurrentPermissions.Error)
+	assert.JSONEq(t, `{"fullName":"Marquita H Ross"}`, string(report.Person.Response))
+	assert.False(t, report.IsComplete())
+
+	var buffer bytes.Buffer
+	assert.NoError(t, report.WriteJSON(&buffer))
+	var decoded map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buffer.Bytes(), &decoded))
+	assert.Equal(t, "2020-10-19T12:00:00Z", decoded["generatedAt"])
+	assert.Equal(t, "person.enrich", decoded["person"].(map[string]interface{})["endpoint"])
+}
+
+func TestSubjectAccessExportResolveRequestWithMock(t *testing.T) {
+	mockClient := NewMockClient()
+	mockClient.Respond("IdentityResolveWithTags", &APIResponse{StatusCode: 404})
+	mockClient.Respond("TagsGet", &APIResponse{StatusCode: 200, TagsResponse: &TagsResponse{RecordId: "r9"}})
+	mockClient.Respond("PermissionFind", &APIResponse{StatusCode: 200, PermissionFindResponse: []*PermissionFindResponse{}})
+	mockClient.Respond("PermissionCurrent", &APIResponse{StatusCode: 200})
+	exporter, _ := NewSubjectAccessExporter(mockClient)
+
+	report, err := exporter.ExportResolveRequest(context.Background(), &ResolveRequest{RecordId: "r9"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"r9"}, report.RecordIds)
+	assert.JSONEq(t, `{"recordId":"r9","partnerId":"","tags":null}`, string(report.Tags[0].Response))
+	assert.Equal(t, "r9", mockClient.CallsTo("PermissionFind")[0].Args[0].(*MultifieldRequest).RecordId)
+	assert.Nil(t, report.Person)
+	assert.Empty(t, mockClient.CallsTo("PersonEnrich"))
+	assert.True(t, report.IsComplete())
+
+	_, err = exporter.Export(context.Background(), nil)
+	assert.EqualError(t, err, "FullContactError: MultifieldRequest can't be nil")
+}
@@ -42,8 +42,9 @@ func (tag *Tag) isValid() bool {
 type TagsRequestOption func(tr *TagsRequest)
 
 type TagsRequest struct {
-	RecordId string `json:"recordId,omitempty"`
-	Tags     []*Tag `json:"tags,omitempty"`
+	RecordId  string `json:"recordId,omitempty"`
+	Tags      []*Tag `json:"tags,omitempty"`
+	tagSchema *TagSchema
 }
 
 func NewTagsRequest(option ...TagsRequestOption) (*TagsRequest, error) {
@@ -53,6 +54,9 @@ func NewTagsRequest(option ...TagsRequestOption) (*TagsRequest, error) {
 		opt(tagsRequest)
 	}
 	err := validateTagsRequest(tagsRequest)
+	if err == nil {
+		err = tagsRequest.tagSchema.validateTags(tagsRequest.Tags)
+	}
 	if err != nil {
 		tagsRequest = nil
 	}
@@ -92,6 +96,13 @@ func WithTags(tags []*Tag) TagsRequestOption {
 	}
 }
 
+// WithTagSchemaForTags validates the tags of the request against the schema when it's created.
+func WithTagSchemaForTags(schema *TagSchema) TagsRequestOption {
+	return func(tagsRequest *TagsRequest) {
+		tagsRequest.tagSchema = schema
+	}
+}
+
 func WithRecordIdForTags(recordId string) TagsRequestOption {
 	return func(tagsRequest *TagsRequest) {
 		tagsRequest.RecordId = recordId
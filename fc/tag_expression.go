@@ -0,0 +1,510 @@
+package fullcontact
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+TagExpression is a boolean expression over tags defining an audience, e.g.
+
+	segment:vip AND (region:us OR region:ca) AND NOT status:churned
+
+Terms are "key:value", quoted with double quotes if they contain spaces or parentheses
+(e.g. name:"Jane Doe"), combined with NOT, AND and OR, in decreasing precedence. Within
+quotes, \" and \\ stand for a double quote and a backslash. The term "key:*" matches any
+value of the key, while key:"*" matches the value * itself.
+
+The Audience API accepts a list of tags, matching the records having any of them, so an
+expression is compiled into the list of its tags (see Tags) and the downloaded audience is
+filtered with Matches when the expression isn't a plain OR of tags (see NeedsPostFilter).
+*/
+type TagExpression struct {
+	root tagNode
+	tags []*Tag
+}
+
+type tagNode interface {
+	eval(tags tagMatcher) bool
+	format(parent int) string
+}
+
+// tagMatcher is what a tag expression is evaluated against, e.g. the tags of a record.
+type tagMatcher interface {
+	hasTag(tag Tag) bool
+	hasKey(key string) bool
+}
+
+// tagSet is the tagMatcher of a set of tags.
+type tagSet map[Tag]bool
+
+func newTagSet(tags []*Tag) tagSet {
+	set := make(tagSet, len(tags))
+	for _, tag := range tags {
+		if tag != nil {
+			set[*tag] = true
+		}
+	}
+	return set
+}
+
+func (set tagSet) hasTag(tag Tag) bool {
+	return set[tag]
+}
+
+func (set tagSet) hasKey(key string) bool {
+	for tag := range set {
+		if tag.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// tagKeysOnly is the tagMatcher of a record having none of the tags of an expression, only some keys.
+type tagKeysOnly map[string]bool
+
+func (keys tagKeysOnly) hasTag(Tag) bool {
+	return false
+}
+
+func (keys tagKeysOnly) hasKey(key string) bool {
+	return keys[key]
+}
+
+// Precedence of the nodes, used to format the expression with the parentheses needed
+const (
+	precedenceOr = iota
+	precedenceAnd
+	precedenceNot
+)
+
+type tagTerm struct {
+	tag Tag
+}
+
+// tagKeyTerm is a "key:*" term, matching any value of the key.
+type tagKeyTerm struct {
+	key string
+}
+
+type tagNot struct {
+	node tagNode
+}
+
+type tagAnd struct {
+	nodes []tagNode
+}
+
+type tagOr struct {
+	nodes []tagNode
+}
+
+func (term *tagTerm) eval(tags tagMatcher) bool {
+	return tags.hasTag(term.tag)
+}
+
+func (term *tagTerm) format(int) string {
+	return quoteTagPart(term.tag.Key) + ":" + quoteTagPart(term.tag.Value)
+}
+
+func (term *tagKeyTerm) eval(tags tagMatcher) bool {
+	return tags.hasKey(term.key)
+}
+
+func (term *tagKeyTerm) format(int) string {
+	return quoteTagPart(term.key) + ":*"
+}
+
+func (not *tagNot) eval(tags tagMatcher) bool {
+	return !not.node.eval(tags)
+}
+
+func (not *tagNot) format(int) string {
+	return "NOT " + not.node.format(precedenceNot)
+}
+
+func (and *tagAnd) eval(tags tagMatcher) bool {
+	for _, node := range and.nodes {
+		if !node.eval(tags) {
+			return false
+		}
+	}
+	return true
+}
+
+func (and *tagAnd) format(parent int) string {
+	return formatTagNodes(and.nodes, " AND ", precedenceAnd, parent)
+}
+
+func (or *tagOr) eval(tags tagMatcher) bool {
+	for _, node := range or.nodes {
+		if node.eval(tags) {
+			return true
+		}
+	}
+	return false
+}
+
+func (or *tagOr) format(parent int) string {
+	return formatTagNodes(or.nodes, " OR ", precedenceOr, parent)
+}
+
+func formatTagNodes(nodes []tagNode, operator string, precedence, parent int) string {
+	parts := make([]string, len(nodes))
+	for i, node := range nodes {
+		parts[i] = node.format(precedence)
+	}
+	formatted := strings.Join(parts, operator)
+	if parent > precedence {
+		return "(" + formatted + ")"
+	}
+	return formatted
+}
+
+// quoteTagPart quotes the part of a term if needed, with the escapes scanTagWord understands.
+func quoteTagPart(part string) string {
+	if part == "" || part == "*" || strings.ContainsAny(part, " \t\r\n():\"") {
+		return `"` + tagPartEscaper.Replace(part) + `"`
+	}
+	return part
+}
+
+var tagPartEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// ParseTagExpression parses and validates a tag expression.
+func ParseTagExpression(expression string) (*TagExpression, error) {
+	te, err := parseTagQuery(expression)
+	if err != nil {
+		return nil, err
+	}
+	if te.matchesWithoutTags() {
+		return nil, NewFullContactError("Tag expression matches records without any of its tags, which the Audience API can't select: " + te.String())
+	}
+	return te, nil
+}
+
+// maxAssumedKeys bounds the combinations of "key:*" terms tried by matchesWithoutTags.
+const maxAssumedKeys = 10
+
+/*
+matchesWithoutTags returns true if the expression matches a record having none of the tags it
+collects, whatever keys of its "key:*" terms the record has. With more than maxAssumedKeys keys,
+only the records having all or none of them are tried.
+*/
+func (te *TagExpression) matchesWithoutTags() bool {
+	keys := make([]string, 0)
+	te.collectKeys(te.root, make(map[string]bool), &keys)
+	combinations := 1 << uint(len(keys))
+	if len(keys) > maxAssumedKeys {
+		combinations = 2
+	}
+	for combination := 0; combination < combinations; combination++ {
+		present := make(tagKeysOnly, len(keys))
+		for i, key := range keys {
+			present[key] = combination&(1<<uint(i)) != 0
+			if len(keys) > maxAssumedKeys {
+				present[key] = combination == 1
+			}
+		}
+		if te.root.eval(present) {
+			return true
+		}
+	}
+	return false
+}
+
+func (te *TagExpression) collectKeys(node tagNode, seen map[string]bool, keys *[]string) {
+	switch n := node.(type) {
+	case *tagKeyTerm:
+		if !seen[n.key] {
+			seen[n.key] = true
+			*keys = append(*keys, n.key)
+		}
+	case *tagNot:
+		te.collectKeys(n.node, seen, keys)
+	case *tagAnd:
+		for _, child := range n.nodes {
+			te.collectKeys(child, seen, keys)
+		}
+	case *tagOr:
+		for _, child := range n.nodes {
+			te.collectKeys(child, seen, keys)
+		}
+	}
+}
+
+// parseTagQuery parses a tag expression which may match records without any of its tags, e.g. "NOT region:us".
+func parseTagQuery(expression string) (*TagExpression, error) {
+	tokens, err := tokenizeTagExpression(expression)
+	if err != nil {
+		return nil, err
+	}
+	parser := &tagExpressionParser{expression: expression, tokens: tokens}
+	root, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if parser.pos < len(tokens) {
+		return nil, parser.errorAt(tokens[parser.pos], "unexpected "+tokens[parser.pos].text)
+	}
+
+	te := &TagExpression{root: root}
+	te.collectTags(root, true, make(map[Tag]bool))
+	return te, nil
+}
+
+// collectTags collects the tags of the terms not negated, in order of appearance.
+func (te *TagExpression) collectTags(node tagNode, positive bool, seen map[Tag]bool) {
+	switch n := node.(type) {
+	case *tagTerm:
+		if positive && !seen[n.tag] {
+			seen[n.tag] = true
+			tag := n.tag
+			te.tags = append(te.tags, &tag)
+		}
+	case *tagNot:
+		te.collectTags(n.node, !positive, seen)
+	case *tagAnd:
+		for _, child := range n.nodes {
+			te.collectTags(child, positive, seen)
+		}
+	case *tagOr:
+		for _, child := range n.nodes {
+			te.collectTags(child, positive, seen)
+		}
+	}
+}
+
+// String returns the expression in canonical form.
+func (te *TagExpression) String() string {
+	return te.root.format(precedenceOr)
+}
+
+// Tags returns the tags to request from the Audience API, a superset of the records matching the expression.
+func (te *TagExpression) Tags() []*Tag {
+	tags := make([]*Tag, len(te.tags))
+	for i, tag := range te.tags {
+		tagCopy := *tag
+		tags[i] = &tagCopy
+	}
+	return tags
+}
+
+// NeedsPostFilter returns false if the expression is a plain OR of tags, which the Audience API selects exactly.
+func (te *TagExpression) NeedsPostFilter() bool {
+	switch root := te.root.(type) {
+	case *tagTerm:
+		return false
+	case *tagOr:
+		for _, node := range root.nodes {
+			if _, ok := node.(*tagTerm); !ok {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// Matches returns true if the tags satisfy the expression.
+func (te *TagExpression) Matches(tags []*Tag) bool {
+	return te.root.eval(newTagSet(tags))
+}
+
+// MatchesRecord returns true if the tags of the audience record satisfy the expression, see WithAudienceRecordFilter.
+func (te *TagExpression) MatchesRecord(record *AudienceRecord) bool {
+	return te.Matches(record.Tags)
+}
+
+// WithTagExpressionForAudience adds the tags of the expression to the AudienceRequest.
+func WithTagExpressionForAudience(expression *TagExpression) AudienceRequestOption {
+	return WithTagsForAudience(expression.Tags())
+}
+
+type tagToken struct {
+	text string
+	pos  int
+	// term is set for "key:value" terms
+	term *Tag
+	// anyValue is set for "key:*" terms, with an unquoted *
+	anyValue bool
+}
+
+func tokenizeTagExpression(expression string) ([]*tagToken, error) {
+	tokens := make([]*tagToken, 0)
+	i := 0
+	for i < len(expression) {
+		c := expression[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, &tagToken{text: string(c), pos: i})
+			i++
+		default:
+			token, err := scanTagWord(expression, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token)
+			i += len(token.text)
+		}
+	}
+	return tokens, nil
+}
+
+// scanTagWord scans an operator or a term, with double quoted parts, starting at start.
+func scanTagWord(expression string, start int) (*tagToken, error) {
+	var parts [2]strings.Builder
+	part := 0
+	quoted := false
+	i := start
+	for i < len(expression) {
+		c := expression[i]
+		if c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '(' || c == ')' {
+			break
+		}
+		switch {
+		case c == '"':
+			end, err := scanTagQuote(expression, i, &parts[part])
+			if err != nil {
+				return nil, err
+			}
+			quoted = quoted || part == 1
+			i = end
+		case c == ':' && part == 0:
+			part = 1
+			i++
+		default:
+			parts[part].WriteByte(c)
+			i++
+		}
+	}
+	token := &tagToken{text: expression[start:i], pos: start}
+	if part == 1 {
+		tag := Tag{Key: parts[0].String(), Value: parts[1].String()}
+		if !tag.isValid() {
+			return nil, NewFullContactError(fmt.Sprintf("Invalid tag expression at position %d: invalid tag %s", start, token.text))
+		}
+		token.term = &tag
+		token.anyValue = tag.Value == "*" && !quoted
+	}
+	return token, nil
+}
+
+// scanTagQuote writes the unescaped content of the quote starting at start, and returns the position after it.
+func scanTagQuote(expression string, start int, part *strings.Builder) (int, error) {
+	for i := start + 1; i < len(expression); i++ {
+		switch expression[i] {
+		case '"':
+			return i + 1, nil
+		case '\\':
+			// only a double quote and a backslash are escaped, other backslashes are kept
+			if i+1 < len(expression) && (expression[i+1] == '"' || expression[i+1] == '\\') {
+				i++
+			}
+		}
+		part.WriteByte(expression[i])
+	}
+	return 0, NewFullContactError(fmt.Sprintf("Invalid tag expression at position %d: unterminated quote", start))
+}
+
+type tagExpressionParser struct {
+	expression string
+	tokens     []*tagToken
+	pos        int
+}
+
+func (parser *tagExpressionParser) errorAt(token *tagToken, message string) error {
+	pos := len(parser.expression)
+	if token != nil {
+		pos = token.pos
+	}
+	return NewFullContactError(fmt.Sprintf("Invalid tag expression at position %d: %s", pos, message))
+}
+
+func (parser *tagExpressionParser) peek() *tagToken {
+	if parser.pos < len(parser.tokens) {
+		return parser.tokens[parser.pos]
+	}
+	return nil
+}
+
+func (parser *tagExpressionParser) isOperator(token *tagToken, operator string) bool {
+	return token != nil && token.term == nil && strings.EqualFold(token.text, operator)
+}
+
+func (parser *tagExpressionParser) parseOr() (tagNode, error) {
+	node, err := parser.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	nodes := []tagNode{node}
+	for parser.isOperator(parser.peek(), "OR") {
+		parser.pos++
+		node, err = parser.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return &tagOr{nodes: nodes}, nil
+}
+
+func (parser *tagExpressionParser) parseAnd() (tagNode, error) {
+	node, err := parser.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	nodes := []tagNode{node}
+	for parser.isOperator(parser.peek(), "AND") {
+		parser.pos++
+		node, err = parser.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return &tagAnd{nodes: nodes}, nil
+}
+
+func (parser *tagExpressionParser) parseUnary() (tagNode, error) {
+	token := parser.peek()
+	switch {
+	case token == nil:
+		return nil, parser.errorAt(nil, "unexpected end of expression")
+	case parser.isOperator(token, "NOT"):
+		parser.pos++
+		node, err := parser.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &tagNot{node: node}, nil
+	case token.text == "(":
+		parser.pos++
+		node, err := parser.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		closing := parser.peek()
+		if closing == nil || closing.text != ")" {
+			return nil, parser.errorAt(closing, "missing )")
+		}
+		parser.pos++
+		return node, nil
+	case token.term != nil && token.anyValue:
+		parser.pos++
+		return &tagKeyTerm{key: token.term.Key}, nil
+	case token.term != nil:
+		parser.pos++
+		return &tagTerm{tag: *token.term}, nil
+	}
+	return nil, parser.errorAt(token, "expected key:value, NOT or ( but found "+token.text)
+}
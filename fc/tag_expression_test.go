@@ -0,0 +1,105 @@
+package fullcontact
+
+import (
+	assert "github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestParseTagExpression(t *testing.T) {
+	te, err := ParseTagExpression("segment:vip AND (region:us or region:ca) AND NOT status:churned")
+	assert.NoError(t, err)
+	assert.Equal(t, "segment:vip AND (region:us OR region:ca) AND NOT status:churned", te.String())
+	assert.Equal(t, []*Tag{{Key: "segment", Value: "vip"}, {Key: "region", Value: "us"}, {Key: "region", Value: "ca"}}, te.Tags())
+	assert.True(t, te.NeedsPostFilter())
+
+	assert.True(t, te.Matches([]*Tag{{Key: "segment", Value: "vip"}, {Key: "region", Value: "ca"}}))
+	assert.False(t, te.Matches([]*Tag{{Key: "segment", Value: "vip"}, {Key: "region", Value: "fr"}}))
+	assert.False(t, te.Matches([]*Tag{{Key: "segment", Value: "vip"}, {Key: "region", Value: "us"}, {Key: "status", Value: "churned"}}))
+
+	te, err = ParseTagExpression(`name:"Jane Doe" OR segment:vip OR segment:vip`)
+	assert.NoError(t, err)
+	assert.Equal(t, `name:"Jane Doe" OR segment:vip OR segment:vip`, te.String())
+	assert.Len(t, te.Tags(), 2)
+	assert.False(t, te.NeedsPostFilter())
+
+	te, err = ParseTagExpression("(a:1 OR b:2) AND NOT (c:3 AND NOT d:4)")
+	assert.NoError(t, err)
+	assert.Equal(t, "(a:1 OR b:2) AND NOT (c:3 AND NOT d:4)", te.String())
+	assert.Equal(t, []*Tag{{Key: "a", Value: "1"}, {Key: "b", Value: "2"}, {Key: "d", Value: "4"}}, te.Tags())
+}
+
+func TestTagExpressionAnyValue(t *testing.T) {
+	te, err := ParseTagExpression(`segment:vip AND region:* AND NOT owner:"*"`)
+	assert.NoError(t, err)
+	assert.Equal(t, `segment:vip AND region:* AND NOT owner:"*"`, te.String())
+	assert.Equal(t, []*Tag{{Key: "segment", Value: "vip"}}, te.Tags())
+	assert.True(t, te.NeedsPostFilter())
+
+	assert.True(t, te.Matches([]*Tag{{Key: "segment", Value: "vip"}, {Key: "region", Value: "eu"}, {Key: "owner", Value: "sales"}}))
+	assert.False(t, te.Matches([]*Tag{{Key: "segment", Value: "vip"}}))
+	assert.False(t, te.Matches([]*Tag{{Key: "segment", Value: "vip"}, {Key: "region", Value: "eu"}, {Key: "owner", Value: "*"}}))
+
+	te, err = ParseTagExpression(`region:"*"`)
+	assert.NoError(t, err)
+	assert.Equal(t, []*Tag{{Key: "region", Value: "*"}}, te.Tags())
+	assert.True(t, te.Matches([]*Tag{{Key: "region", Value: "*"}}))
+	assert.False(t, te.Matches([]*Tag{{Key: "region", Value: "eu"}}))
+}
+
+func TestTagExpressionStringRoundTrip(t *testing.T) {
+	values := []string{`say "hi"`, `C:\dir\`, "line\nbreak", "bell\a", "tab\tand space", `\"`, "caf\u00e9"}
+	for _, value := range values {
+		tag := Tag{Key: "note", Value: value}
+		te, err := ParseTagExpression((&tagTerm{tag: tag}).format(0) + " OR segment:vip")
+		assert.NoError(t, err, value)
+		assert.Equal(t, []*Tag{&tag, {Key: "segment", Value: "vip"}}, te.Tags())
+
+		parsed, err := ParseTagExpression(te.String())
+		assert.NoError(t, err, value)
+		assert.Equal(t, te.String(), parsed.String())
+		assert.Equal(t, te.Tags(), parsed.Tags())
+	}
+
+	te, err := ParseTagExpression(`note:"a\"b\\c\d"`)
+	assert.NoError(t, err)
+	assert.Equal(t, []*Tag{{Key: "note", Value: `a"b\c\d`}}, te.Tags())
+	assert.Equal(t, `note:"a\"b\\c\\d"`, te.String())
+}
+
+func TestParseTagExpressionErrors(t *testing.T) {
+	for expression, message := range map[string]string{
+		"":                          "Invalid tag expression at position 0: unexpected end of expression",
+		"segment:vip AND":           "Invalid tag expression at position 15: unexpected end of expression",
+		"(segment:vip OR region:us": "Invalid tag expression at position 25: missing )",
+		"segment:vip region:us":     "Invalid tag expression at position 12: unexpected region:us",
+		"segment AND region:us":     "Invalid tag expression at position 0: expected key:value, NOT or ( but found segment",
+		"segment:":                  "Invalid tag expression at position 0: invalid tag segment:",
+		"seg'ment:vip":              "Invalid tag expression at position 0: invalid tag seg'ment:vip",
+		"name:\"Jane":               "Invalid tag expression at position 5: unterminated quote",
+		"NOT status:churned":        "Tag expression matches records without any of its tags, which the Audience API can't select: NOT status:churned",
+		"a:1 OR NOT b:2":            "Tag expression matches records without any of its tags, which the Audience API can't select: a:1 OR NOT b:2",
+		"a:1 OR b:*":                "Tag expression matches records without any of its tags, which the Audience API can't select: a:1 OR b:*",
+		"a:1 OR (b:* AND NOT c:*)":  "Tag expression matches records without any of its tags, which the Audience API can't select: a:1 OR b:* AND NOT c:*",
+	} {
+		_, err := ParseTagExpression(expression)
+		assert.EqualError(t, err, "FullContactError: "+message, expression)
+	}
+}
+
+func TestTagExpressionForAudience(t *testing.T) {
+	te, _ := ParseTagExpression("segment:vip AND NOT region:us")
+	audienceRequest, err := NewAudienceRequest(WithWebhookUrlForAudience("https://example.com/webhook/audience"),
+		WithTagExpressionForAudience(te))
+	assert.NoError(t, err)
+	assert.Equal(t, []*Tag{{Key: "segment", Value: "vip"}}, audienceRequest.Tags)
+
+	input := `{"recordId":"r1","tags":[{"key":"segment","value":"vip"}]}
+{"recordId":"r2","tags":[{"key":"segment","value":"vip"},{"key":"region","value":"us"}]}
+{"recordId":"r3","tags":[{"key":"segment","value":"vip"},{"key":"region","value":"ca"}]}`
+	reader, _ := NewAudienceReader(strings.NewReader(input), WithAudienceRecordFilter(te.MatchesRecord))
+	records := readAudience(t, reader)
+	assert.Len(t, records, 2)
+	assert.Equal(t, "r1", records[0].RecordId)
+	assert.Equal(t, "r3", records[1].RecordId)
+}
@@ -0,0 +1,221 @@
+package fullcontact
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+)
+
+const tagIndexStoreKey = "tag-index"
+
+type TagIndexOption func(ti *TagIndex)
+
+/*
+TagIndex is a local index of the tags of records, to find the records carrying tags without calling
+the API per record. Registered as a CallObserver with WithCallObserver, it is populated from the
+Tags Get, Tags Create, Tags Delete, Identity Delete and Identity Resolve with tags calls made through
+the client. It only knows the records seen through these calls, or set with SetTags: Tags Create
+only adds tags to records already indexed, as the other tags of a record aren't known otherwise.
+*/
+type TagIndex struct {
+	mutex   sync.RWMutex
+	store   Store
+	records map[string]tagSet
+}
+
+var _ CallObserver = (*TagIndex)(nil)
+
+type tagIndexSnapshot struct {
+	Records map[string][]*Tag `json:"records"`
+}
+
+// NewTagIndex creates a TagIndex, loaded from the Store set with WithTagIndexStore if any.
+func NewTagIndex(options ...TagIndexOption) (*TagIndex, error) {
+	ti := &TagIndex{records: make(map[string]tagSet)}
+	for _, opts := range options {
+		opts(ti)
+	}
+	if ti.store == nil {
+		return ti, nil
+	}
+	value, err := ti.store.Load(tagIndexStoreKey)
+	if err != nil || value == nil {
+		return ti, err
+	}
+	var snapshot tagIndexSnapshot
+	if err = json.Unmarshal(value, &snapshot); err != nil {
+		return nil, err
+	}
+	for recordId, tags := range snapshot.Records {
+		ti.setTags(recordId, tags)
+	}
+	return ti, nil
+}
+
+// WithTagIndexStore persists the index to the Store with Save.
+func WithTagIndexStore(store Store) TagIndexOption {
+	return func(ti *TagIndex) {
+		ti.store = store
+	}
+}
+
+// Save persists the index to its Store.
+func (ti *TagIndex) Save() error {
+	if ti.store == nil {
+		return NewFullContactError("TagIndex has no Store")
+	}
+	ti.mutex.RLock()
+	snapshot := tagIndexSnapshot{Records: make(map[string][]*Tag, len(ti.records))}
+	for recordId := range ti.records {
+		snapshot.Records[recordId] = ti.tagsOf(recordId)
+	}
+	ti.mutex.RUnlock()
+	value, err := json.Marshal(&snapshot)
+	if err != nil {
+		return err
+	}
+	return ti.store.Save(tagIndexStoreKey, value)
+}
+
+func (ti *TagIndex) ObserveCall(call *CallRecord) {
+	resp := call.Response
+	switch call.Endpoint {
+	case endpointName(tagsGetUrl):
+		var request TagsRequest
+		_ = json.Unmarshal(call.Request, &request)
+		if resp.IsMatched() && resp.TagsResponse != nil {
+			recordId := resp.TagsResponse.RecordId
+			if !isPopulated(recordId) {
+				recordId = request.RecordId
+			}
+			tags := make([]*Tag, len(resp.TagsResponse.Tags))
+			for i := range resp.TagsResponse.Tags {
+				tags[i] = &resp.TagsResponse.Tags[i]
+			}
+			ti.SetTags(recordId, tags)
+		} else if resp.IsNoMatch() {
+			ti.Remove(request.RecordId)
+		}
+	case endpointName(tagsCreateUrl), endpointName(tagsDeleteUrl):
+		if !resp.IsMatched() && !resp.IsDeleted() {
+			return
+		}
+		var request TagsRequest
+		if json.Unmarshal(call.Request, &request) != nil || !isPopulated(request.RecordId) {
+			return
+		}
+		ti.mutex.Lock()
+		defer ti.mutex.Unlock()
+		// The other tags of a record not indexed yet are unknown, so it would wrongly match negated terms
+		tags, ok := ti.records[request.RecordId]
+		if !ok {
+			return
+		}
+		if call.Endpoint == endpointName(tagsCreateUrl) {
+			for _, tag := range request.Tags {
+				tags[*tag] = true
+			}
+		} else {
+			for _, tag := range request.Tags {
+				delete(tags, *tag)
+			}
+		}
+	case endpointName(identityResolveUrl):
+		if resp.IsMatched() && resp.ResolveResponseWithTags != nil {
+			for recordId, tags := range resp.ResolveResponseWithTags.Tags {
+				tagPointers := make([]*Tag, len(tags))
+				for i := range tags {
+					tagPointers[i] = &tags[i]
+				}
+				ti.SetTags(recordId, tagPointers)
+			}
+		}
+	case endpointName(identityDeleteUrl):
+		var request ResolveRequest
+		if (resp.IsMatched() || resp.IsDeleted()) && json.Unmarshal(call.Request, &request) == nil {
+			ti.Remove(request.RecordId)
+		}
+	}
+}
+
+// SetTags replaces the tags of the record, e.g. with the tags of an AudienceRecord.
+func (ti *TagIndex) SetTags(recordId string, tags []*Tag) {
+	if !isPopulated(recordId) {
+		return
+	}
+	ti.mutex.Lock()
+	defer ti.mutex.Unlock()
+	ti.setTags(recordId, tags)
+}
+
+func (ti *TagIndex) setTags(recordId string, tags []*Tag) {
+	ti.records[recordId] = newTagSet(tags)
+}
+
+func (ti *TagIndex) Remove(recordId string) {
+	ti.mutex.Lock()
+	defer ti.mutex.Unlock()
+	delete(ti.records, recordId)
+}
+
+// Len returns the number of records in the index.
+func (ti *TagIndex) Len() int {
+	ti.mutex.RLock()
+	defer ti.mutex.RUnlock()
+	return len(ti.records)
+}
+
+// TagsOf returns the tags of the record sorted by key and value, and false if the record isn't indexed.
+func (ti *TagIndex) TagsOf(recordId string) ([]*Tag, bool) {
+	ti.mutex.RLock()
+	defer ti.mutex.RUnlock()
+	if _, ok := ti.records[recordId]; !ok {
+		return nil, false
+	}
+	return ti.tagsOf(recordId), true
+}
+
+func (ti *TagIndex) tagsOf(recordId string) []*Tag {
+	tags := make([]*Tag, 0, len(ti.records[recordId]))
+	for tag := range ti.records[recordId] {
+		tagCopy := tag
+		tags = append(tags, &tagCopy)
+	}
+	sortTags(tags)
+	return tags
+}
+
+// RecordsWithTag returns the sorted recordIds of the records having the tag.
+func (ti *TagIndex) RecordsWithTag(key, value string) []string {
+	return ti.match(&tagTerm{tag: Tag{Key: key, Value: value}})
+}
+
+// RecordsWithKey returns the sorted recordIds of the records having a tag with the key, whatever its value.
+func (ti *TagIndex) RecordsWithKey(key string) []string {
+	return ti.match(&tagKeyTerm{key: key})
+}
+
+/*
+Query returns the sorted recordIds of the records matching a tag expression, see TagExpression,
+e.g. "segment:* AND NOT region:us".
+*/
+func (ti *TagIndex) Query(expression string) ([]string, error) {
+	te, err := parseTagQuery(expression)
+	if err != nil {
+		return nil, err
+	}
+	return ti.match(te.root), nil
+}
+
+func (ti *TagIndex) match(node tagNode) []string {
+	ti.mutex.RLock()
+	defer ti.mutex.RUnlock()
+	recordIds := make([]string, 0)
+	for recordId, tags := range ti.records {
+		if node.eval(tags) {
+			recordIds = append(recordIds, recordId)
+		}
+	}
+	sort.Strings(recordIds)
+	return recordIds
+}
@@ -0,0 +1,96 @@
+package fullcontact
+
+import (
+	assert "github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+func getTagIndexTestClient(t *testing.T, index *TagIndex) *fullContactClient {
+	transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		switch req.URL.String() {
+		case tagsGetUrl:
+			return chaosResponse(req, 200, `{"recordId":"r1","tags":[{"key":"segment","value":"vip"},{"key":"region","value":"us"}]}`), nil
+		case identityResolveWithTagsUrl:
+			return chaosResponse(req, 200, `{"recordIds":["r2","r3"],"tags":{"r2":[{"key":"segment","value":"basic"}],"r3":[{"key":"region","value":"ca"}]}}`), nil
+		case tagsDeleteUrl:
+			return chaosResponse(req, 204, ""), nil
+		}
+		return chaosResponse(req, 200, "{}"), nil
+	})
+	fcClient, err := NewFullContactClient(
+		WithCredentialsProvider(StaticCredentialsProvider{apiKey: "apikey"}),
+		WithHTTPClient(&http.Client{Transport: transport}),
+		WithCallObserver(index))
+	assert.NoError(t, err)
+	return fcClient
+}
+
+func TestTagIndexObservesCalls(t *testing.T) {
+	index, err := NewTagIndex()
+	assert.NoError(t, err)
+	fcClient := getTagIndexTestClient(t, index)
+
+	assert.NoError(t, (<-fcClient.TagsGet("r1")).Err)
+	resolveRequest, _ := NewResolveRequest(WithEmailForResolve("marquitaross006@gmail.com"))
+	assert.NoError(t, (<-fcClient.IdentityResolveWithTags(resolveRequest)).Err)
+	assert.Equal(t, 3, index.Len())
+
+	tagsRequest, _ := NewTagsRequest(WithRecordIdForTags("r3"), WithTag(NewTag(WithTagKey("segment"), WithTagValue("vip"))))
+	assert.NoError(t, (<-fcClient.TagsCreate(tagsRequest)).Err)
+	tagsRequest, _ = NewTagsRequest(WithRecordIdForTags("r1"), WithTag(NewTag(WithTagKey("region"), WithTagValue("us"))))
+	assert.NoError(t, (<-fcClient.TagsDelete(tagsRequest)).Err)
+
+	tags, ok := index.TagsOf("r3")
+	assert.True(t, ok)
+	assert.Equal(t, []*Tag{{Key: "region", Value: "ca"}, {Key: "segment", Value: "vip"}}, tags)
+	assert.Equal(t, []string{"r1", "r3"}, index.RecordsWithTag("segment", "vip"))
+	assert.Equal(t, []string{"r3"}, index.RecordsWithKey("region"))
+	_, ok = index.TagsOf("r4")
+	assert.False(t, ok)
+
+	tagsRequest, _ = NewTagsRequest(WithRecordIdForTags("r5"), WithTag(NewTag(WithTagKey("region"), WithTagValue("us"))))
+	assert.NoError(t, (<-fcClient.TagsCreate(tagsRequest)).Err)
+	_, ok = index.TagsOf("r5")
+	assert.False(t, ok)
+	recordIds, err := index.Query("NOT segment:vip")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"r2"}, recordIds)
+}
+
+func TestTagIndexQuery(t *testing.T) {
+	index, _ := NewTagIndex()
+	index.SetTags("r1", []*Tag{{Key: "segment", Value: "vip"}, {Key: "region", Value: "us"}})
+	index.SetTags("r2", []*Tag{{Key: "segment", Value: "basic"}, {Key: "region", Value: "ca"}})
+	index.SetTags("r3", []*Tag{{Key: "owner", Value: "sales"}})
+	index.SetTags("r4", []*Tag{{Key: "segment", Value: "*"}})
+
+	for expression, expected := range map[string][]string{
+		"segment:vip OR region:ca":                   {"r1", "r2"},
+		"segment:* AND NOT region:us":                {"r2", "r4"},
+		"NOT segment:*":                              {"r3"},
+		`segment:"*"`:                                {"r4"},
+		"(segment:vip AND region:us) OR owner:sales": {"r1", "r3"},
+		"segment:gold":                               {},
+	} {
+		recordIds, err := index.Query(expression)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, recordIds, expression)
+	}
+	_, err := index.Query("segment:vip AND")
+	assert.Error(t, err)
+}
+
+func TestTagIndexPersistence(t *testing.T) {
+	store := NewMemoryStore()
+	index, _ := NewTagIndex(WithTagIndexStore(store))
+	index.SetTags("r1", []*Tag{{Key: "segment", Value: "vip"}})
+	assert.NoError(t, index.Save())
+
+	reloaded, err := NewTagIndex(WithTagIndexStore(store))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"r1"}, reloaded.RecordsWithTag("segment", "vip"))
+
+	index, _ = NewTagIndex()
+	assert.EqualError(t, index.Save(), "FullContactError: TagIndex has no Store")
+}
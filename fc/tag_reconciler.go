@@ -0,0 +1,225 @@
+package fullcontact
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// TagPlan holds the tags to add to and remove from a record to reach its desired tags.
+type TagPlan struct {
+	RecordId string `json:"recordId"`
+	Add      []*Tag `json:"add,omitempty"`
+	Remove   []*Tag `json:"remove,omitempty"`
+}
+
+func (plan *TagPlan) IsEmpty() bool {
+	return len(plan.Add) == 0 && len(plan.Remove) == 0
+}
+
+// String returns the plan as e.g. "r1: +segment:vip -region:us".
+func (plan *TagPlan) String() string {
+	changes := make([]string, 0, len(plan.Add)+len(plan.Remove))
+	for _, tag := range plan.Add {
+		changes = append(changes, "+"+tag.Key+":"+tag.Value)
+	}
+	for _, tag := range plan.Remove {
+		changes = append(changes, "-"+tag.Key+":"+tag.Value)
+	}
+	if len(changes) == 0 {
+		changes = append(changes, "no change")
+	}
+	return plan.RecordId + ": " + strings.Join(changes, " ")
+}
+
+// TagReconcileResult is the result of reconciling the tags of a single record.
+type TagReconcileResult struct {
+	Plan *TagPlan
+	// Applied is true once the plan is applied, it is never applied in dry run.
+	Applied bool
+	Err     error
+}
+
+type TagReconcilerOption func(tr *TagReconciler)
+
+/*
+TagReconciler brings the tags of records in the PIC to a desired set: the current tags are
+fetched with TagsGet, and the missing and extra tags are added with TagsCreate and removed with
+TagsDelete. In dry run, the plans are computed but not applied.
+*/
+type TagReconciler struct {
+	client      Tagger
+	dryRun      bool
+	concurrency int
+	managedKeys map[string]bool
+}
+
+func NewTagReconciler(client Tagger, options ...TagReconcilerOption) (*TagReconciler, error) {
+	if client == nil {
+		return nil, NewFullContactError("Client can't be nil")
+	}
+	tr := &TagReconciler{client: client, concurrency: 4}
+	for _, opts := range options {
+		opts(tr)
+	}
+	if tr.concurrency < 1 {
+		tr.concurrency = 1
+	}
+	return tr, nil
+}
+
+func WithReconcileDryRun() TagReconcilerOption {
+	return func(tr *TagReconciler) {
+		tr.dryRun = true
+	}
+}
+
+// WithReconcileConcurrency sets how many records ReconcileAll reconciles at once, 4 by default.
+func WithReconcileConcurrency(concurrency int) TagReconcilerOption {
+	return func(tr *TagReconciler) {
+		tr.concurrency = concurrency
+	}
+}
+
+// WithManagedTagKeys only removes the tags with these keys, leaving the tags with other keys untouched.
+func WithManagedTagKeys(keys ...string) TagReconcilerOption {
+	return func(tr *TagReconciler) {
+		if tr.managedKeys == nil {
+			tr.managedKeys = make(map[string]bool)
+		}
+		for _, key := range keys {
+			tr.managedKeys[key] = true
+		}
+	}
+}
+
+// Plan fetches the current tags of the record and computes the changes to reach the desired tags.
+func (tr *TagReconciler) Plan(ctx context.Context, recordId string, desired []*Tag, options ...CallOption) (*TagPlan, error) {
+	if !isPopulated(recordId) {
+		return nil, NewFullContactError("RecordId must be present for reconciling Tags")
+	}
+	for _, tag := range desired {
+		if tag == nil || !tag.isValid() {
+			return nil, NewFullContactError("Both Key and Value must be populated for adding a Tag")
+		}
+	}
+
+	resp := <-tr.client.TagsGet(recordId, append([]CallOption{WithCallContext(ctx)}, options...)...)
+	current := make(map[Tag]bool)
+	switch {
+	case resp.IsMatched():
+		if resp.TagsResponse != nil {
+			for _, tag := range resp.TagsResponse.Tags {
+				current[tag] = true
+			}
+		}
+	case resp.IsNoMatch():
+	case resp.Err != nil:
+		return nil, resp.Err
+	default:
+		return nil, NewFullContactError(fmt.Sprintf("Tags get failed for record %s with status %d", recordId, resp.StatusCode))
+	}
+
+	plan := &TagPlan{RecordId: recordId}
+	wanted := make(map[Tag]bool, len(desired))
+	for _, tag := range desired {
+		if !wanted[*tag] && !current[*tag] {
+			tagCopy := *tag
+			plan.Add = append(plan.Add, &tagCopy)
+		}
+		wanted[*tag] = true
+	}
+	for tag := range current {
+		if !wanted[tag] && (tr.managedKeys == nil || tr.managedKeys[tag.Key]) {
+			tagCopy := tag
+			plan.Remove = append(plan.Remove, &tagCopy)
+		}
+	}
+	sortTags(plan.Remove)
+	return plan, nil
+}
+
+// Apply adds and removes the tags of the plan.
+func (tr *TagReconciler) Apply(ctx context.Context, plan *TagPlan, options ...CallOption) error {
+	options = append([]CallOption{WithCallContext(ctx)}, options...)
+	if len(plan.Add) > 0 {
+		tagsRequest, err := NewTagsRequest(WithRecordIdForTags(plan.RecordId), WithTags(plan.Add))
+		if err != nil {
+			return err
+		}
+		if err = tagsCallError(<-tr.client.TagsCreate(tagsRequest, options...), "create", plan.RecordId); err != nil {
+			return err
+		}
+	}
+	if len(plan.Remove) > 0 {
+		tagsRequest := &TagsRequest{RecordId: plan.RecordId, Tags: plan.Remove}
+		if err := tagsCallError(<-tr.client.TagsDelete(tagsRequest, options...), "delete", plan.RecordId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func tagsCallError(resp *APIResponse, operation string, recordId string) error {
+	if resp.Err != nil {
+		return resp.Err
+	}
+	if resp.IsError() {
+		return NewFullContactError(fmt.Sprintf("Tags %s failed for record %s with status %d", operation, recordId, resp.StatusCode))
+	}
+	return nil
+}
+
+// Reconcile plans the changes of a single record and applies them, unless in dry run.
+func (tr *TagReconciler) Reconcile(ctx context.Context, recordId string, desired []*Tag, options ...CallOption) *TagReconcileResult {
+	plan, err := tr.Plan(ctx, recordId, desired, options...)
+	if err != nil {
+		return &TagReconcileResult{Plan: &TagPlan{RecordId: recordId}, Err: err}
+	}
+	result := &TagReconcileResult{Plan: plan}
+	if tr.dryRun || plan.IsEmpty() {
+		return result
+	}
+	result.Err = tr.Apply(ctx, plan, options...)
+	result.Applied = result.Err == nil
+	return result
+}
+
+// ReconcileAll reconciles the desired tags of many records concurrently, returning the results sorted by RecordId.
+func (tr *TagReconciler) ReconcileAll(ctx context.Context, desired map[string][]*Tag, options ...CallOption) []*TagReconcileResult {
+	recordIds := make([]string, 0, len(desired))
+	for recordId := range desired {
+		recordIds = append(recordIds, recordId)
+	}
+	sort.Strings(recordIds)
+
+	results := make([]*TagReconcileResult, len(recordIds))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < tr.concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = tr.Reconcile(ctx, recordIds[i], desired[recordIds[i]], options...)
+			}
+		}()
+	}
+	for i := range recordIds {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return results
+}
+
+func sortTags(tags []*Tag) {
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Key != tags[j].Key {
+			return tags[i].Key < tags[j].Key
+		}
+		return tags[i].Value < tags[j].Value
+	})
+}
@@ -0,0 +1,78 @@
+package fullcontact
+
+import (
+	"context"
+	assert "github.com/stretchr/testify/require"
+	"testing"
+)
+
+func getReconcilerTestClient(current map[string][]Tag) *MockClient {
+	mockClient := NewMockClient()
+	mockClient.On("TagsGet", func(args ...interface{}) *APIResponse {
+		tags, ok := current[args[0].(string)]
+		if !ok {
+			return &APIResponse{StatusCode: 404}
+		}
+		return &APIResponse{StatusCode: 200, TagsResponse: &TagsResponse{RecordId: args[0].(string), Tags: tags}}
+	})
+	mockClient.Respond("TagsCreate", &APIResponse{StatusCode: 200})
+	mockClient.Respond("TagsDelete", &APIResponse{StatusCode: 204})
+	return mockClient
+}
+
+func TestTagReconcilerReconcile(t *testing.T) {
+	mockClient := getReconcilerTestClient(map[string][]Tag{
+		"r1": {{Key: "segment", Value: "vip"}, {Key: "region", Value: "us"}, {Key: "owner", Value: "sales"}},
+	})
+	reconciler, err := NewTagReconciler(mockClient)
+	assert.NoError(t, err)
+
+	result := reconciler.Reconcile(context.Background(), "r1", []*Tag{{Key: "segment", Value: "vip"}, {Key: "region", Value: "ca"}})
+	assert.NoError(t, result.Err)
+	assert.True(t, result.Applied)
+	assert.Equal(t, "r1: +region:ca -owner:sales -region:us", result.Plan.String())
+
+	created := mockClient.CallsTo("TagsCreate")[0].Args[0].(*TagsRequest)
+	assert.Equal(t, &TagsRequest{RecordId: "r1", Tags: []*Tag{{Key: "region", Value: "ca"}}}, created)
+	deleted := mockClient.CallsTo("TagsDelete")[0].Args[0].(*TagsRequest)
+	assert.Equal(t, []*Tag{{Key: "owner", Value: "sales"}, {Key: "region", Value: "us"}}, deleted.Tags)
+}
+
+func TestTagReconcilerManagedKeysAndDryRun(t *testing.T) {
+	mockClient := getReconcilerTestClient(map[string][]Tag{
+		"r1": {{Key: "region", Value: "us"}, {Key: "owner", Value: "sales"}},
+	})
+	reconciler, _ := NewTagReconciler(mockClient, WithManagedTagKeys("region"), WithReconcileDryRun())
+
+	result := reconciler.Reconcile(context.Background(), "r1", []*Tag{{Key: "region", Value: "ca"}})
+	assert.NoError(t, result.Err)
+	assert.False(t, result.Applied)
+	assert.Equal(t, "r1: +region:ca -region:us", result.Plan.String())
+	assert.Empty(t, mockClient.CallsTo("TagsCreate"))
+	assert.Empty(t, mockClient.CallsTo("TagsDelete"))
+}
+
+func TestTagReconcilerReconcileAll(t *testing.T) {
+	mockClient := getReconcilerTestClient(map[string][]Tag{
+		"r1": {{Key: "segment", Value: "vip"}},
+		"r2": {{Key: "segment", Value: "basic"}},
+	})
+	mockClient.On("TagsCreate", func(args ...interface{}) *APIResponse {
+		if args[0].(*TagsRequest).RecordId == "r3" {
+			return &APIResponse{StatusCode: 400}
+		}
+		return &APIResponse{StatusCode: 200}
+	})
+	reconciler, _ := NewTagReconciler(mockClient, WithReconcileConcurrency(2))
+	vip := []*Tag{{Key: "segment", Value: "vip"}}
+
+	results := reconciler.ReconcileAll(context.Background(), map[string][]*Tag{"r3": vip, "r1": vip, "r2": vip, "": vip})
+	assert.Len(t, results, 4)
+	assert.EqualError(t, results[0].Err, "FullContactError: RecordId must be present for reconciling Tags")
+	assert.True(t, results[1].Plan.IsEmpty())
+	assert.False(t, results[1].Applied)
+	assert.Equal(t, "r2: +segment:vip -segment:basic", results[2].Plan.String())
+	assert.True(t, results[2].Applied)
+	assert.EqualError(t, results[3].Err, "FullContactError: Tags create failed for record r3 with status 400")
+	assert.Len(t, mockClient.CallsTo("TagsCreate"), 2)
+}
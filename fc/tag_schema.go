@@ -0,0 +1,206 @@
+package fullcontact
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type TagValueType int
+
+const (
+	TagValueString TagValueType = iota
+	TagValueEnum
+	TagValueInteger
+	TagValueDate
+	TagValueRegex
+)
+
+/*
+TagKeySchema declares a tag key and its values. Values holds the allowed values of TagValueEnum,
+Pattern the regular expression of TagValueRegex and DateLayout the layout of TagValueDate,
+"2006-01-02" by default. MaxValues limits the number of values of the key in a single request, 0
+means no limit: the values a record already has in the PIC aren't known, so repeated requests can
+still add more.
+*/
+type TagKeySchema struct {
+	Key        string
+	Type       TagValueType
+	Values     []string
+	Pattern    string
+	DateLayout string
+	MaxValues  int
+	pattern    *regexp.Regexp
+}
+
+type TagSchemaOption func(ts *TagSchema)
+
+/*
+TagSchema declares the tag keys allowed in the PIC, so that typos are rejected before they are sent,
+when the requests are created WithTagSchemaForTags, WithTagSchemaForResolve and WithTagSchemaForAudience,
+or by a client created WithTagSchema. Keys are either declared one by one with WithTagKeySchema, or by
+namespace with WithTagNamespace, allowing any key starting with the namespace and a dot, e.g.
+"crm.segment" for "crm".
+*/
+type TagSchema struct {
+	keys       map[string]*TagKeySchema
+	namespaces []string
+	err        error
+}
+
+func NewTagSchema(options ...TagSchemaOption) (*TagSchema, error) {
+	ts := &TagSchema{keys: make(map[string]*TagKeySchema)}
+	for _, opts := range options {
+		opts(ts)
+	}
+	if ts.err != nil {
+		return nil, ts.err
+	}
+	return ts, nil
+}
+
+func WithTagKeySchema(keySchema TagKeySchema) TagSchemaOption {
+	return func(ts *TagSchema) {
+		if !isPopulated(keySchema.Key) {
+			ts.err = NewFullContactError("Tag schema key must be present")
+			return
+		}
+		switch keySchema.Type {
+		case TagValueEnum:
+			if len(keySchema.Values) == 0 {
+				ts.err = NewFullContactError("Values must be present for enum tag key: " + keySchema.Key)
+				return
+			}
+		case TagValueRegex:
+			pattern, err := regexp.Compile("^(?:" + keySchema.Pattern + ")$")
+			if err != nil {
+				ts.err = NewFullContactError(fmt.Sprintf("Invalid pattern for tag key %s: %v", keySchema.Key, err))
+				return
+			}
+			keySchema.pattern = pattern
+		case TagValueDate:
+			if !isPopulated(keySchema.DateLayout) {
+				keySchema.DateLayout = "2006-01-02"
+			}
+		}
+		ts.keys[keySchema.Key] = &keySchema
+	}
+}
+
+// WithTagNamespace allows any key of the namespace, e.g. "crm.segment" for "crm", with any value.
+func WithTagNamespace(namespace string) TagSchemaOption {
+	return func(ts *TagSchema) {
+		ts.namespaces = append(ts.namespaces, strings.TrimSuffix(namespace, ".")+".")
+	}
+}
+
+// validateTags validates the tags against the schema, a nil schema allows any tag.
+func (ts *TagSchema) validateTags(tags []*Tag) error {
+	if ts == nil {
+		return nil
+	}
+	return ts.Validate(tags)
+}
+
+// Validate returns an error for the first tag not allowed by the schema.
+func (ts *TagSchema) Validate(tags []*Tag) error {
+	counts := make(map[string]int)
+	for _, tag := range tags {
+		if tag == nil {
+			continue
+		}
+		keySchema, ok := ts.keys[tag.Key]
+		if !ok {
+			if ts.inNamespace(tag.Key) {
+				continue
+			}
+			message := "Tag key " + tag.Key + " is not declared in the tag schema"
+			if suggestion := ts.closestKey(tag.Key); isPopulated(suggestion) {
+				message += ", did you mean " + suggestion + "?"
+			}
+			return NewFullContactError(message)
+		}
+		if err := keySchema.validateValue(tag.Value); err != nil {
+			return err
+		}
+		counts[tag.Key]++
+		if keySchema.MaxValues > 0 && counts[tag.Key] > keySchema.MaxValues {
+			return NewFullContactError(fmt.Sprintf("Tag key %s allows at most %d values", tag.Key, keySchema.MaxValues))
+		}
+	}
+	return nil
+}
+
+func (keySchema *TagKeySchema) validateValue(value string) error {
+	valid := true
+	switch keySchema.Type {
+	case TagValueEnum:
+		valid = false
+		for _, allowed := range keySchema.Values {
+			if value == allowed {
+				valid = true
+				break
+			}
+		}
+	case TagValueInteger:
+		_, err := strconv.ParseInt(value, 10, 64)
+		valid = err == nil
+	case TagValueDate:
+		_, err := time.Parse(keySchema.DateLayout, value)
+		valid = err == nil
+	case TagValueRegex:
+		valid = keySchema.pattern.MatchString(value)
+	}
+	if !valid {
+		return NewFullContactError(fmt.Sprintf("Invalid value %s for tag key %s", value, keySchema.Key))
+	}
+	return nil
+}
+
+func (ts *TagSchema) inNamespace(key string) bool {
+	for _, namespace := range ts.namespaces {
+		if strings.HasPrefix(key, namespace) && len(key) > len(namespace) {
+			return true
+		}
+	}
+	return false
+}
+
+// closestKey returns the declared key closest to the key, if close enough to be a typo.
+func (ts *TagSchema) closestKey(key string) string {
+	keys := make([]string, 0, len(ts.keys))
+	for declared := range ts.keys {
+		keys = append(keys, declared)
+	}
+	sort.Strings(keys)
+	closest, best := "", 3
+	for _, declared := range keys {
+		if distance := editDistance(key, declared); distance < best {
+			closest, best = declared, distance
+		}
+	}
+	return closest
+}
+
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(min(previous[j]+1, current[j-1]+1), previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
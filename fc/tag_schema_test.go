@@ -0,0 +1,138 @@
+package fullcontact
+
+import (
+	"context"
+	assert "github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+func getTestTagSchema(t *testing.T) *TagSchema {
+	schema, err := NewTagSchema(
+		WithTagKeySchema(TagKeySchema{Key: "segment", Type: TagValueEnum, Values: []string{"gold", "silver"}, MaxValues: 1}),
+		WithTagKeySchema(TagKeySchema{Key: "score", Type: TagValueInteger}),
+		WithTagKeySchema(TagKeySchema{Key: "signup", Type: TagValueDate}),
+		WithTagKeySchema(TagKeySchema{Key: "sku", Type: TagValueRegex, Pattern: "[A-Z]{3}-[0-9]+"}),
+		WithTagKeySchema(TagKeySchema{Key: "note"}),
+		WithTagNamespace("crm"))
+	assert.NoError(t, err)
+	return schema
+}
+
+func newTestTag(key, value string) *Tag {
+	return NewTag(WithTagKey(key), WithTagValue(value))
+}
+
+func TestTagSchemaValidTags(t *testing.T) {
+	schema := getTestTagSchema(t)
+	assert.NoError(t, schema.Validate([]*Tag{
+		newTestTag("segment", "gold"),
+		newTestTag("score", "-12"),
+		newTestTag("signup", "2020-10-19"),
+		newTestTag("sku", "ABC-123"),
+		newTestTag("note", "anything"),
+		newTestTag("crm.segment", "vip"),
+	}))
+}
+
+func TestTagSchemaInvalidValues(t *testing.T) {
+	schema := getTestTagSchema(t)
+	assert.EqualError(t, schema.Validate([]*Tag{newTestTag("segment", "bronze")}), "FullContactError: Invalid value bronze for tag key segment")
+	assert.EqualError(t, schema.Validate([]*Tag{newTestTag("score", "1.5")}), "FullContactError: Invalid value 1.5 for tag key score")
+	assert.EqualError(t, schema.Validate([]*Tag{newTestTag("signup", "19/10/2020")}), "FullContactError: Invalid value 19/10/2020 for tag key signup")
+	assert.EqualError(t, schema.Validate([]*Tag{newTestTag("sku", "ABC-123x")}), "FullContactError: Invalid value ABC-123x for tag key sku")
+}
+
+func TestTagSchemaUndeclaredKey(t *testing.T) {
+	schema := getTestTagSchema(t)
+	assert.EqualError(t, schema.Validate([]*Tag{newTestTag("segmnet", "gold")}),
+		"FullContactError: Tag key segmnet is not declared in the tag schema, did you mean segment?")
+	assert.EqualError(t, schema.Validate([]*Tag{newTestTag("region", "eu")}),
+		"FullContactError: Tag key region is not declared in the tag schema")
+	assert.EqualError(t, schema.Validate([]*Tag{newTestTag("crm.", "eu")}),
+		"FullContactError: Tag key crm. is not declared in the tag schema")
+}
+
+func TestTagSchemaCardinality(t *testing.T) {
+	schema := getTestTagSchema(t)
+	assert.EqualError(t, schema.Validate([]*Tag{newTestTag("segment", "gold"), newTestTag("segment", "silver")}),
+		"FullContactError: Tag key segment allows at most 1 values")
+}
+
+func TestNewTagSchemaInvalid(t *testing.T) {
+	_, err := NewTagSchema(WithTagKeySchema(TagKeySchema{Type: TagValueInteger}))
+	assert.EqualError(t, err, "FullContactError: Tag schema key must be present")
+	_, err = NewTagSchema(WithTagKeySchema(TagKeySchema{Key: "segment", Type: TagValueEnum}))
+	assert.EqualError(t, err, "FullContactError: Values must be present for enum tag key: segment")
+	_, err = NewTagSchema(WithTagKeySchema(TagKeySchema{Key: "sku", Type: TagValueRegex, Pattern: "[A-Z"}))
+	assert.Error(t, err)
+}
+
+func getTagSchemaTestClient(t *testing.T, schema *TagSchema) *fullContactClient {
+	transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return chaosResponse(req, 200, "{}"), nil
+	})
+	fcClient, err := NewFullContactClient(
+		WithCredentialsProvider(StaticCredentialsProvider{apiKey: "apikey"}),
+		WithHTTPClient(&http.Client{Transport: transport}),
+		WithTagSchema(schema))
+	assert.NoError(t, err)
+	return fcClient
+}
+
+func TestClientTagSchemaValidatesRequests(t *testing.T) {
+	fcClient := getTagSchemaTestClient(t, getTestTagSchema(t))
+
+	tagsRequest, err := NewTagsRequest(WithRecordIdForTags("r1"), WithTag(newTestTag("segmnet", "gold")))
+	assert.NoError(t, err)
+	resp := <-fcClient.TagsCreate(tagsRequest)
+	assert.EqualError(t, resp.Err, "FullContactError: Tag key segmnet is not declared in the tag schema, did you mean segment?")
+	assert.NoError(t, (<-fcClient.TagsDelete(tagsRequest)).Err)
+	tagsRequest, _ = NewTagsRequest(WithRecordIdForTags("r1"), WithTag(newTestTag("segment", "gold")))
+	assert.NoError(t, (<-fcClient.TagsCreate(tagsRequest)).Err)
+
+	resolveRequest, err := NewResolveRequest(WithEmailForResolve("bart@fullcontact.com"), WithRecordIdForResolve("r1"),
+		WithTagForResolve(newTestTag("score", "high")))
+	assert.NoError(t, err)
+	assert.EqualError(t, (<-fcClient.IdentityMap(resolveRequest)).Err, "FullContactError: Invalid value high for tag key score")
+	assert.EqualError(t, (<-fcClient.IdentityMapResolve(resolveRequest)).Err, "FullContactError: Invalid value high for tag key score")
+
+	audienceRequest, _ := NewAudienceRequest(WithWebhookUrlForAudience("http://localhost/webhook"), WithTagForAudience(newTestTag("crm.segment", "vip")))
+	assert.NoError(t, (<-fcClient.AudienceCreate(audienceRequest)).Err)
+	audienceRequest, _ = NewAudienceRequest(WithWebhookUrlForAudience("http://localhost/webhook"), WithTagForAudience(newTestTag("region", "eu")))
+	assert.EqualError(t, (<-fcClient.AudienceCreate(audienceRequest)).Err, "FullContactError: Tag key region is not declared in the tag schema")
+
+	tagsRequest, _ = NewTagsRequest(WithRecordIdForTags("r1"), WithTag(newTestTag("segmnet", "gold")))
+	assert.NoError(t, (<-getTagSchemaTestClient(t, nil).TagsCreate(tagsRequest)).Err)
+}
+
+func TestTagReconcilerRemovesUndeclaredTags(t *testing.T) {
+	fcClient := getTagSchemaTestClient(t, getTestTagSchema(t))
+	reconciler, err := NewTagReconciler(fcClient)
+	assert.NoError(t, err)
+	err = reconciler.Apply(context.Background(), &TagPlan{RecordId: "r1", Remove: []*Tag{newTestTag("segmnet", "gold")}})
+	assert.NoError(t, err)
+}
+
+func TestRequestConstructorsValidateTagSchema(t *testing.T) {
+	schema := getTestTagSchema(t)
+
+	_, err := NewTagsRequest(WithTagSchemaForTags(schema), WithRecordIdForTags("r1"), WithTag(newTestTag("segmnet", "gold")))
+	assert.EqualError(t, err, "FullContactError: Tag key segmnet is not declared in the tag schema, did you mean segment?")
+	tagsRequest, err := NewTagsRequest(WithRecordIdForTags("r1"), WithTag(newTestTag("segment", "gold")), WithTagSchemaForTags(schema))
+	assert.NoError(t, err)
+	assert.Equal(t, "r1", tagsRequest.RecordId)
+
+	_, err = NewResolveRequest(WithTagSchemaForResolve(schema), WithTagForResolve(newTestTag("score", "high")))
+	assert.EqualError(t, err, "FullContactError: Invalid value high for tag key score")
+	_, err = NewResolveRequest(WithTagSchemaForResolve(schema), WithTagForResolve(newTestTag("score", "12")))
+	assert.NoError(t, err)
+
+	_, err = NewAudienceRequest(WithTagSchemaForAudience(schema), WithWebhookUrlForAudience("https://example.com/audience"),
+		WithTagForAudience(newTestTag("segment", "gold")), WithTagForAudience(newTestTag("segment", "silver")))
+	assert.EqualError(t, err, "FullContactError: Tag key segment allows at most 1 values")
+
+	// Without a schema, any tag is allowed
+	_, err = NewTagsRequest(WithRecordIdForTags("r1"), WithTag(newTestTag("segmnet", "gold")))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,364 @@
+package fullcontact
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+type tenantContextKey struct{}
+
+// WithTenant returns a context carrying the tenant id, to be passed to a TenantClient with WithCallContext.
+func WithTenant(ctx context.Context, tenantId string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenantId)
+}
+
+func TenantFromContext(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	tenantId, ok := ctx.Value(tenantContextKey{}).(string)
+	return tenantId, ok && isPopulated(tenantId)
+}
+
+/*
+Tenant holds the configuration of a single tenant of a TenantClient.
+
+RequestsPerSecond and Burst limit the request rate of the tenant, 0 means unlimited.
+MaxCalls and MaxMatchedCalls cap the number of calls and of billable (matched) calls
+made for the tenant, 0 means no cap. A call in flight holds one of the MaxMatchedCalls
+until its response, so concurrent calls can't exceed the cap.
+*/
+type Tenant struct {
+	Id                  string
+	CredentialsProvider CredentialsProvider
+	Headers             map[string]string
+	RequestsPerSecond   float64
+	Burst               int
+	MaxCalls            int
+	MaxMatchedCalls     int
+}
+
+// TenantUsage is the usage accounted for a tenant.
+type TenantUsage struct {
+	Calls        int `json:"calls"`
+	MatchedCalls int `json:"matchedCalls"`
+	FailedCalls  int `json:"failedCalls"`
+	Rejected     int `json:"rejected"`
+}
+
+type tenantState struct {
+	tenant  *Tenant
+	limiter *rateLimiter
+	usage   TenantUsage
+	// pendingMatched counts the calls in flight that may be matched
+	pendingMatched int
+}
+
+/*
+TenantClient serves several tenants with a single Client. The tenant of every request is taken
+from the context set with WithCallContext(WithTenant(ctx, tenantId)), and selects the credentials,
+headers, rate limit and caps used for the request. Usage is accounted per tenant. The credentials
+and headers of the tenant take precedence over the CallOptions of the request.
+*/
+type TenantClient struct {
+	client  Client
+	mutex   sync.Mutex
+	tenants map[string]*tenantState
+}
+
+var _ Client = (*TenantClient)(nil)
+
+func NewTenantClient(client Client, tenants ...*Tenant) (*TenantClient, error) {
+	if client == nil {
+		return nil, NewFullContactError("Client can't be nil")
+	}
+	tc := &TenantClient{
+		client:  client,
+		tenants: make(map[string]*tenantState),
+	}
+	for _, tenant := range tenants {
+		err := tc.AddTenant(tenant)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tc, nil
+}
+
+// AddTenant adds a tenant or replaces the configuration of an existing one, keeping its usage.
+func (tc *TenantClient) AddTenant(tenant *Tenant) error {
+	if tenant == nil || !isPopulated(tenant.Id) {
+		return NewFullContactError("Tenant Id must be present")
+	}
+	if tenant.CredentialsProvider == nil {
+		return NewFullContactError("CredentialsProvider must be present for tenant: " + tenant.Id)
+	}
+	tc.mutex.Lock()
+	defer tc.mutex.Unlock()
+	state := &tenantState{tenant: tenant, limiter: newRateLimiter(tenant.RequestsPerSecond, tenant.Burst)}
+	if existing, ok := tc.tenants[tenant.Id]; ok {
+		state.usage = existing.usage
+		state.pendingMatched = existing.pendingMatched
+	}
+	tc.tenants[tenant.Id] = state
+	return nil
+}
+
+func (tc *TenantClient) RemoveTenant(tenantId string) {
+	tc.mutex.Lock()
+	defer tc.mutex.Unlock()
+	delete(tc.tenants, tenantId)
+}
+
+// Usage returns the usage of the tenant and false if the tenant is unknown.
+func (tc *TenantClient) Usage(tenantId string) (TenantUsage, bool) {
+	tc.mutex.Lock()
+	defer tc.mutex.Unlock()
+	state, ok := tc.tenants[tenantId]
+	if !ok {
+		return TenantUsage{}, false
+	}
+	return state.usage, true
+}
+
+// ResetUsage clears the usage of the tenant, e.g. at the start of a new billing period.
+func (tc *TenantClient) ResetUsage(tenantId string) {
+	tc.mutex.Lock()
+	defer tc.mutex.Unlock()
+	if state, ok := tc.tenants[tenantId]; ok {
+		state.usage = TenantUsage{}
+	}
+}
+
+// reserve checks the caps of the tenant and accounts for a new call, holding a matched call slot until it's recorded.
+func (tc *TenantClient) reserve(ctx context.Context) (*tenantState, error) {
+	tenantId, ok := TenantFromContext(ctx)
+	if !ok {
+		return nil, NewFullContactError("Tenant must be present in the request context")
+	}
+	tc.mutex.Lock()
+	defer tc.mutex.Unlock()
+	state, ok := tc.tenants[tenantId]
+	if !ok {
+		return nil, NewFullContactError("Unknown tenant: " + tenantId)
+	}
+	tenant := state.tenant
+	if (tenant.MaxCalls > 0 && state.usage.Calls >= tenant.MaxCalls) ||
+		(tenant.MaxMatchedCalls > 0 && state.usage.MatchedCalls+state.pendingMatched >= tenant.MaxMatchedCalls) {
+		state.usage.Rejected++
+		return nil, NewFullContactError("Usage cap reached for tenant: " + tenantId)
+	}
+	state.usage.Calls++
+	state.pendingMatched++
+	return state, nil
+}
+
+/*
+record accounts for the response of a reserved call and releases its matched call slot, in the
+current state of the tenant as AddTenant may have replaced it since.
+*/
+func (tc *TenantClient) record(tenantId string, resp *APIResponse) {
+	tc.mutex.Lock()
+	defer tc.mutex.Unlock()
+	state, ok := tc.tenants[tenantId]
+	if !ok {
+		return
+	}
+	// the tenant may have been removed and added again since the call was reserved
+	if state.pendingMatched > 0 {
+		state.pendingMatched--
+	}
+	if resp.IsError() {
+		state.usage.FailedCalls++
+	} else if resp.IsMatched() {
+		state.usage.MatchedCalls++
+	}
+}
+
+// release cancels the reservation of a call which wasn't sent, e.g. cancelled while rate limited.
+func (tc *TenantClient) release(tenantId string) {
+	tc.mutex.Lock()
+	defer tc.mutex.Unlock()
+	state, ok := tc.tenants[tenantId]
+	if !ok {
+		return
+	}
+	if state.pendingMatched > 0 {
+		state.pendingMatched--
+	}
+	if state.usage.Calls > 0 {
+		state.usage.Calls--
+	}
+}
+
+func (tc *TenantClient) call(options []CallOption, method func(options ...CallOption) chan *APIResponse) chan *APIResponse {
+	ch := make(chan *APIResponse)
+	ctx := contextFromCallOptions(options)
+	state, err := tc.reserve(ctx)
+	if err != nil {
+		go sendToChannel(ch, nil, "", err)
+		return ch
+	}
+
+	tenantOptions := []CallOption{
+		WithCallCredentialsProvider(state.tenant.CredentialsProvider),
+		WithCallHeaders(state.tenant.Headers),
+	}
+	go func() {
+		err := state.limiter.wait(ctx)
+		if err != nil {
+			tc.release(state.tenant.Id)
+			ch <- &APIResponse{Err: err}
+			return
+		}
+		// the tenant options are applied last, so that a request can't override the tenant credentials
+		resp := <-method(append(append([]CallOption{}, options...), tenantOptions...)...)
+		tc.record(state.tenant.Id, resp)
+		ch <- resp
+	}()
+	return ch
+}
+
+func contextFromCallOptions(options []CallOption) context.Context {
+	co := &callOptions{headers: make(map[string]string)}
+	for _, opts := range options {
+		opts(co)
+	}
+	if co.ctx == nil {
+		return context.Background()
+	}
+	return co.ctx
+}
+
+func (tc *TenantClient) PersonEnrich(personRequest *PersonRequest, options ...CallOption) chan *APIResponse {
+	return tc.call(options, func(options ...CallOption) chan *APIResponse {
+		return tc.client.PersonEnrich(personRequest, options...)
+	})
+}
+
+func (tc *TenantClient) CompanyEnrich(companyRequest *CompanyRequest, options ...CallOption) chan *APIResponse {
+	return tc.call(options, func(options ...CallOption) chan *APIResponse {
+		return tc.client.CompanyEnrich(companyRequest, options...)
+	})
+}
+
+func (tc *TenantClient) IdentityMap(resolveRequest *ResolveRequest, options ...CallOption) chan *APIResponse {
+	return tc.call(options, func(options ...CallOption) chan *APIResponse {
+		return tc.client.IdentityMap(resolveRequest, options...)
+	})
+}
+
+func (tc *TenantClient) IdentityResolve(resolveRequest *ResolveRequest, options ...CallOption) chan *APIResponse {
+	return tc.call(options, func(options ...CallOption) chan *APIResponse {
+		return tc.client.IdentityResolve(resolveRequest, options...)
+	})
+}
+
+func (tc *TenantClient) IdentityMapResolve(resolveRequest *ResolveRequest, options ...CallOption) chan *APIResponse {
+	return tc.call(options, func(options ...CallOption) chan *APIResponse {
+		return tc.client.IdentityMapResolve(resolveRequest, options...)
+	})
+}
+
+func (tc *TenantClient) IdentityResolveWithTags(resolveRequest *ResolveRequest, options ...CallOption) chan *APIResponse {
+	return tc.call(options, func(options ...CallOption) chan *APIResponse {
+		return tc.client.IdentityResolveWithTags(resolveRequest, options...)
+	})
+}
+
+func (tc *TenantClient) IdentityDelete(resolveRequest *ResolveRequest, options ...CallOption) chan *APIResponse {
+	return tc.call(options, func(options ...CallOption) chan *APIResponse {
+		return tc.client.IdentityDelete(resolveRequest, options...)
+	})
+}
+
+func (tc *TenantClient) TagsCreate(tagsRequest *TagsRequest, options ...CallOption) chan *APIResponse {
+	return tc.call(options, func(options ...CallOption) chan *APIResponse {
+		return tc.client.TagsCreate(tagsRequest, options...)
+	})
+}
+
+func (tc *TenantClient) TagsGet(recordId string, options ...CallOption) chan *APIResponse {
+	return tc.call(options, func(options ...CallOption) chan *APIResponse {
+		return tc.client.TagsGet(recordId, options...)
+	})
+}
+
+func (tc *TenantClient) TagsDelete(tagsRequest *TagsRequest, options ...CallOption) chan *APIResponse {
+	return tc.call(options, func(options ...CallOption) chan *APIResponse {
+		return tc.client.TagsDelete(tagsRequest, options...)
+	})
+}
+
+func (tc *TenantClient) AudienceCreate(audienceRequest *AudienceRequest, options ...CallOption) chan *APIResponse {
+	return tc.call(options, func(options ...CallOption) chan *APIResponse {
+		return tc.client.AudienceCreate(audienceRequest, options...)
+	})
+}
+
+func (tc *TenantClient) AudienceDownload(requestId string, options ...CallOption) chan *APIResponse {
+	return tc.call(options, func(options ...CallOption) chan *APIResponse {
+		return tc.client.AudienceDownload(requestId, options...)
+	})
+}
+
+func (tc *TenantClient) AudienceDownloadTo(requestId string, writer io.Writer, options ...CallOption) chan *APIResponse {
+	return tc.call(options, func(options ...CallOption) chan *APIResponse {
+		return tc.client.AudienceDownloadTo(requestId, writer, options...)
+	})
+}
+
+func (tc *TenantClient) AudienceDownloadToFile(requestId string, fileName string, options ...CallOption) chan *APIResponse {
+	return tc.call(options, func(options ...CallOption) chan *APIResponse {
+		return tc.client.AudienceDownloadToFile(requestId, fileName, options...)
+	})
+}
+
+func (tc *TenantClient) PermissionCreate(permissionRequest *PermissionRequest, options ...CallOption) chan *APIResponse {
+	return tc.call(options, func(options ...CallOption) chan *APIResponse {
+		return tc.client.PermissionCreate(permissionRequest, options...)
+	})
+}
+
+func (tc *TenantClient) PermissionDelete(multifieldRequest *MultifieldRequest, options ...CallOption) chan *APIResponse {
+	return tc.call(options, func(options ...CallOption) chan *APIResponse {
+		return tc.client.PermissionDelete(multifieldRequest, options...)
+	})
+}
+
+func (tc *TenantClient) PermissionFind(multifieldRequest *MultifieldRequest, options ...CallOption) chan *APIResponse {
+	return tc.call(options, func(options ...CallOption) chan *APIResponse {
+		return tc.client.PermissionFind(multifieldRequest, options...)
+	})
+}
+
+func (tc *TenantClient) PermissionCurrent(multifieldRequest *MultifieldRequest, options ...CallOption) chan *APIResponse {
+	return tc.call(options, func(options ...CallOption) chan *APIResponse {
+		return tc.client.PermissionCurrent(multifieldRequest, options...)
+	})
+}
+
+func (tc *TenantClient) PermissionVerify(permissionRequest *PermissionRequest, options ...CallOption) chan *APIResponse {
+	return tc.call(options, func(options ...CallOption) chan *APIResponse {
+		return tc.client.PermissionVerify(permissionRequest, options...)
+	})
+}
+
+func (tc *TenantClient) VerifySignals(multifieldRequest *MultifieldRequest, options ...CallOption) chan *APIResponse {
+	return tc.call(options, func(options ...CallOption) chan *APIResponse {
+		return tc.client.VerifySignals(multifieldRequest, options...)
+	})
+}
+
+func (tc *TenantClient) VerifyMatch(multifieldRequest *MultifieldRequest, options ...CallOption) chan *APIResponse {
+	return tc.call(options, func(options ...CallOption) chan *APIResponse {
+		return tc.client.VerifyMatch(multifieldRequest, options...)
+	})
+}
+
+func (tc *TenantClient) VerifyActivity(multifieldRequest *MultifieldRequest, options ...CallOption) chan *APIResponse {
+	return tc.call(options, func(options ...CallOption) chan *APIResponse {
+		return tc.client.VerifyActivity(multifieldRequest, options...)
+	})
+}
@@ -0,0 +1,183 @@
+package fullcontact
+
+import (
+	"context"
+	assert "github.com/stretchr/testify/require"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func getTenantTestClient(t *testing.T, tenants ...*Tenant) (*TenantClient, *[]*http.Request) {
+	var mutex sync.Mutex
+	requests := make([]*http.Request, 0)
+	transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		requests = append(requests, req)
+		if req.URL.String() == tagsGetUrl {
+			return chaosResponse(req, 404, ""), nil
+		}
+		return chaosResponse(req, 200, "{\"recordId\":\"r1\"}"), nil
+	})
+	fcClient, err := NewFullContactClient(
+		WithCredentialsProvider(StaticCredentialsProvider{apiKey: "default"}),
+		WithHTTPClient(&http.Client{Transport: transport}))
+	assert.NoError(t, err)
+	tc, err := NewTenantClient(fcClient, tenants...)
+	assert.NoError(t, err)
+	return tc, &requests
+}
+
+func TestTenantClientSelectsTenantFromContext(t *testing.T) {
+	tc, requests := getTenantTestClient(t,
+		&Tenant{Id: "sales", CredentialsProvider: StaticCredentialsProvider{apiKey: "sales-key"},
+			Headers: map[string]string{"Reporting-Key": "sales"}},
+		&Tenant{Id: "support", CredentialsProvider: StaticCredentialsProvider{apiKey: "support-key"}})
+
+	tagsRequest, _ := NewTagsRequest(WithRecordIdForTags("r1"), WithTag(NewTag(WithTagKey("k"), WithTagValue("v"))))
+	resp := <-tc.TagsCreate(tagsRequest, WithCallContext(WithTenant(context.Background(), "sales")))
+	assert.NoError(t, resp.Err)
+	resp = <-tc.TagsGet("r1", WithCallContext(WithTenant(context.Background(), "support")))
+	assert.NoError(t, resp.Err)
+
+	assert.Equal(t, "Bearer sales-key", (*requests)[0].Header.Get("Authorization"))
+	assert.Equal(t, "sales", (*requests)[0].Header.Get("Reporting-Key"))
+	assert.Equal(t, "Bearer support-key", (*requests)[1].Header.Get("Authorization"))
+
+	usage, ok := tc.Usage("sales")
+	assert.True(t, ok)
+	assert.Equal(t, TenantUsage{Calls: 1, MatchedCalls: 1}, usage)
+	usage, _ = tc.Usage("support")
+	assert.Equal(t, TenantUsage{Calls: 1}, usage)
+}
+
+func TestTenantClientWithoutTenant(t *testing.T) {
+	tc, _ := getTenantTestClient(t, &Tenant{Id: "sales", CredentialsProvider: StaticCredentialsProvider{apiKey: "k"}})
+
+	resp := <-tc.TagsGet("r1")
+	assert.EqualError(t, resp.Err, "FullContactError: Tenant must be present in the request context")
+	resp = <-tc.TagsGet("r1", WithCallContext(WithTenant(context.Background(), "marketing")))
+	assert.EqualError(t, resp.Err, "FullContactError: Unknown tenant: marketing")
+}
+
+func TestTenantClientCaps(t *testing.T) {
+	tc, _ := getTenantTestClient(t,
+		&Tenant{Id: "sales", CredentialsProvider: StaticCredentialsProvider{apiKey: "k"}, MaxMatchedCalls: 2})
+	ctx := WithTenant(context.Background(), "sales")
+	tagsRequest, _ := NewTagsRequest(WithRecordIdForTags("r1"), WithTag(NewTag(WithTagKey("k"), WithTagValue("v"))))
+
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, (<-tc.TagsCreate(tagsRequest, WithCallContext(ctx))).Err)
+	}
+	resp := <-tc.TagsCreate(tagsRequest, WithCallContext(ctx))
+	assert.EqualError(t, resp.Err, "FullContactError: Usage cap reached for tenant: sales")
+
+	usage, _ := tc.Usage("sales")
+	assert.Equal(t, TenantUsage{Calls: 2, MatchedCalls: 2, Rejected: 1}, usage)
+
+	tc.ResetUsage("sales")
+	assert.NoError(t, (<-tc.TagsCreate(tagsRequest, WithCallContext(ctx))).Err)
+}
+
+func TestTenantClientRateLimit(t *testing.T) {
+	tc, _ := getTenantTestClient(t,
+		&Tenant{Id: "sales", CredentialsProvider: StaticCredentialsProvider{apiKey: "k"}, RequestsPerSecond: 20, Burst: 1})
+	ctx := WithTenant(context.Background(), "sales")
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, (<-tc.TagsGet("r1", WithCallContext(ctx))).Err)
+	}
+	assert.True(t, time.Since(start) >= 90*time.Millisecond)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	resp := <-tc.TagsGet("r1", WithCallContext(cancelled))
+	assert.Equal(t, context.Canceled, resp.Err)
+	// the call cancelled by the rate limiter isn't accounted
+	usage, _ := tc.Usage("sales")
+	assert.Equal(t, TenantUsage{Calls: 3}, usage)
+}
+
+func TestTenantClientRemovedWithCallsInFlight(t *testing.T) {
+	release := make(chan bool)
+	transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		<-release
+		return chaosResponse(req, 200, "{\"recordId\":\"r1\"}"), nil
+	})
+	fcClient, _ := NewFullContactClient(
+		WithCredentialsProvider(StaticCredentialsProvider{apiKey: "default"}),
+		WithHTTPClient(&http.Client{Transport: transport}))
+	sales := &Tenant{Id: "sales", CredentialsProvider: StaticCredentialsProvider{apiKey: "k"}, MaxMatchedCalls: 1}
+	tc, _ := NewTenantClient(fcClient, sales)
+	ctx := WithTenant(context.Background(), "sales")
+	tagsRequest, _ := NewTagsRequest(WithRecordIdForTags("r1"), WithTag(NewTag(WithTagKey("k"), WithTagValue("v"))))
+
+	inFlight := tc.TagsCreate(tagsRequest, WithCallContext(ctx))
+	tc.RemoveTenant("sales")
+	assert.NoError(t, tc.AddTenant(sales))
+	release <- true
+	assert.NoError(t, (<-inFlight).Err)
+
+	// the call in flight is accounted to the tenant added again, without releasing a slot it doesn't hold
+	resp := <-tc.TagsCreate(tagsRequest, WithCallContext(ctx))
+	assert.EqualError(t, resp.Err, "FullContactError: Usage cap reached for tenant: sales")
+}
+
+func TestNewTenantClientInvalidTenant(t *testing.T) {
+	_, err := NewTenantClient(NewMockClient(), &Tenant{Id: "sales"})
+	assert.EqualError(t, err, "FullContactError: CredentialsProvider must be present for tenant: sales")
+	_, err = NewTenantClient(NewMockClient(), &Tenant{})
+	assert.EqualError(t, err, "FullContactError: Tenant Id must be present")
+}
+
+func TestTenantClientCapsConcurrentCalls(t *testing.T) {
+	release := make(chan bool)
+	transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		<-release
+		if req.URL.String() == tagsGetUrl {
+			return chaosResponse(req, 404, ""), nil
+		}
+		return chaosResponse(req, 200, "{\"recordId\":\"r1\"}"), nil
+	})
+	fcClient, _ := NewFullContactClient(
+		WithCredentialsProvider(StaticCredentialsProvider{apiKey: "default"}),
+		WithHTTPClient(&http.Client{Transport: transport}))
+	tc, _ := NewTenantClient(fcClient,
+		&Tenant{Id: "sales", CredentialsProvider: StaticCredentialsProvider{apiKey: "k"}, MaxMatchedCalls: 2})
+	ctx := WithTenant(context.Background(), "sales")
+	tagsRequest, _ := NewTagsRequest(WithRecordIdForTags("r1"), WithTag(NewTag(WithTagKey("k"), WithTagValue("v"))))
+
+	first := tc.TagsCreate(tagsRequest, WithCallContext(ctx))
+	second := tc.TagsGet("r1", WithCallContext(ctx))
+	resp := <-tc.TagsCreate(tagsRequest, WithCallContext(ctx))
+	assert.EqualError(t, resp.Err, "FullContactError: Usage cap reached for tenant: sales")
+
+	release <- true
+	release <- true
+	assert.NoError(t, (<-first).Err)
+	assert.NoError(t, (<-second).Err)
+
+	// the no match released its slot
+	third := tc.TagsCreate(tagsRequest, WithCallContext(ctx))
+	release <- true
+	assert.NoError(t, (<-third).Err)
+	usage, _ := tc.Usage("sales")
+	assert.Equal(t, TenantUsage{Calls: 3, MatchedCalls: 2, Rejected: 1}, usage)
+}
+
+func TestTenantClientCredentialsCantBeOverridden(t *testing.T) {
+	tc, requests := getTenantTestClient(t,
+		&Tenant{Id: "sales", CredentialsProvider: StaticCredentialsProvider{apiKey: "sales-key"},
+			Headers: map[string]string{"Reporting-Key": "sales"}})
+
+	resp := <-tc.TagsGet("r1", WithCallContext(WithTenant(context.Background(), "sales")),
+		WithCallCredentialsProvider(StaticCredentialsProvider{apiKey: "other-key"}),
+		WithCallHeaders(map[string]string{"Reporting-Key": "other", "Request-Key": "r"}))
+	assert.NoError(t, resp.Err)
+	assert.Equal(t, "Bearer sales-key", (*requests)[0].Header.Get("Authorization"))
+	assert.Equal(t, "sales", (*requests)[0].Header.Get("Reporting-Key"))
+	assert.Equal(t, "r", (*requests)[0].Header.Get("Request-Key"))
+}
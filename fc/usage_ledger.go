@@ -0,0 +1,284 @@
+package fullcontact
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+type UsageWindow int
+
+const (
+	UsageDaily UsageWindow = iota
+	UsageMonthly
+)
+
+// EndpointUsage counts the calls made to a single endpoint.
+type EndpointUsage struct {
+	Calls    int `json:"calls"`
+	Matched  int `json:"matched"`
+	NoMatch  int `json:"noMatch"`
+	Accepted int `json:"accepted"`
+	Failed   int `json:"failed"`
+	Rejected int `json:"rejected"`
+	// DataFilters counts the matched calls per requested data pack.
+	DataFilters map[string]int `json:"dataFilters,omitempty"`
+}
+
+// UsageSnapshot is the usage of a single time window, e.g. "2020-10-19" or "2020-10".
+type UsageSnapshot struct {
+	Window    string                    `json:"window"`
+	Start     time.Time                 `json:"start"`
+	End       time.Time                 `json:"end"`
+	Endpoints map[string]*EndpointUsage `json:"endpoints"`
+}
+
+// Total returns the usage of all endpoints of the snapshot.
+func (snapshot *UsageSnapshot) Total() *EndpointUsage {
+	total := &EndpointUsage{}
+	for _, usage := range snapshot.Endpoints {
+		total.Calls += usage.Calls
+		total.Matched += usage.Matched
+		total.NoMatch += usage.NoMatch
+		total.Accepted += usage.Accepted
+		total.Failed += usage.Failed
+		total.Rejected += usage.Rejected
+	}
+	return total
+}
+
+// BudgetCap limits the calls per time window to an endpoint, or to all endpoints if Endpoint is empty.
+type BudgetCap struct {
+	Endpoint   string
+	MaxCalls   int
+	MaxMatched int
+}
+
+// BudgetExceededError is returned for requests made after a budget cap of the UsageLedger is reached.
+type BudgetExceededError struct {
+	Endpoint string
+	Window   string
+	Cap      BudgetCap
+}
+
+func (err *BudgetExceededError) Error() string {
+	endpoint := err.Cap.Endpoint
+	if !isPopulated(endpoint) {
+		endpoint = "all endpoints"
+	}
+	return fmt.Sprintf("FullContactError: Budget exceeded for %s in window %s", endpoint, err.Window)
+}
+
+func IsBudgetExceeded(err error) bool {
+	var budgetErr *BudgetExceededError
+	return errors.As(err, &budgetErr)
+}
+
+type UsageLedgerOption func(ul *UsageLedger)
+
+/*
+UsageLedger accounts the calls made through a client per endpoint and time window: calls, matched
+(200), no match (404), accepted for webhook delivery (202) and failed calls, and the data packs
+(DataFilter) of matched Person Enrich calls. Budget caps make further calls of the window fail
+fast with a BudgetExceededError, without calling the API.
+
+Caps are checked before a call is sent and usage is recorded once it completes. A call in flight
+holds a slot of the caps until then, so concurrent calls can't exceed a cap.
+*/
+type UsageLedger struct {
+	mutex   sync.Mutex
+	window  UsageWindow
+	caps    []BudgetCap
+	windows map[string]*UsageSnapshot
+	// pending counts the calls in flight per endpoint, allowed but not observed yet
+	pending  map[string]int
+	location *time.Location
+	now      func() time.Time
+}
+
+var _ CallObserver = (*UsageLedger)(nil)
+
+func NewUsageLedger(options ...UsageLedgerOption) *UsageLedger {
+	ul := &UsageLedger{
+		window:   UsageMonthly,
+		windows:  make(map[string]*UsageSnapshot),
+		pending:  make(map[string]int),
+		location: time.UTC,
+		now:      time.Now,
+	}
+	for _, opts := range options {
+		opts(ul)
+	}
+	return ul
+}
+
+func WithUsageWindow(window UsageWindow) UsageLedgerOption {
+	return func(ul *UsageLedger) {
+		ul.window = window
+	}
+}
+
+// WithUsageLocation sets the time zone in which the time windows start, UTC by default.
+func WithUsageLocation(location *time.Location) UsageLedgerOption {
+	return func(ul *UsageLedger) {
+		ul.location = location
+	}
+}
+
+func WithBudgetCap(budgetCap BudgetCap) UsageLedgerOption {
+	return func(ul *UsageLedger) {
+		ul.caps = append(ul.caps, budgetCap)
+	}
+}
+
+// Snapshot returns a copy of the usage of the current time window.
+func (ul *UsageLedger) Snapshot() UsageSnapshot {
+	ul.mutex.Lock()
+	defer ul.mutex.Unlock()
+	return ul.current().copy()
+}
+
+// Snapshots returns a copy of the usage of every time window, oldest first.
+func (ul *UsageLedger) Snapshots() []UsageSnapshot {
+	ul.mutex.Lock()
+	defer ul.mutex.Unlock()
+	snapshots := make([]UsageSnapshot, 0, len(ul.windows))
+	for _, snapshot := range ul.windows {
+		snapshots = append(snapshots, snapshot.copy())
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Start.Before(snapshots[j].Start)
+	})
+	return snapshots
+}
+
+// WriteJSON writes the usage of every time window as a JSON array.
+func (ul *UsageLedger) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(ul.Snapshots())
+}
+
+func (ul *UsageLedger) ObserveCall(call *CallRecord) {
+	ul.mutex.Lock()
+	defer ul.mutex.Unlock()
+	if isPopulated(call.reservedEndpoint) && ul.pending[call.reservedEndpoint] > 0 {
+		ul.pending[call.reservedEndpoint]--
+	}
+	usage := ul.current().endpoint(call.Endpoint)
+	resp := call.Response
+
+	if IsBudgetExceeded(resp.Err) {
+		usage.Rejected++
+		return
+	}
+	usage.Calls++
+	switch resp.GetOutcome() {
+	case OutcomeMatched:
+		usage.Matched++
+		if call.Endpoint == endpointName(personEnrichUrl) {
+			var personRequest PersonRequest
+			if json.Unmarshal(call.Request, &personRequest) == nil {
+				for _, dataFilter := range personRequest.DataFilter {
+					if usage.DataFilters == nil {
+						usage.DataFilters = make(map[string]int)
+					}
+					usage.DataFilters[dataFilter]++
+				}
+			}
+		}
+	case OutcomeNoMatch:
+		usage.NoMatch++
+	case OutcomeAccepted:
+		usage.Accepted++
+	case OutcomeClientError, OutcomeRateLimited, OutcomeServerError, OutcomeTransportError:
+		usage.Failed++
+	}
+}
+
+/*
+allow returns a BudgetExceededError if a budget cap for the endpoint is reached in the current window,
+counting the calls in flight, and otherwise reserves a slot for the call until it's observed.
+*/
+func (ul *UsageLedger) allow(endpoint string) error {
+	ul.mutex.Lock()
+	defer ul.mutex.Unlock()
+	snapshot := ul.current()
+	for _, budgetCap := range ul.caps {
+		var usage *EndpointUsage
+		var pending int
+		if !isPopulated(budgetCap.Endpoint) {
+			usage = snapshot.Total()
+			for _, endpointPending := range ul.pending {
+				pending += endpointPending
+			}
+		} else if budgetCap.Endpoint == endpoint {
+			usage = snapshot.endpoint(endpoint)
+			pending = ul.pending[endpoint]
+		} else {
+			continue
+		}
+		if (budgetCap.MaxCalls > 0 && usage.Calls+pending >= budgetCap.MaxCalls) ||
+			(budgetCap.MaxMatched > 0 && usage.Matched+pending >= budgetCap.MaxMatched) {
+			return &BudgetExceededError{Endpoint: endpoint, Window: snapshot.Window, Cap: budgetCap}
+		}
+	}
+	ul.pending[endpoint]++
+	return nil
+}
+
+// current returns the usage of the current time window, must be called with the mutex held.
+func (ul *UsageLedger) current() *UsageSnapshot {
+	now := ul.now().In(ul.location)
+	var start, end time.Time
+	var window string
+	if ul.window == UsageDaily {
+		start = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, ul.location)
+		end = start.AddDate(0, 0, 1)
+		window = start.Format("2006-01-02")
+	} else {
+		start = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, ul.location)
+		end = start.AddDate(0, 1, 0)
+		window = start.Format("2006-01")
+	}
+	snapshot, ok := ul.windows[window]
+	if !ok {
+		snapshot = &UsageSnapshot{
+			Window:    window,
+			Start:     start,
+			End:       end,
+			Endpoints: make(map[string]*EndpointUsage),
+		}
+		ul.windows[window] = snapshot
+	}
+	return snapshot
+}
+
+func (snapshot *UsageSnapshot) endpoint(endpoint string) *EndpointUsage {
+	usage, ok := snapshot.Endpoints[endpoint]
+	if !ok {
+		usage = &EndpointUsage{}
+		snapshot.Endpoints[endpoint] = usage
+	}
+	return usage
+}
+
+func (snapshot *UsageSnapshot) copy() UsageSnapshot {
+	snapshotCopy := *snapshot
+	snapshotCopy.Endpoints = make(map[string]*EndpointUsage, len(snapshot.Endpoints))
+	for endpoint, usage := range snapshot.Endpoints {
+		usageCopy := *usage
+		if usage.DataFilters != nil {
+			usageCopy.DataFilters = make(map[string]int, len(usage.DataFilters))
+			for dataFilter, count := range usage.DataFilters {
+				usageCopy.DataFilters[dataFilter] = count
+			}
+		}
+		snapshotCopy.Endpoints[endpoint] = &usageCopy
+	}
+	return snapshotCopy
+}
@@ -0,0 +1,116 @@
+package fullcontact
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	assert "github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func getLedgerTestClient(t *testing.T, ledger *UsageLedger, statusCode int) *fullContactClient {
+	fcClient, err := NewFullContactClient(
+		WithCredentialsProvider(StaticCredentialsProvider{apiKey: "apikey"}),
+		WithHTTPClient(&http.Client{Transport: stubTransport(statusCode, "{}")}),
+		WithUsageLedger(ledger))
+	assert.NoError(t, err)
+	return fcClient
+}
+
+func TestUsageLedgerCountsOutcomes(t *testing.T) {
+	ledger := NewUsageLedger()
+	personRequest, _ := NewPersonRequest(WithEmail("marquitaross006@gmail.com"), WithDataFilter("individual"), WithDataFilter("social"))
+
+	assert.NoError(t, (<-getLedgerTestClient(t, ledger, 200).PersonEnrich(personRequest)).Err)
+	assert.NoError(t, (<-getLedgerTestClient(t, ledger, 200).PersonEnrich(personRequest)).Err)
+	assert.NoError(t, (<-getLedgerTestClient(t, ledger, 404).PersonEnrich(personRequest)).Err)
+	assert.NoError(t, (<-getLedgerTestClient(t, ledger, 202).PersonEnrich(personRequest)).Err)
+	assert.NoError(t, (<-getLedgerTestClient(t, ledger, 400).TagsGet("r1")).Err)
+
+	snapshot := ledger.Snapshot()
+	assert.Equal(t, &EndpointUsage{Calls: 4, Matched: 2, NoMatch: 1, Accepted: 1,
+		DataFilters: map[string]int{"individual": 2, "social": 2}}, snapshot.Endpoints["person.enrich"])
+	assert.Equal(t, &EndpointUsage{Calls: 1, Failed: 1}, snapshot.Endpoints["tags.get"])
+	assert.Equal(t, 5, snapshot.Total().Calls)
+}
+
+func TestUsageLedgerBudgetCap(t *testing.T) {
+	ledger := NewUsageLedger(
+		WithBudgetCap(BudgetCap{Endpoint: "person.enrich", MaxMatched: 1}),
+		WithBudgetCap(BudgetCap{MaxCalls: 3}))
+	fcClient := getLedgerTestClient(t, ledger, 200)
+	personRequest, _ := NewPersonRequest(WithEmail("marquitaross006@gmail.com"))
+
+	assert.NoError(t, (<-fcClient.PersonEnrich(personRequest)).Err)
+	resp := <-fcClient.PersonEnrich(personRequest)
+	assert.True(t, IsBudgetExceeded(resp.Err))
+	assert.Nil(t, resp.RawHttpResponse)
+
+	assert.NoError(t, (<-fcClient.TagsGet("r1")).Err)
+	assert.NoError(t, (<-fcClient.TagsGet("r1")).Err)
+	resp = <-fcClient.TagsGet("r1")
+	assert.EqualError(t, resp.Err, "FullContactError: Budget exceeded for all endpoints in window "+ledger.Snapshot().Window)
+	assert.Equal(t, 1, ledger.Snapshot().Endpoints["person.enrich"].Rejected)
+}
+
+func TestUsageLedgerWindows(t *testing.T) {
+	now := time.Date(2020, 10, 19, 23, 0, 0, 0, time.UTC)
+	ledger := NewUsageLedger(WithUsageWindow(UsageDaily), WithBudgetCap(BudgetCap{MaxCalls: 1}))
+	ledger.now = func() time.Time { return now }
+	fcClient := getLedgerTestClient(t, ledger, 200)
+
+	assert.NoError(t, (<-fcClient.TagsGet("r1")).Err)
+	assert.Error(t, (<-fcClient.TagsGet("r1")).Err)
+	now = now.Add(2 * time.Hour)
+	assert.NoError(t, (<-fcClient.TagsGet("r1")).Err)
+
+	var buffer bytes.Buffer
+	assert.NoError(t, ledger.WriteJSON(&buffer))
+	var snapshots []UsageSnapshot
+	assert.NoError(t, json.Unmarshal(buffer.Bytes(), &snapshots))
+	assert.Len(t, snapshots, 2)
+	assert.Equal(t, "2020-10-19", snapshots[0].Window)
+	assert.Equal(t, 1, snapshots[0].Endpoints["tags.get"].Rejected)
+	assert.Equal(t, "2020-10-20", snapshots[1].Window)
+	assert.Equal(t, 1, snapshots[1].Endpoints["tags.get"].Matched)
+}
+
+func TestUsageLedgerBudgetCapCountsCallsInFlight(t *testing.T) {
+	ledger := NewUsageLedger(WithBudgetCap(BudgetCap{Endpoint: "tags.get", MaxCalls: 2}))
+	release := make(chan bool)
+	fcClient, err := NewFullContactClient(
+		WithCredentialsProvider(StaticCredentialsProvider{apiKey: "apikey"}),
+		WithHTTPClient(&http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			<-release
+			return chaosResponse(req, 200, "{}"), nil
+		})}),
+		WithUsageLedger(ledger))
+	assert.NoError(t, err)
+
+	first := fcClient.TagsGet("r1")
+	second := fcClient.TagsGet("r2")
+	// the calls are reserved in their goroutines
+	assert.Eventually(t, func() bool {
+		ledger.mutex.Lock()
+		defer ledger.mutex.Unlock()
+		return ledger.pending["tags.get"] == 2
+	}, time.Second, time.Millisecond)
+	assert.True(t, IsBudgetExceeded((<-fcClient.TagsGet("r3")).Err))
+
+	release <- true
+	release <- true
+	assert.NoError(t, (<-first).Err)
+	assert.NoError(t, (<-second).Err)
+	assert.True(t, IsBudgetExceeded((<-fcClient.TagsGet("r3")).Err))
+	assert.Equal(t, 2, ledger.Snapshot().Endpoints["tags.get"].Calls)
+	assert.Empty(t, ledger.pending["tags.get"])
+}
+
+func TestIsBudgetExceededWrapped(t *testing.T) {
+	err := fmt.Errorf("sync failed: %w", &BudgetExceededError{Endpoint: "tags.get"})
+	assert.True(t, IsBudgetExceeded(err))
+	assert.False(t, IsBudgetExceeded(errors.New("other")))
+}
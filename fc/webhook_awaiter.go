@@ -0,0 +1,332 @@
+package fullcontact
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	awaiterPendingPrefix = "webhook-awaiter/pending/"
+	awaiterResultPrefix  = "webhook-awaiter/result/"
+)
+
+// PersonFuture is the pending result of a Person Enrich request delivered to a webhook.
+type PersonFuture struct {
+	RecordId string
+	done     chan struct{}
+	once     sync.Once
+	webhook  *PersonWebhook
+	err      error
+}
+
+func newPersonFuture(recordId string) *PersonFuture {
+	return &PersonFuture{RecordId: recordId, done: make(chan struct{})}
+}
+
+// Done returns a channel closed once the result is delivered or the wait timed out.
+func (pf *PersonFuture) Done() <-chan struct{} {
+	return pf.done
+}
+
+// Await blocks until the result is delivered, the wait timed out or ctx is done.
+func (pf *PersonFuture) Await(ctx context.Context) (*PersonWebhook, error) {
+	select {
+	case <-pf.done:
+		return pf.webhook, pf.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (pf *PersonFuture) complete(webhook *PersonWebhook, err error) {
+	pf.once.Do(func() {
+		pf.webhook = webhook
+		pf.err = err
+		close(pf.done)
+	})
+}
+
+type pendingPersonWebhook struct {
+	RecordId string    `json:"recordId"`
+	Deadline time.Time `json:"deadline"`
+}
+
+type storedPersonWebhook struct {
+	RecordId  string          `json:"recordId"`
+	RequestId string          `json:"requestId,omitempty"`
+	Body      json.RawMessage `json:"body"`
+}
+
+type pendingPerson struct {
+	future *PersonFuture
+	timer  *time.Timer
+}
+
+type WebhookAwaiterOption func(wa *WebhookAwaiter)
+
+/*
+WebhookAwaiter sends Person Enrich requests with a webhook url and returns a PersonFuture,
+completed once the result is received by the WebhookHandler. Requests are correlated by their
+RecordId, which is generated if absent, and added as a query parameter to the webhook url.
+
+With a Store, pending requests survive a restart: results received for requests of a previous
+run are kept in the Store until the request is resumed with Resume.
+*/
+type WebhookAwaiter struct {
+	client      Enricher
+	webhookUrl  string
+	timeout     time.Duration
+	store       Store
+	newRecordId func() string
+	mutex       sync.Mutex
+	pending     map[string]*pendingPerson
+}
+
+func NewWebhookAwaiter(client Enricher, handler *WebhookHandler, webhookUrl string, options ...WebhookAwaiterOption) (*WebhookAwaiter, error) {
+	if client == nil {
+		return nil, NewFullContactError("Client can't be nil")
+	}
+	if handler == nil {
+		return nil, NewFullContactError("WebhookHandler can't be nil")
+	}
+	if _, err := url.Parse(webhookUrl); err != nil || !isPopulated(webhookUrl) {
+		return nil, NewFullContactError("Valid webhook url must be present")
+	}
+	wa := &WebhookAwaiter{
+		client:      client,
+		webhookUrl:  webhookUrl,
+		timeout:     10 * time.Minute,
+		newRecordId: randomRecordId,
+		pending:     make(map[string]*pendingPerson),
+	}
+	for _, opts := range options {
+		opts(wa)
+	}
+	handler.OnPersonEnrich(wa.onPersonWebhook)
+	return wa, nil
+}
+
+// WithAwaitTimeout sets how long a result is awaited before the future fails, 10 minutes by default.
+func WithAwaitTimeout(timeout time.Duration) WebhookAwaiterOption {
+	return func(wa *WebhookAwaiter) {
+		wa.timeout = timeout
+	}
+}
+
+// WithAwaitStore persists the pending requests and the results received for them.
+func WithAwaitStore(store Store) WebhookAwaiterOption {
+	return func(wa *WebhookAwaiter) {
+		wa.store = store
+	}
+}
+
+// WithAwaitRecordIdGenerator sets the function generating the RecordId of requests without one.
+func WithAwaitRecordIdGenerator(newRecordId func() string) WebhookAwaiterOption {
+	return func(wa *WebhookAwaiter) {
+		wa.newRecordId = newRecordId
+	}
+}
+
+func randomRecordId() string {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+/*
+PersonEnrich sends a copy of personRequest with the RecordId and webhook url set, and returns a
+future for its result. An error is returned if the API doesn't accept the request with 202.
+*/
+func (wa *WebhookAwaiter) PersonEnrich(ctx context.Context, personRequest *PersonRequest) (*PersonFuture, error) {
+	if personRequest == nil {
+		return nil, NewFullContactError("Person Request can't be nil")
+	}
+	request := *personRequest
+	if !isPopulated(request.RecordId) {
+		request.RecordId = wa.newRecordId()
+	}
+	webhookUrl := wa.webhookUrl
+	if isPopulated(request.WebhookUrl) {
+		webhookUrl = request.WebhookUrl
+	}
+	var err error
+	request.WebhookUrl, err = withRecordIdQuery(webhookUrl, request.RecordId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Registered before sending, as the result may be delivered before the API responds
+	future, err := wa.register(request.RecordId, time.Now().Add(wa.timeout), true)
+	if err != nil {
+		return nil, err
+	}
+	resp := <-wa.client.PersonEnrich(&request, WithCallContext(ctx))
+	if !resp.IsAccepted() {
+		err = resp.Err
+		if err == nil {
+			err = NewFullContactError(fmt.Sprintf("Expected 202 Accepted for webhook request, got %d", resp.StatusCode))
+		}
+		wa.fail(request.RecordId, err)
+		return nil, err
+	}
+	return future, nil
+}
+
+/*
+Resume returns the future of a request still pending, possibly sent before a restart. The
+future is completed at once if the result was received in the meantime.
+*/
+func (wa *WebhookAwaiter) Resume(recordId string) (*PersonFuture, error) {
+	wa.mutex.Lock()
+	p, ok := wa.pending[recordId]
+	wa.mutex.Unlock()
+	if ok {
+		return p.future, nil
+	}
+	if wa.store == nil {
+		return nil, NewFullContactError("No pending webhook for record: " + recordId)
+	}
+	value, err := wa.store.Load(awaiterPendingPrefix + recordId)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, NewFullContactError("No pending webhook for record: " + recordId)
+	}
+	var pending pendingPersonWebhook
+	if err = json.Unmarshal(value, &pending); err != nil {
+		return nil, err
+	}
+
+	value, err = wa.store.Load(awaiterResultPrefix + recordId)
+	if err != nil {
+		return nil, err
+	}
+	if value != nil {
+		var stored storedPersonWebhook
+		if err = json.Unmarshal(value, &stored); err != nil {
+			return nil, err
+		}
+		var person PersonResp
+		if err = json.Unmarshal(stored.Body, &person); err != nil {
+			return nil, err
+		}
+		future := newPersonFuture(recordId)
+		future.complete(&PersonWebhook{RecordId: recordId, RequestId: stored.RequestId, Person: &person, Body: stored.Body}, nil)
+		_ = wa.store.Delete(awaiterResultPrefix + recordId)
+		_ = wa.store.Delete(awaiterPendingPrefix + recordId)
+		return future, nil
+	}
+	return wa.register(recordId, pending.Deadline, false)
+}
+
+// Pending returns the RecordIds of the requests whose result wasn't received yet, including
+// the requests of a previous run if a Store is used.
+func (wa *WebhookAwaiter) Pending() ([]string, error) {
+	recordIds := make(map[string]bool)
+	wa.mutex.Lock()
+	for recordId := range wa.pending {
+		recordIds[recordId] = true
+	}
+	wa.mutex.Unlock()
+	if wa.store != nil {
+		keys, err := wa.store.Keys(awaiterPendingPrefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			recordIds[strings.TrimPrefix(key, awaiterPendingPrefix)] = true
+		}
+	}
+	pending := make([]string, 0, len(recordIds))
+	for recordId := range recordIds {
+		pending = append(pending, recordId)
+	}
+	sort.Strings(pending)
+	return pending, nil
+}
+
+func (wa *WebhookAwaiter) register(recordId string, deadline time.Time, persist bool) (*PersonFuture, error) {
+	wa.mutex.Lock()
+	defer wa.mutex.Unlock()
+	if _, ok := wa.pending[recordId]; ok {
+		return nil, NewFullContactError("Webhook already pending for record: " + recordId)
+	}
+	if persist && wa.store != nil {
+		value, err := json.Marshal(&pendingPersonWebhook{RecordId: recordId, Deadline: deadline})
+		if err != nil {
+			return nil, err
+		}
+		if err = wa.store.Save(awaiterPendingPrefix+recordId, value); err != nil {
+			return nil, err
+		}
+	}
+	p := &pendingPerson{future: newPersonFuture(recordId)}
+	p.timer = time.AfterFunc(time.Until(deadline), func() {
+		wa.fail(recordId, NewFullContactError("Timed out waiting for webhook of record: "+recordId))
+	})
+	wa.pending[recordId] = p
+	return p.future, nil
+}
+
+func (wa *WebhookAwaiter) remove(recordId string) (*pendingPerson, bool) {
+	wa.mutex.Lock()
+	defer wa.mutex.Unlock()
+	p, ok := wa.pending[recordId]
+	if ok {
+		delete(wa.pending, recordId)
+		p.timer.Stop()
+		if wa.store != nil {
+			_ = wa.store.Delete(awaiterPendingPrefix + recordId)
+		}
+	}
+	return p, ok
+}
+
+func (wa *WebhookAwaiter) fail(recordId string, err error) {
+	if p, ok := wa.remove(recordId); ok {
+		p.future.complete(nil, err)
+	}
+}
+
+func (wa *WebhookAwaiter) onPersonWebhook(webhook *PersonWebhook) error {
+	if !isPopulated(webhook.RecordId) {
+		return nil
+	}
+	if p, ok := wa.remove(webhook.RecordId); ok {
+		p.future.complete(webhook, nil)
+		return nil
+	}
+	if wa.store == nil {
+		return nil
+	}
+	// Result of a request sent before a restart, kept until resumed
+	value, err := wa.store.Load(awaiterPendingPrefix + webhook.RecordId)
+	if err != nil || value == nil {
+		return err
+	}
+	value, err = json.Marshal(&storedPersonWebhook{RecordId: webhook.RecordId, RequestId: webhook.RequestId, Body: webhook.Body})
+	if err != nil {
+		return err
+	}
+	return wa.store.Save(awaiterResultPrefix+webhook.RecordId, value)
+}
+
+func withRecordIdQuery(webhookUrl string, recordId string) (string, error) {
+	u, err := url.Parse(webhookUrl)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	query.Set("recordId", recordId)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
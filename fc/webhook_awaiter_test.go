@@ -0,0 +1,104 @@
+package fullcontact
+
+import (
+	"context"
+	assert "github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestWebhookAwaiterCompletesFuture(t *testing.T) {
+	mockClient := NewMockClient()
+	mockClient.Respond("PersonEnrich", &APIResponse{StatusCode: 202})
+	handler := NewWebhookHandler()
+	awaiter, err := NewWebhookAwaiter(mockClient, handler, "https://example.com/webhook/person",
+		WithAwaitRecordIdGenerator(func() string { return "r1" }))
+	assert.NoError(t, err)
+
+	personRequest, _ := NewPersonRequest(WithEmail("marquitaross006@gmail.com"))
+	future, err := awaiter.PersonEnrich(context.Background(), personRequest)
+	assert.NoError(t, err)
+	assert.Equal(t, "r1", future.RecordId)
+	sent := mockClient.CallsTo("PersonEnrich")[0].Args[0].(*PersonRequest)
+	assert.Equal(t, "r1", sent.RecordId)
+	assert.Equal(t, "https://example.com/webhook/person?recordId=r1", sent.WebhookUrl)
+	assert.Empty(t, personRequest.RecordId)
+
+	pending, _ := awaiter.Pending()
+	assert.Equal(t, []string{"r1"}, pending)
+	resp := postWebhook(handler, http.MethodPost, "/webhook/person?recordId=r1", "{\"fullName\":\"Marquita H Ross\"}")
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	webhook, err := future.Await(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "Marquita H Ross", webhook.Person.FullName)
+	pending, _ = awaiter.Pending()
+	assert.Empty(t, pending)
+}
+
+func TestWebhookAwaiterTimeout(t *testing.T) {
+	mockClient := NewMockClient()
+	mockClient.Respond("PersonEnrich", &APIResponse{StatusCode: 202})
+	awaiter, err := NewWebhookAwaiter(mockClient, NewWebhookHandler(), "https://example.com/webhook/person",
+		WithAwaitTimeout(20*time.Millisecond))
+	assert.NoError(t, err)
+
+	personRequest, _ := NewPersonRequest(WithEmail("marquitaross006@gmail.com"), WithRecordId("r1"))
+	future, err := awaiter.PersonEnrich(context.Background(), personRequest)
+	assert.NoError(t, err)
+	_, err = future.Await(context.Background())
+	assert.EqualError(t, err, "FullContactError: Timed out waiting for webhook of record: r1")
+}
+
+func TestWebhookAwaiterNotAccepted(t *testing.T) {
+	mockClient := NewMockClient()
+	mockClient.Respond("PersonEnrich", &APIResponse{StatusCode: 400})
+	awaiter, err := NewWebhookAwaiter(mockClient, NewWebhookHandler(), "https://example.com/webhook/person")
+	assert.NoError(t, err)
+
+	personRequest, _ := NewPersonRequest(WithEmail("marquitaross006@gmail.com"))
+	_, err = awaiter.PersonEnrich(context.Background(), personRequest)
+	assert.EqualError(t, err, "FullContactError: Expected 202 Accepted for webhook request, got 400")
+	pending, _ := awaiter.Pending()
+	assert.Empty(t, pending)
+}
+
+func TestWebhookAwaiterResumesAfterRestart(t *testing.T) {
+	store := NewMemoryStore()
+	mockClient := NewMockClient()
+	mockClient.Respond("PersonEnrich", &APIResponse{StatusCode: 202})
+	awaiter, err := NewWebhookAwaiter(mockClient, NewWebhookHandler(), "https://example.com/webhook/person",
+		WithAwaitStore(store))
+	assert.NoError(t, err)
+	for _, recordId := range []string{"r1", "r2"} {
+		personRequest, _ := NewPersonRequest(WithEmail("marquitaross006@gmail.com"), WithRecordId(recordId))
+		_, err = awaiter.PersonEnrich(context.Background(), personRequest)
+		assert.NoError(t, err)
+	}
+
+	handler := NewWebhookHandler()
+	restarted, err := NewWebhookAwaiter(mockClient, handler, "https://example.com/webhook/person", WithAwaitStore(store))
+	assert.NoError(t, err)
+	pending, _ := restarted.Pending()
+	assert.Equal(t, []string{"r1", "r2"}, pending)
+	postWebhook(handler, http.MethodPost, "/webhook/person?recordId=r1", "{\"fullName\":\"Marquita H Ross\"}")
+
+	future, err := restarted.Resume("r1")
+	assert.NoError(t, err)
+	webhook, err := future.Await(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "Marquita H Ross", webhook.Person.FullName)
+
+	future, err = restarted.Resume("r2")
+	assert.NoError(t, err)
+	postWebhook(handler, http.MethodPost, "/webhook/person?recordId=r2", "{\"fullName\":\"Jane Doe\"}")
+	webhook, err = future.Await(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "Jane Doe", webhook.Person.FullName)
+
+	pending, _ = restarted.Pending()
+	assert.Empty(t, pending)
+	_, err = restarted.Resume("r3")
+	assert.EqualError(t, err, "FullContactError: No pending webhook for record: r3")
+}
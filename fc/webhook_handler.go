@@ -0,0 +1,320 @@
+package fullcontact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+)
+
+type WebhookKind string
+
+const (
+	WebhookPersonEnrich  WebhookKind = "person"
+	WebhookCompanyEnrich WebhookKind = "company"
+	WebhookAudience      WebhookKind = "audience"
+)
+
+// PersonWebhook is a Person Enrich result delivered to a webhook.
+type PersonWebhook struct {
+	RecordId  string
+	RequestId string
+	Person    *PersonResp
+	Body      []byte
+}
+
+// CompanyWebhook is a Company Enrich result delivered to a webhook.
+type CompanyWebhook struct {
+	RecordId  string
+	RequestId string
+	Company   *CompanyResponse
+	Body      []byte
+}
+
+// AudienceNotification is sent to the webhook of an AudienceRequest once the audience is ready for download.
+type AudienceNotification struct {
+	RequestId string
+	Body      []byte
+}
+
+type webhookEnvelope struct {
+	RecordId  string `json:"recordId"`
+	RequestId string `json:"requestId"`
+}
+
+type WebhookHandlerOption func(wh *WebhookHandler)
+
+/*
+WebhookHandler is an http.Handler receiving the results FullContact sends to webhook urls.
+
+The kind of result is taken from the last path segment of the request url, so the handler can be
+mounted once and used with webhook urls ending in /person, /company and /audience, e.g.
+
+	http.Handle("/fullcontact/webhook/", handler)
+	fc.WithWebhookUrl("https://example.com/fullcontact/webhook/person")
+
+Results are correlated by the recordId and requestId of the body, or of the url query if absent.
+Callbacks are registered per kind and a callback error answers 500, so the delivery is retried.
+Deliveries with the same kind, correlation ids and body are only dispatched once within the
+duplicate window. A duplicate arriving while the first delivery is still dispatched answers 409,
+so it is retried in case the first delivery fails.
+*/
+type WebhookHandler struct {
+	mutex             sync.Mutex
+	maxBodyBytes      int64
+	duplicateWindow   time.Duration
+	personCallbacks   []func(webhook *PersonWebhook) error
+	companyCallbacks  []func(webhook *CompanyWebhook) error
+	audienceCallbacks []func(notification *AudienceNotification) error
+	seen              map[string]*webhookDelivery
+	// expiries holds the keys of seen in the order they were completed, to expire them without a full scan
+	expiries []webhookExpiry
+	now      func() time.Time
+}
+
+type webhookDelivery struct {
+	seenAt   time.Time
+	inFlight bool
+}
+
+type webhookExpiry struct {
+	key    string
+	seenAt time.Time
+}
+
+type webhookClaim int
+
+const (
+	webhookClaimed webhookClaim = iota
+	webhookDuplicate
+	webhookInFlight
+)
+
+func NewWebhookHandler(options ...WebhookHandlerOption) *WebhookHandler {
+	wh := &WebhookHandler{
+		maxBodyBytes:    10 << 20,
+		duplicateWindow: time.Hour,
+		seen:            make(map[string]*webhookDelivery),
+		now:             time.Now,
+	}
+	for _, opts := range options {
+		opts(wh)
+	}
+	return wh
+}
+
+// WithWebhookMaxBodyBytes limits the size of the accepted bodies, 10MB by default.
+func WithWebhookMaxBodyBytes(maxBodyBytes int64) WebhookHandlerOption {
+	return func(wh *WebhookHandler) {
+		wh.maxBodyBytes = maxBodyBytes
+	}
+}
+
+// WithWebhookDuplicateWindow sets how long deliveries are remembered to suppress duplicates, 1 hour by default.
+func WithWebhookDuplicateWindow(duplicateWindow time.Duration) WebhookHandlerOption {
+	return func(wh *WebhookHandler) {
+		wh.duplicateWindow = duplicateWindow
+	}
+}
+
+func (wh *WebhookHandler) OnPersonEnrich(callback func(webhook *PersonWebhook) error) *WebhookHandler {
+	wh.mutex.Lock()
+	defer wh.mutex.Unlock()
+	wh.personCallbacks = append(wh.personCallbacks, callback)
+	return wh
+}
+
+func (wh *WebhookHandler) OnCompanyEnrich(callback func(webhook *CompanyWebhook) error) *WebhookHandler {
+	wh.mutex.Lock()
+	defer wh.mutex.Unlock()
+	wh.companyCallbacks = append(wh.companyCallbacks, callback)
+	return wh
+}
+
+func (wh *WebhookHandler) OnAudience(callback func(notification *AudienceNotification) error) *WebhookHandler {
+	wh.mutex.Lock()
+	defer wh.mutex.Unlock()
+	wh.audienceCallbacks = append(wh.audienceCallbacks, callback)
+	return wh
+}
+
+func (wh *WebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	wh.serve(WebhookKind(path.Base(r.URL.Path)), w, r)
+}
+
+// KindHandler returns an http.Handler receiving a single kind of result, whatever the url path.
+func (wh *WebhookHandler) KindHandler(kind WebhookKind) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		wh.serve(kind, w, r)
+	})
+}
+
+func (wh *WebhookHandler) serve(kind WebhookKind, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if kind != WebhookPersonEnrich && kind != WebhookCompanyEnrich && kind != WebhookAudience {
+		http.Error(w, "Unknown webhook: "+string(kind), http.StatusNotFound)
+		return
+	}
+
+	if r.ContentLength > wh.maxBodyBytes {
+		http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, wh.maxBodyBytes+1))
+	if err != nil {
+		http.Error(w, "Unreadable body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if int64(len(body)) > wh.maxBodyBytes {
+		http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	var envelope webhookEnvelope
+	if err = json.Unmarshal(body, &envelope); err != nil {
+		http.Error(w, "Malformed JSON body", http.StatusBadRequest)
+		return
+	}
+	if !isPopulated(envelope.RecordId) {
+		envelope.RecordId = r.URL.Query().Get("recordId")
+	}
+	if !isPopulated(envelope.RequestId) {
+		envelope.RequestId = r.URL.Query().Get("requestId")
+	}
+
+	key := deliveryKey(kind, envelope, body)
+	switch wh.claim(key) {
+	case webhookDuplicate:
+		w.WriteHeader(http.StatusOK)
+		return
+	case webhookInFlight:
+		http.Error(w, "Delivery already in progress", http.StatusConflict)
+		return
+	}
+	succeeded := false
+	// a panicking callback fails the delivery too, so that it isn't left in flight
+	defer func() {
+		wh.complete(key, succeeded)
+	}()
+	err = wh.dispatch(kind, envelope, body)
+	succeeded = err == nil
+	if err != nil {
+		if _, ok := err.(*webhookDecodeError); ok {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+type webhookDecodeError struct {
+	err error
+}
+
+func (err *webhookDecodeError) Error() string {
+	return "Malformed webhook body: " + err.err.Error()
+}
+
+func (wh *WebhookHandler) dispatch(kind WebhookKind, envelope webhookEnvelope, body []byte) error {
+	wh.mutex.Lock()
+	personCallbacks := wh.personCallbacks
+	companyCallbacks := wh.companyCallbacks
+	audienceCallbacks := wh.audienceCallbacks
+	wh.mutex.Unlock()
+
+	switch kind {
+	case WebhookPersonEnrich:
+		var person PersonResp
+		if err := json.Unmarshal(body, &person); err != nil {
+			return &webhookDecodeError{err: err}
+		}
+		webhook := &PersonWebhook{RecordId: envelope.RecordId, RequestId: envelope.RequestId, Person: &person, Body: body}
+		for _, callback := range personCallbacks {
+			if err := callback(webhook); err != nil {
+				return err
+			}
+		}
+	case WebhookCompanyEnrich:
+		var company CompanyResponse
+		if err := json.Unmarshal(body, &company); err != nil {
+			return &webhookDecodeError{err: err}
+		}
+		webhook := &CompanyWebhook{RecordId: envelope.RecordId, RequestId: envelope.RequestId, Company: &company, Body: body}
+		for _, callback := range companyCallbacks {
+			if err := callback(webhook); err != nil {
+				return err
+			}
+		}
+	case WebhookAudience:
+		notification := &AudienceNotification{RequestId: envelope.RequestId, Body: body}
+		for _, callback := range audienceCallbacks {
+			if err := callback(notification); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func deliveryKey(kind WebhookKind, envelope webhookEnvelope, body []byte) string {
+	hash := sha256.Sum256(body)
+	return string(kind) + "|" + envelope.RecordId + "|" + envelope.RequestId + "|" + hex.EncodeToString(hash[:])
+}
+
+/*
+claim records the delivery as in flight, unless it was already seen within the duplicate window.
+In-flight deliveries are only queued for expiry once completed.
+*/
+func (wh *WebhookHandler) claim(key string) webhookClaim {
+	wh.mutex.Lock()
+	defer wh.mutex.Unlock()
+	now := wh.now()
+	wh.expire(now)
+	if delivery, ok := wh.seen[key]; ok {
+		if delivery.inFlight {
+			return webhookInFlight
+		}
+		return webhookDuplicate
+	}
+	wh.seen[key] = &webhookDelivery{seenAt: now, inFlight: true}
+	return webhookClaimed
+}
+
+// expire forgets the deliveries completed before the duplicate window, which are at the front of expiries.
+func (wh *WebhookHandler) expire(now time.Time) {
+	expired := 0
+	for _, expiry := range wh.expiries {
+		if now.Sub(expiry.seenAt) <= wh.duplicateWindow {
+			break
+		}
+		// the key may have been claimed and completed again since
+		if delivery, ok := wh.seen[expiry.key]; ok && delivery.seenAt.Equal(expiry.seenAt) && !delivery.inFlight {
+			delete(wh.seen, expiry.key)
+		}
+		expired++
+	}
+	wh.expiries = wh.expiries[expired:]
+}
+
+// complete marks a dispatched delivery as seen from now on, or forgets it if it failed so that it can be retried.
+func (wh *WebhookHandler) complete(key string, succeeded bool) {
+	wh.mutex.Lock()
+	defer wh.mutex.Unlock()
+	if !succeeded {
+		delete(wh.seen, key)
+		return
+	}
+	now := wh.now()
+	wh.seen[key] = &webhookDelivery{seenAt: now}
+	wh.expiries = append(wh.expiries, webhookExpiry{key: key, seenAt: now})
+}
@@ -0,0 +1,190 @@
+package fullcontact
+
+import (
+	"errors"
+	assert "github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func postWebhook(handler http.Handler, method, url, body string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(method, url, strings.NewReader(body)))
+	return recorder
+}
+
+func TestWebhookHandlerDispatchesByKind(t *testing.T) {
+	var personWebhook *PersonWebhook
+	var companyWebhook *CompanyWebhook
+	var notification *AudienceNotification
+	handler := NewWebhookHandler().
+		OnPersonEnrich(func(webhook *PersonWebhook) error {
+			personWebhook = webhook
+			return nil
+		}).
+		OnCompanyEnrich(func(webhook *CompanyWebhook) error {
+			companyWebhook = webhook
+			return nil
+		}).
+		OnAudience(func(n *AudienceNotification) error {
+			notification = n
+			return nil
+		})
+
+	resp := postWebhook(handler, http.MethodPost, "/webhook/person?recordId=r1", "{\"fullName\":\"Marquita H Ross\"}")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "r1", personWebhook.RecordId)
+	assert.Equal(t, "Marquita H Ross", personWebhook.Person.FullName)
+
+	resp = postWebhook(handler, http.MethodPost, "/webhook/company", "{\"requestId\":\"q1\",\"name\":\"FullContact Inc\"}")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "q1", companyWebhook.RequestId)
+	assert.Equal(t, "FullContact Inc", companyWebhook.Company.Name)
+
+	resp = postWebhook(handler, http.MethodPost, "/webhook/audience", "{\"requestId\":\"a1\"}")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "a1", notification.RequestId)
+
+	resp = postWebhook(handler.KindHandler(WebhookPersonEnrich), http.MethodPost, "/callback", "{\"recordId\":\"r2\"}")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "r2", personWebhook.RecordId)
+}
+
+func TestWebhookHandlerStatuses(t *testing.T) {
+	handler := NewWebhookHandler(WithWebhookMaxBodyBytes(64)).
+		OnPersonEnrich(func(webhook *PersonWebhook) error {
+			if webhook.RecordId == "fail" {
+				return errors.New("callback failed")
+			}
+			return nil
+		})
+
+	resp := postWebhook(handler, http.MethodGet, "/webhook/person", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.Code)
+	assert.Equal(t, http.MethodPost, resp.Header().Get("Allow"))
+	assert.Equal(t, http.StatusNotFound, postWebhook(handler, http.MethodPost, "/webhook/unknown", "{}").Code)
+	assert.Equal(t, http.StatusBadRequest, postWebhook(handler, http.MethodPost, "/webhook/person", "{").Code)
+	assert.Equal(t, http.StatusBadRequest, postWebhook(handler, http.MethodPost, "/webhook/person", "{\"fullName\":1}").Code)
+	assert.Equal(t, http.StatusRequestEntityTooLarge,
+		postWebhook(handler, http.MethodPost, "/webhook/person", "{\"fullName\":\""+strings.Repeat("a", 64)+"\"}").Code)
+	assert.Equal(t, http.StatusInternalServerError, postWebhook(handler, http.MethodPost, "/webhook/person", "{\"recordId\":\"fail\"}").Code)
+}
+
+func TestWebhookHandlerSuppressesDuplicates(t *testing.T) {
+	now := time.Date(2020, 10, 19, 0, 0, 0, 0, time.UTC)
+	calls := 0
+	fail := true
+	handler := NewWebhookHandler(WithWebhookDuplicateWindow(time.Minute)).
+		OnPersonEnrich(func(webhook *PersonWebhook) error {
+			calls++
+			if fail {
+				return errors.New("callback failed")
+			}
+			return nil
+		})
+	handler.now = func() time.Time { return now }
+	body := "{\"recordId\":\"r1\",\"fullName\":\"Marquita H Ross\"}"
+
+	assert.Equal(t, http.StatusInternalServerError, postWebhook(handler, http.MethodPost, "/webhook/person", body).Code)
+	fail = false
+	assert.Equal(t, http.StatusOK, postWebhook(handler, http.MethodPost, "/webhook/person", body).Code)
+	assert.Equal(t, http.StatusOK, postWebhook(handler, http.MethodPost, "/webhook/person", body).Code)
+	assert.Equal(t, 2, calls)
+
+	assert.Equal(t, http.StatusOK, postWebhook(handler, http.MethodPost, "/webhook/person", "{\"recordId\":\"r2\"}").Code)
+	assert.Equal(t, 3, calls)
+
+	now = now.Add(2 * time.Minute)
+	assert.Equal(t, http.StatusOK, postWebhook(handler, http.MethodPost, "/webhook/person", body).Code)
+	assert.Equal(t, 4, calls)
+}
+
+func TestWebhookHandlerInFlightDuplicates(t *testing.T) {
+	dispatching := make(chan bool)
+	fail := make(chan bool)
+	calls := 0
+	handler := NewWebhookHandler().
+		OnPersonEnrich(func(webhook *PersonWebhook) error {
+			calls++
+			if calls == 1 {
+				dispatching <- true
+				if <-fail {
+					return errors.New("callback failed")
+				}
+			}
+			return nil
+		})
+	body := "{\"recordId\":\"r1\"}"
+
+	first := make(chan int)
+	go func() {
+		first <- postWebhook(handler, http.MethodPost, "/webhook/person", body).Code
+	}()
+	<-dispatching
+	assert.Equal(t, http.StatusConflict, postWebhook(handler, http.MethodPost, "/webhook/person", body).Code)
+	fail <- true
+	assert.Equal(t, http.StatusInternalServerError, <-first)
+
+	assert.Equal(t, http.StatusOK, postWebhook(handler, http.MethodPost, "/webhook/person", body).Code)
+	assert.Equal(t, 2, calls)
+}
+
+func TestWebhookHandlerExpiresCompletedDeliveries(t *testing.T) {
+	now := time.Date(2020, 10, 19, 0, 0, 0, 0, time.UTC)
+	handler := NewWebhookHandler(WithWebhookDuplicateWindow(time.Minute)).
+		OnPersonEnrich(func(webhook *PersonWebhook) error {
+			return nil
+		})
+	handler.now = func() time.Time { return now }
+
+	for i := 0; i < 4; i++ {
+		postWebhook(handler, http.MethodPost, "/webhook/person", "{\"recordId\":\"r"+strconv.Itoa(i)+"\"}")
+		now = now.Add(30 * time.Second)
+	}
+	assert.Len(t, handler.seen, 3)
+	assert.Len(t, handler.expiries, 3)
+	_, ok := handler.seen[deliveryKey(WebhookPersonEnrich, webhookEnvelope{RecordId: "r0"}, []byte("{\"recordId\":\"r0\"}"))]
+	assert.False(t, ok)
+}
+
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func TestWebhookHandlerBodyErrors(t *testing.T) {
+	handler := NewWebhookHandler(WithWebhookMaxBodyBytes(64))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/webhook/person", failingReader{}))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/webhook/person", strings.NewReader("{}"))
+	request.ContentLength = 65
+	handler.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, recorder.Code)
+}
+
+func TestWebhookHandlerPanickingCallbackReleasesDelivery(t *testing.T) {
+	panicking := true
+	handler := NewWebhookHandler().
+		OnPersonEnrich(func(webhook *PersonWebhook) error {
+			if panicking {
+				panic("callback panicked")
+			}
+			return nil
+		})
+	body := "{\"recordId\":\"r1\"}"
+
+	assert.Panics(t, func() {
+		postWebhook(handler, http.MethodPost, "/webhook/person", body)
+	})
+	panicking = false
+	assert.Equal(t, http.StatusOK, postWebhook(handler, http.MethodPost, "/webhook/person", body).Code)
+}
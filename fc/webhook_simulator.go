@@ -0,0 +1,337 @@
+package fullcontact
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebhookFaults holds the probability (0 to 1) of every fault injected by a WebhookSimulator.
+type WebhookFaults struct {
+	// RejectProbability answers the API request with 500 instead of accepting it.
+	RejectProbability float64
+	// DropProbability accepts the request but never delivers its result.
+	DropProbability float64
+	// DuplicateProbability delivers the result a second time once delivered.
+	DuplicateProbability float64
+	// DeliveryFailureProbability fails a delivery attempt before it reaches the webhook.
+	DeliveryFailureProbability float64
+}
+
+// WebhookDelivery describes a result delivered, or to be delivered, by a WebhookSimulator.
+type WebhookDelivery struct {
+	Kind       WebhookKind
+	Url        string
+	RecordId   string
+	RequestId  string
+	Attempts   int
+	StatusCode int
+	Delivered  bool
+	Dropped    bool
+	Duplicated bool
+}
+
+type WebhookSimulatorOption func(ws *WebhookSimulator)
+
+/*
+WebhookSimulator is a fake of the FullContact API for requests with a webhook url, for testing
+webhook consumers offline. Person Enrich, Company Enrich and Audience Create requests are answered
+with 202 at once, and a payload is POSTed to the webhook url after a delay. Deliveries answered
+with another status than 2xx are attempted again, up to a maximum number of attempts.
+
+The simulator is an http.RoundTripper, to be used as the transport of the client, and an
+http.Handler, to be mounted on an httptest server.
+*/
+type WebhookSimulator struct {
+	delay          time.Duration
+	maxAttempts    int
+	retryDelay     time.Duration
+	faults         *WebhookFaults
+	httpClient     *http.Client
+	personPayload  func(personRequest *PersonRequest) interface{}
+	companyPayload func(companyRequest *CompanyRequest) interface{}
+	mutex          sync.Mutex
+	random         *rand.Rand
+	requests       int
+	deliveries     []*WebhookDelivery
+	wg             sync.WaitGroup
+}
+
+func NewWebhookSimulator(options ...WebhookSimulatorOption) *WebhookSimulator {
+	ws := &WebhookSimulator{
+		delay:          100 * time.Millisecond,
+		maxAttempts:    3,
+		retryDelay:     100 * time.Millisecond,
+		faults:         &WebhookFaults{},
+		httpClient:     http.DefaultClient,
+		personPayload:  simulatedPerson,
+		companyPayload: simulatedCompany,
+		random:         rand.New(rand.NewSource(0)),
+	}
+	for _, opts := range options {
+		opts(ws)
+	}
+	return ws
+}
+
+// WithSimulatorDelay sets the delay between accepting a request and delivering its result, 100ms by default.
+func WithSimulatorDelay(delay time.Duration) WebhookSimulatorOption {
+	return func(ws *WebhookSimulator) {
+		ws.delay = delay
+	}
+}
+
+// WithSimulatorRedelivery sets the maximum attempts of a delivery, 3 by default, and the delay between them.
+func WithSimulatorRedelivery(maxAttempts int, retryDelay time.Duration) WebhookSimulatorOption {
+	return func(ws *WebhookSimulator) {
+		ws.maxAttempts = maxAttempts
+		ws.retryDelay = retryDelay
+	}
+}
+
+func WithSimulatorFaults(faults *WebhookFaults) WebhookSimulatorOption {
+	return func(ws *WebhookSimulator) {
+		ws.faults = faults
+	}
+}
+
+func WithSimulatorSeed(seed int64) WebhookSimulatorOption {
+	return func(ws *WebhookSimulator) {
+		ws.random = rand.New(rand.NewSource(seed))
+	}
+}
+
+// WithSimulatorHTTPClient sets the http.Client used to deliver the results to the webhooks.
+func WithSimulatorHTTPClient(httpClient *http.Client) WebhookSimulatorOption {
+	return func(ws *WebhookSimulator) {
+		ws.httpClient = httpClient
+	}
+}
+
+// WithSimulatorPersonPayload sets the function building the Person Enrich result delivered for a request.
+func WithSimulatorPersonPayload(personPayload func(personRequest *PersonRequest) interface{}) WebhookSimulatorOption {
+	return func(ws *WebhookSimulator) {
+		ws.personPayload = personPayload
+	}
+}
+
+// WithSimulatorCompanyPayload sets the function building the Company Enrich result delivered for a request.
+func WithSimulatorCompanyPayload(companyPayload func(companyRequest *CompanyRequest) interface{}) WebhookSimulatorOption {
+	return func(ws *WebhookSimulator) {
+		ws.companyPayload = companyPayload
+	}
+}
+
+// Wait blocks until every accepted request is delivered, dropped or out of attempts.
+func (ws *WebhookSimulator) Wait() {
+	ws.wg.Wait()
+}
+
+// Deliveries returns a copy of the deliveries of every accepted request, in the order accepted.
+func (ws *WebhookSimulator) Deliveries() []WebhookDelivery {
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+	deliveries := make([]WebhookDelivery, len(ws.deliveries))
+	for i, delivery := range ws.deliveries {
+		deliveries[i] = *delivery
+	}
+	return deliveries
+}
+
+func (ws *WebhookSimulator) RoundTrip(req *http.Request) (*http.Response, error) {
+	statusCode, body := ws.handle(req)
+	return chaosResponse(req, statusCode, body), nil
+}
+
+func (ws *WebhookSimulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	statusCode, body := ws.handle(r)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_, _ = w.Write([]byte(body))
+}
+
+func simulatorMessage(statusCode int, message string) (int, string) {
+	body, _ := json.Marshal(map[string]interface{}{"status": statusCode, "message": message})
+	return statusCode, string(body)
+}
+
+func (ws *WebhookSimulator) handle(req *http.Request) (int, string) {
+	if req.Body != nil {
+		defer req.Body.Close()
+	}
+	if req.Method != http.MethodPost {
+		return simulatorMessage(http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+	reqBytes, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return simulatorMessage(http.StatusBadRequest, err.Error())
+	}
+
+	var delivery *WebhookDelivery
+	var payload interface{}
+	switch path.Base(req.URL.Path) {
+	case "person.enrich":
+		var personRequest PersonRequest
+		if err = json.Unmarshal(reqBytes, &personRequest); err != nil {
+			return simulatorMessage(http.StatusBadRequest, err.Error())
+		}
+		delivery = &WebhookDelivery{Kind: WebhookPersonEnrich, Url: personRequest.WebhookUrl, RecordId: personRequest.RecordId}
+		payload = &personWebhookPayload{RecordId: personRequest.RecordId, Payload: ws.personPayload(&personRequest)}
+	case "company.enrich":
+		var companyRequest CompanyRequest
+		if err = json.Unmarshal(reqBytes, &companyRequest); err != nil {
+			return simulatorMessage(http.StatusBadRequest, err.Error())
+		}
+		delivery = &WebhookDelivery{Kind: WebhookCompanyEnrich, Url: companyRequest.WebhookUrl}
+		payload = ws.companyPayload(&companyRequest)
+	case "audience.create":
+		var audienceRequest AudienceRequest
+		if err = json.Unmarshal(reqBytes, &audienceRequest); err != nil {
+			return simulatorMessage(http.StatusBadRequest, err.Error())
+		}
+		delivery = &WebhookDelivery{Kind: WebhookAudience, Url: audienceRequest.WebhookURL}
+	default:
+		return simulatorMessage(http.StatusNotFound, "Endpoint not simulated: "+req.URL.Path)
+	}
+	if !isPopulated(delivery.Url) {
+		return simulatorMessage(http.StatusBadRequest, "Webhook url must be present")
+	}
+
+	ws.mutex.Lock()
+	if ws.roll(ws.faults.RejectProbability) {
+		ws.mutex.Unlock()
+		return simulatorMessage(http.StatusInternalServerError, "Internal Server Error")
+	}
+	ws.requests++
+	delivery.RequestId = fmt.Sprintf("simulated-%d", ws.requests)
+	if delivery.Kind == WebhookAudience {
+		payload = map[string]string{"requestId": delivery.RequestId}
+	}
+	delivery.Dropped = ws.roll(ws.faults.DropProbability)
+	ws.deliveries = append(ws.deliveries, delivery)
+	ws.mutex.Unlock()
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return simulatorMessage(http.StatusInternalServerError, err.Error())
+	}
+	if !delivery.Dropped {
+		ws.wg.Add(1)
+		go ws.deliver(delivery, body)
+	}
+
+	if delivery.Kind == WebhookAudience {
+		resp, _ := json.Marshal(map[string]string{"requestId": delivery.RequestId})
+		return http.StatusAccepted, string(resp)
+	}
+	return simulatorMessage(http.StatusAccepted, "Queued for search, the result will be sent to the webhook")
+}
+
+func (ws *WebhookSimulator) deliver(delivery *WebhookDelivery, body []byte) {
+	defer ws.wg.Done()
+	time.Sleep(ws.delay)
+	for {
+		ws.mutex.Lock()
+		delivery.Attempts++
+		failed := ws.roll(ws.faults.DeliveryFailureProbability)
+		ws.mutex.Unlock()
+
+		statusCode := 0
+		if !failed {
+			statusCode = ws.post(delivery, body)
+		}
+		ws.mutex.Lock()
+		delivery.StatusCode = statusCode
+		delivery.Delivered = statusCode >= 200 && statusCode < 300
+		duplicate := delivery.Delivered && !delivery.Duplicated && ws.roll(ws.faults.DuplicateProbability)
+		delivery.Duplicated = delivery.Duplicated || duplicate
+		retry := !delivery.Delivered && delivery.Attempts < ws.maxAttempts
+		ws.mutex.Unlock()
+
+		if duplicate {
+			ws.post(delivery, body)
+		}
+		if !retry {
+			return
+		}
+		time.Sleep(ws.retryDelay)
+	}
+}
+
+func (ws *WebhookSimulator) post(delivery *WebhookDelivery, body []byte) int {
+	resp, err := ws.httpClient.Post(delivery.Url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return 0
+	}
+	defer resp.Body.Close()
+	_, _ = ioutil.ReadAll(resp.Body)
+	return resp.StatusCode
+}
+
+// roll must be called with the mutex held.
+func (ws *WebhookSimulator) roll(probability float64) bool {
+	return probability > 0 && ws.random.Float64() < probability
+}
+
+// personWebhookPayload adds the RecordId of the request to the delivered Person Enrich result.
+type personWebhookPayload struct {
+	RecordId string
+	Payload  interface{}
+}
+
+func (payload *personWebhookPayload) MarshalJSON() ([]byte, error) {
+	body, err := json.Marshal(payload.Payload)
+	if err != nil || !isPopulated(payload.RecordId) {
+		return body, err
+	}
+	var fields map[string]interface{}
+	if json.Unmarshal(body, &fields) != nil {
+		return body, nil
+	}
+	fields["recordId"] = payload.RecordId
+	return json.Marshal(fields)
+}
+
+func simulatedPerson(personRequest *PersonRequest) interface{} {
+	person := &PersonResp{
+		FullName:     "Marquita H Ross",
+		AgeRange:     "30-39",
+		Gender:       "Female",
+		Location:     "San Francisco, California, United States",
+		Title:        "Senior Petroleum Manager",
+		Organization: "Mostow Co.",
+		Twitter:      "https://twitter.com/marqross91",
+		Linkedin:     "https://www.linkedin.com/in/marquita-ross-5b6b72192",
+		Updated:      time.Now().Format("2006-01-02"),
+	}
+	if len(personRequest.Emails) > 0 {
+		person.Email = personRequest.Emails[0]
+	}
+	if len(personRequest.Phones) > 0 {
+		person.Phone = personRequest.Phones[0]
+	}
+	return person
+}
+
+func simulatedCompany(companyRequest *CompanyRequest) interface{} {
+	company := &CompanyResponse{
+		Name:      "FullContact Inc",
+		Location:  "1755 Blake Street Suite 450 Denver CO, 80202 USA",
+		Website:   "https://www.fullcontact.com",
+		Category:  "Other",
+		Founded:   2010,
+		Employees: 300,
+		Updated:   time.Now().Format("2006-01-02"),
+	}
+	if isPopulated(companyRequest.Domain) {
+		company.Website = "https://" + strings.TrimPrefix(companyRequest.Domain, "www.")
+	}
+	return company
+}
@@ -0,0 +1,152 @@
+package fullcontact
+
+import (
+	"context"
+	assert "github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func getSimulatorTestClient(t *testing.T, simulator *WebhookSimulator) *fullContactClient {
+	fcClient, err := NewFullContactClient(
+		WithCredentialsProvider(StaticCredentialsProvider{apiKey: "apikey"}),
+		WithHTTPClient(&http.Client{Transport: simulator}))
+	assert.NoError(t, err)
+	return fcClient
+}
+
+func TestWebhookSimulatorDeliversResults(t *testing.T) {
+	var mutex sync.Mutex
+	people := make([]*PersonWebhook, 0)
+	notifications := make([]*AudienceNotification, 0)
+	handler := NewWebhookHandler().
+		OnPersonEnrich(func(webhook *PersonWebhook) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+			people = append(people, webhook)
+			return nil
+		}).
+		OnAudience(func(notification *AudienceNotification) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+			notifications = append(notifications, notification)
+			return nil
+		})
+	consumer := httptest.NewServer(handler)
+	defer consumer.Close()
+	simulator := NewWebhookSimulator(WithSimulatorDelay(time.Millisecond))
+	fcClient := getSimulatorTestClient(t, simulator)
+
+	personRequest, _ := NewPersonRequest(WithEmail("marquitaross006@gmail.com"), WithRecordId("r1"),
+		WithWebhookUrl(consumer.URL+"/webhook/person"))
+	resp := <-fcClient.PersonEnrich(personRequest)
+	assert.NoError(t, resp.Err)
+	assert.True(t, resp.IsAccepted())
+
+	audienceRequest, _ := NewAudienceRequest(WithWebhookUrlForAudience(consumer.URL+"/webhook/audience"),
+		WithTagForAudience(NewTag(WithTagKey("gender"), WithTagValue("female"))))
+	resp = <-fcClient.AudienceCreate(audienceRequest)
+	assert.NoError(t, resp.Err)
+	assert.Equal(t, "simulated-2", resp.AudienceResponse.RequestId)
+
+	simulator.Wait()
+	assert.Len(t, people, 1)
+	assert.Equal(t, "r1", people[0].RecordId)
+	assert.Equal(t, "marquitaross006@gmail.com", people[0].Person.Email)
+	assert.Len(t, notifications, 1)
+	assert.Equal(t, "simulated-2", notifications[0].RequestId)
+
+	deliveries := simulator.Deliveries()
+	assert.Len(t, deliveries, 2)
+	assert.Equal(t, WebhookDelivery{Kind: WebhookPersonEnrich, Url: consumer.URL + "/webhook/person", RecordId: "r1",
+		RequestId: "simulated-1", Attempts: 1, StatusCode: 200, Delivered: true}, deliveries[0])
+}
+
+func TestWebhookSimulatorRedelivers(t *testing.T) {
+	attempts := 0
+	consumer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer consumer.Close()
+	simulator := NewWebhookSimulator(WithSimulatorDelay(time.Millisecond), WithSimulatorRedelivery(3, time.Millisecond))
+	fcClient := getSimulatorTestClient(t, simulator)
+
+	companyRequest, _ := NewCompanyRequest(WithDomain("fullcontact.com"), WithWebhookUrlForCompany(consumer.URL))
+	assert.True(t, (<-fcClient.CompanyEnrich(companyRequest)).IsAccepted())
+	simulator.Wait()
+
+	delivery := simulator.Deliveries()[0]
+	assert.Equal(t, 3, delivery.Attempts)
+	assert.True(t, delivery.Delivered)
+}
+
+func TestWebhookSimulatorFaults(t *testing.T) {
+	hits := 0
+	consumer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+	}))
+	defer consumer.Close()
+	personRequest, _ := NewPersonRequest(WithEmail("marquitaross006@gmail.com"), WithWebhookUrl(consumer.URL))
+
+	simulator := NewWebhookSimulator(WithSimulatorFaults(&WebhookFaults{RejectProbability: 1}))
+	resp := <-getSimulatorTestClient(t, simulator).PersonEnrich(personRequest)
+	assert.Equal(t, 500, resp.StatusCode)
+
+	simulator = NewWebhookSimulator(WithSimulatorDelay(time.Millisecond), WithSimulatorFaults(&WebhookFaults{DropProbability: 1}))
+	assert.True(t, (<-getSimulatorTestClient(t, simulator).PersonEnrich(personRequest)).IsAccepted())
+	simulator.Wait()
+	assert.True(t, simulator.Deliveries()[0].Dropped)
+	assert.Equal(t, 0, hits)
+
+	simulator = NewWebhookSimulator(WithSimulatorDelay(time.Millisecond), WithSimulatorFaults(&WebhookFaults{DuplicateProbability: 1}))
+	assert.True(t, (<-getSimulatorTestClient(t, simulator).PersonEnrich(personRequest)).IsAccepted())
+	simulator.Wait()
+	assert.True(t, simulator.Deliveries()[0].Duplicated)
+	assert.Equal(t, 2, hits)
+
+	simulator = NewWebhookSimulator(WithSimulatorDelay(time.Millisecond), WithSimulatorRedelivery(2, time.Millisecond),
+		WithSimulatorFaults(&WebhookFaults{DeliveryFailureProbability: 1}))
+	assert.True(t, (<-getSimulatorTestClient(t, simulator).PersonEnrich(personRequest)).IsAccepted())
+	simulator.Wait()
+	assert.Equal(t, WebhookDelivery{Kind: WebhookPersonEnrich, Url: consumer.URL, RequestId: "simulated-1", Attempts: 2},
+		simulator.Deliveries()[0])
+	assert.Equal(t, 2, hits)
+}
+
+func TestWebhookSimulatorWithAwaiter(t *testing.T) {
+	handler := NewWebhookHandler()
+	consumer := httptest.NewServer(handler)
+	defer consumer.Close()
+	simulator := NewWebhookSimulator(WithSimulatorDelay(time.Millisecond))
+	awaiter, err := NewWebhookAwaiter(getSimulatorTestClient(t, simulator), handler, consumer.URL+"/person")
+	assert.NoError(t, err)
+
+	personRequest, _ := NewPersonRequest(WithEmail("marquitaross006@gmail.com"))
+	future, err := awaiter.PersonEnrich(context.Background(), personRequest)
+	assert.NoError(t, err)
+	webhook, err := future.Await(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "Marquita H Ross", webhook.Person.FullName)
+}
+
+func TestWebhookSimulatorHandler(t *testing.T) {
+	simulator := NewWebhookSimulator()
+	api := httptest.NewServer(simulator)
+	defer api.Close()
+
+	resp, err := http.Get(api.URL + "/v3/person.enrich")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	resp, err = http.Post(api.URL+"/v3/person.enrich", "application/json", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, err = http.Post(api.URL+"/v3/tags.get", "application/json", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}